@@ -22,6 +22,15 @@ func main() {
 	wg.Add(1)
 	go app.RunOrdersWorker(stopCh, &wg)
 
+	wg.Add(1)
+	go app.RunBalanceReconciler(stopCh, &wg)
+
+	wg.Add(1)
+	go app.RunEventsListener(stopCh, &wg)
+
+	wg.Add(1)
+	go app.RunWebhookDispatcher(stopCh, &wg)
+
 	wg.Add(1)
 	go app.Run(stopCh, &wg)
 
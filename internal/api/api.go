@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/frolmr/gophermart/internal/api/controller"
+	"github.com/frolmr/gophermart/internal/api/handlers"
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/storage"
 	"github.com/go-chi/chi/v5"
@@ -26,8 +27,8 @@ type API struct {
 	storage *storage.Storage
 }
 
-func NewAPI(lgr *zap.SugaredLogger, cfg *config.AppConfig, stor *storage.Storage) (*API, error) {
-	ctrl, err := controller.NewController(stor)
+func NewAPI(lgr *zap.SugaredLogger, cfg *config.AppConfig, stor *storage.Storage, events handlers.EventsSubscriber) (*API, error) {
+	ctrl, err := controller.NewController(stor, events)
 	if err != nil {
 		return nil, err
 	}
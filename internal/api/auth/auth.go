@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,32 +13,108 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+)
+
+var (
+	ErrUnknownKeyID  = errors.New("unknown jwt key id")
+	ErrWrongTokenUse = errors.New("unexpected jwt token use")
+)
+
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID   int64  `json:"user_id"`
+	TokenUse string `json:"token_use"`
 	jwt.RegisteredClaims
 }
 
 func GenerateAccessToken(userID int64, authConf *config.AuthConfig) (string, error) {
 	expirationTime := time.Now().Add(authConf.JWTAccessTokenExpiresIn)
 
-	return generateToken(userID, expirationTime, authConf.JWTKey)
+	return generateToken(userID, TokenUseAccess, expirationTime, authConf)
 }
 
 func GenerateRefreshToken(userID int64, authConf *config.AuthConfig) (string, error) {
 	expirationTime := time.Now().Add(authConf.JWTRefreshTokenExpiresIn)
 
-	return generateToken(userID, expirationTime, authConf.JWTKey)
+	return generateToken(userID, TokenUseRefresh, expirationTime, authConf)
+}
+
+func ParseAccessToken(tokenString string, authConf *config.AuthConfig) (*Claims, error) {
+	return parseToken(tokenString, TokenUseAccess, authConf)
+}
+
+func ParseRefreshToken(tokenString string, authConf *config.AuthConfig) (*Claims, error) {
+	return parseToken(tokenString, TokenUseRefresh, authConf)
+}
+
+func parseToken(tokenString, tokenUse string, authConf *config.AuthConfig) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if authConf.JWTSigningKey == nil {
+			if token.Method != jwt.SigningMethodHS256 {
+				return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+			}
+			return authConf.JWTKey, nil
+		}
+
+		keyID, _ := token.Header["kid"].(string)
+		key, ok := authConf.VerificationKey(keyID)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, keyID)
+		}
+		if method, _ := signingMethodForKey(key); method != token.Method {
+			return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+		}
+
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token: %w", err)
+	}
+	if !token.Valid {
+		return nil, errors.New("error parsing token: token is invalid")
+	}
+	if claims.TokenUse != tokenUse {
+		return nil, fmt.Errorf("error parsing token: %w: %q", ErrWrongTokenUse, claims.TokenUse)
+	}
+
+	return claims, nil
 }
 
-func generateToken(userID int64, expirationTime time.Time, key []byte) (string, error) {
+func generateToken(userID int64, tokenUse string, expirationTime time.Time, authConf *config.AuthConfig) (string, error) {
+	tokenID, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+
 	claims := &Claims{
-		UserID: userID,
+		UserID:   userID,
+		TokenUse: tokenUse,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if authConf.JWTSigningKey == nil {
+		return signToken(jwt.NewWithClaims(jwt.SigningMethodHS256, claims), authConf.JWTKey)
+	}
+
+	method, err := signingMethodForKey(authConf.JWTSigningKey.Signer.Public())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = authConf.JWTSigningKey.ID
+
+	return signToken(token, authConf.JWTSigningKey.Signer)
+}
+
+func signToken(token *jwt.Token, key interface{}) (string, error) {
 	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("error signing: %w", err)
@@ -41,3 +122,23 @@ func generateToken(userID int64, expirationTime time.Time, key []byte) (string,
 
 	return tokenString, nil
 }
+
+func signingMethodForKey(key interface{}) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+}
+
+func generateTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
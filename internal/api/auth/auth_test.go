@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAsymmetricConfig(t *testing.T, keyID string, signer crypto.Signer, extra ...*config.VerificationKey) *config.AuthConfig {
+	t.Helper()
+
+	return &config.AuthConfig{
+		JWTSigningKey: &config.SigningKey{ID: keyID, Signer: signer},
+		JWTVerificationKeys: append([]*config.VerificationKey{
+			{ID: keyID, PublicKey: signer.Public()},
+		}, extra...),
+		JWTAccessTokenExpiresIn:  time.Hour,
+		JWTRefreshTokenExpiresIn: time.Hour,
+	}
+}
+
+func TestGenerateAndParseAccessToken(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name       string
+		authConfig *config.AuthConfig
+	}{
+		{
+			name: "HS256",
+			authConfig: &config.AuthConfig{
+				JWTKey:                  []byte("secret"),
+				JWTAccessTokenExpiresIn: time.Hour,
+			},
+		},
+		{
+			name:       "EdDSA",
+			authConfig: newAsymmetricConfig(t, "ed-key", edKey),
+		},
+		{
+			name:       "RS256",
+			authConfig: newAsymmetricConfig(t, "rsa-key", rsaKey),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := GenerateAccessToken(42, tt.authConfig)
+			assert.NoError(t, err)
+
+			claims, err := ParseAccessToken(token, tt.authConfig)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(42), claims.UserID)
+		})
+	}
+}
+
+func TestParseToken_KeyRotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	oldConfig := newAsymmetricConfig(t, "old-key", oldKey)
+	oldToken, err := GenerateAccessToken(1, oldConfig)
+	assert.NoError(t, err)
+
+	rotatedConfig := newAsymmetricConfig(t, "new-key", newKey, &config.VerificationKey{ID: "old-key", PublicKey: oldKey.Public()})
+	claims, err := ParseAccessToken(oldToken, rotatedConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), claims.UserID)
+
+	retiredConfig := newAsymmetricConfig(t, "new-key", newKey)
+	_, err = ParseAccessToken(oldToken, retiredConfig)
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestParseToken_RejectsAlgorithmMismatch(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	hmacToken, err := GenerateAccessToken(1, &config.AuthConfig{JWTKey: []byte("secret"), JWTAccessTokenExpiresIn: time.Hour})
+	assert.NoError(t, err)
+
+	_, err = ParseAccessToken(hmacToken, newAsymmetricConfig(t, "ed-key", edKey))
+	assert.Error(t, err)
+
+	edToken, err := GenerateAccessToken(1, newAsymmetricConfig(t, "ed-key", edKey))
+	assert.NoError(t, err)
+
+	_, err = ParseAccessToken(edToken, &config.AuthConfig{JWTKey: []byte("secret")})
+	assert.Error(t, err)
+}
+
+func TestParseToken_RequiresTokenUse(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  time.Hour,
+		JWTRefreshTokenExpiresIn: time.Hour,
+	}
+
+	accessToken, err := GenerateAccessToken(1, authConfig)
+	assert.NoError(t, err)
+	refreshToken, err := GenerateRefreshToken(1, authConfig)
+	assert.NoError(t, err)
+
+	claims, err := ParseRefreshToken(refreshToken, authConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, TokenUseRefresh, claims.TokenUse)
+
+	_, err = ParseAccessToken(refreshToken, authConfig)
+	assert.ErrorIs(t, err, ErrWrongTokenUse)
+
+	_, err = ParseRefreshToken(accessToken, authConfig)
+	assert.ErrorIs(t, err, ErrWrongTokenUse)
+}
+
+func TestBuildJWKSet(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	authConfig := newAsymmetricConfig(t, "ed-key", edKey, &config.VerificationKey{ID: "rsa-key", PublicKey: &rsaKey.PublicKey})
+
+	set := BuildJWKSet(authConfig)
+
+	assert.Len(t, set.Keys, 2)
+	assert.Equal(t, "OKP", set.Keys[0].KeyType)
+	assert.Equal(t, "ed-key", set.Keys[0].KeyID)
+	assert.Equal(t, "EdDSA", set.Keys[0].Algorithm)
+	assert.NotEmpty(t, set.Keys[0].X)
+	assert.Equal(t, "RSA", set.Keys[1].KeyType)
+	assert.Equal(t, "rsa-key", set.Keys[1].KeyID)
+	assert.Equal(t, "AQAB", set.Keys[1].E)
+	assert.NotEmpty(t, set.Keys[1].N)
+
+	assert.Empty(t, BuildJWKSet(&config.AuthConfig{JWTKey: []byte("secret")}).Keys)
+}
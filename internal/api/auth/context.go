@@ -0,0 +1,14 @@
+package auth
+
+import "context"
+
+type userIDContextKey struct{}
+
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+func UserFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDContextKey{}).(int64)
+	return userID, ok
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/config"
+)
+
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+)
+
+func SetTokenCookie(w http.ResponseWriter, authConf *config.AuthConfig, name, value string, ttl time.Duration) {
+	http.SetCookie(w, newCookie(authConf, name, value, time.Now().Add(ttl), int(ttl.Seconds())))
+}
+
+func ClearTokenCookies(w http.ResponseWriter, authConf *config.AuthConfig) {
+	for _, name := range []string{AccessTokenCookie, RefreshTokenCookie} {
+		http.SetCookie(w, newCookie(authConf, name, "", time.Unix(0, 0), -1))
+	}
+}
+
+func TokenFromCookie(req *http.Request, authConf *config.AuthConfig, name string) string {
+	cookie, err := req.Cookie(authConf.Cookie.NamePrefix + name)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func newCookie(authConf *config.AuthConfig, name, value string, expires time.Time, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     authConf.Cookie.NamePrefix + name,
+		Value:    value,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Domain:   authConf.Cookie.Domain,
+		Path:     "/",
+		Secure:   authConf.Cookie.Secure,
+		HttpOnly: true,
+		SameSite: authConf.Cookie.SameSite,
+	}
+}
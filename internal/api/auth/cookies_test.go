@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTokenCookie(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Cookie: config.CookieConfig{
+			Secure:     true,
+			SameSite:   http.SameSiteStrictMode,
+			NamePrefix: "__Host-",
+		},
+	}
+
+	w := httptest.NewRecorder()
+	SetTokenCookie(w, authConfig, AccessTokenCookie, "token", 15*time.Minute)
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "__Host-access_token", cookies[0].Name)
+	assert.Equal(t, "token", cookies[0].Value)
+	assert.Equal(t, "/", cookies[0].Path)
+	assert.Equal(t, 900, cookies[0].MaxAge)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.Empty(t, cookies[0].Domain)
+}
+
+func TestClearTokenCookies(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Cookie: config.CookieConfig{Secure: true, SameSite: http.SameSiteLaxMode, Domain: "example.com"},
+	}
+
+	w := httptest.NewRecorder()
+	ClearTokenCookies(w, authConfig)
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	for _, cookie := range cookies {
+		assert.Empty(t, cookie.Value)
+		assert.Equal(t, -1, cookie.MaxAge)
+		assert.Equal(t, "example.com", cookie.Domain)
+		assert.True(t, cookie.Secure)
+	}
+}
+
+func TestTokenFromCookie(t *testing.T) {
+	authConfig := &config.AuthConfig{Cookie: config.CookieConfig{NamePrefix: "__Secure-"}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "unprefixed"})
+	req.AddCookie(&http.Cookie{Name: "__Secure-refresh_token", Value: "prefixed"})
+
+	assert.Equal(t, "prefixed", TokenFromCookie(req, authConfig, RefreshTokenCookie))
+	assert.Empty(t, TokenFromCookie(req, authConfig, AccessTokenCookie))
+}
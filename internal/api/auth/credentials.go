@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+var commonPasswords = sync.OnceValue(func() map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordsList))
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			passwords[strings.ToLower(password)] = struct{}{}
+		}
+	}
+
+	return passwords
+})
+
+var passwordClassCheckers = map[string]func(rune) bool{
+	config.PasswordClassLower: unicode.IsLower,
+	config.PasswordClassUpper: unicode.IsUpper,
+	config.PasswordClassDigit: unicode.IsDigit,
+	config.PasswordClassSymbol: func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	},
+}
+
+func NormalizeLogin(login string, policy config.CredentialsPolicy) string {
+	login = strings.TrimSpace(login)
+	if policy.LoginCaseFold {
+		login = strings.ToLower(login)
+	}
+
+	return login
+}
+
+func ValidateCredentials(login, password string, policy config.CredentialsPolicy) []domain.ValidationViolation {
+	var violations []domain.ValidationViolation
+
+	maxLoginLength := domain.MaxLoginLength
+	if policy.LoginMaxLength > 0 {
+		maxLoginLength = min(policy.LoginMaxLength, domain.MaxLoginLength)
+	}
+
+	loginLength := utf8.RuneCountInString(login)
+	if loginLength < policy.LoginMinLength {
+		violations = append(violations, domain.ValidationViolation{
+			Field:   "login",
+			Rule:    "min_length",
+			Message: fmt.Sprintf("login must be at least %d characters long", policy.LoginMinLength),
+		})
+	}
+	if loginLength > maxLoginLength {
+		violations = append(violations, domain.ValidationViolation{
+			Field:   "login",
+			Rule:    "max_length",
+			Message: fmt.Sprintf("login must be at most %d characters long", maxLoginLength),
+		})
+	}
+	if policy.LoginPattern != nil && !policy.LoginPattern.MatchString(login) {
+		violations = append(violations, domain.ValidationViolation{
+			Field:   "login",
+			Rule:    "allowed_characters",
+			Message: "login contains characters that are not allowed",
+		})
+	}
+
+	return append(violations, ValidatePassword(password, policy)...)
+}
+
+func ValidatePassword(password string, policy config.CredentialsPolicy) []domain.ValidationViolation {
+	var violations []domain.ValidationViolation
+
+	if utf8.RuneCountInString(password) < policy.PasswordMinLength {
+		violations = append(violations, domain.ValidationViolation{
+			Field:   "password",
+			Rule:    "min_length",
+			Message: fmt.Sprintf("password must be at least %d characters long", policy.PasswordMinLength),
+		})
+	}
+	maxPasswordBytes := config.BcryptMaxPasswordBytes
+	if policy.PasswordMaxBytes > 0 {
+		maxPasswordBytes = policy.PasswordMaxBytes
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, domain.ValidationViolation{
+			Field:   "password",
+			Rule:    "max_length",
+			Message: fmt.Sprintf("password must be at most %d bytes long", maxPasswordBytes),
+		})
+	}
+	for _, class := range policy.PasswordRequiredClasses {
+		if isInClass, ok := passwordClassCheckers[class]; ok && !strings.ContainsFunc(password, isInClass) {
+			violations = append(violations, domain.ValidationViolation{
+				Field:   "password",
+				Rule:    "character_class_" + class,
+				Message: fmt.Sprintf("password must contain at least one %s character", class),
+			})
+		}
+	}
+	if policy.RejectCommonPasswords {
+		if _, ok := commonPasswords()[strings.ToLower(password)]; ok {
+			violations = append(violations, domain.ValidationViolation{
+				Field:   "password",
+				Rule:    "common_password",
+				Message: "password is too common",
+			})
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCredentials(t *testing.T) {
+	policy := config.CredentialsPolicy{
+		PasswordMinLength:       8,
+		PasswordRequiredClasses: []string{config.PasswordClassUpper, config.PasswordClassDigit},
+		RejectCommonPasswords:   true,
+		LoginMinLength:          3,
+		LoginMaxLength:          50,
+		LoginPattern:            regexp.MustCompile(`^[a-z0-9_]+$`),
+	}
+
+	tests := []struct {
+		name          string
+		login         string
+		password      string
+		expectedRules []string
+	}{
+		{
+			name:     "Valid credentials",
+			login:    "gopher_1",
+			password: "Tr0ub4dor&3",
+		},
+		{
+			name:          "Short login and password",
+			login:         "go",
+			password:      "Ab1",
+			expectedRules: []string{"login:min_length", "password:min_length"},
+		},
+		{
+			name:          "Login too long for the column",
+			login:         strings.Repeat("a", 51),
+			password:      "Tr0ub4dor&3",
+			expectedRules: []string{"login:max_length"},
+		},
+		{
+			name:     "Password at bcrypt limit",
+			login:    "gopher",
+			password: "Tr0ub4dor&3" + strings.Repeat("x", 61),
+		},
+		{
+			name:          "Password over bcrypt limit",
+			login:         "gopher",
+			password:      "Tr0ub4dor&3" + strings.Repeat("x", 62),
+			expectedRules: []string{"password:max_length"},
+		},
+		{
+			name:          "Login with disallowed characters",
+			login:         "gopher mart",
+			password:      "Tr0ub4dor&3",
+			expectedRules: []string{"login:allowed_characters"},
+		},
+		{
+			name:          "Missing character classes",
+			login:         "gopher",
+			password:      "correcthorse",
+			expectedRules: []string{"password:character_class_upper", "password:character_class_digit"},
+		},
+		{
+			name:          "Common password",
+			login:         "gopher",
+			password:      "Password123",
+			expectedRules: []string{"password:common_password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, violation := range ValidateCredentials(tt.login, tt.password, policy) {
+				rules = append(rules, violation.Field+":"+violation.Rule)
+			}
+
+			assert.Equal(t, tt.expectedRules, rules)
+		})
+	}
+}
+
+func TestValidateCredentials_EnforcesColumnLimitWithoutPolicy(t *testing.T) {
+	violations := ValidateCredentials(strings.Repeat("a", 51), "password", config.CredentialsPolicy{})
+
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "max_length", violations[0].Rule)
+}
+
+func TestValidatePassword_MaxLengthFollowsHasher(t *testing.T) {
+	password := "Tr0ub4dor&3" + strings.Repeat("x", 62)
+
+	bcryptPolicy := config.CredentialsPolicy{
+		PasswordMaxBytes: config.PasswordHashingPolicy{Algorithm: config.PasswordHashBcrypt}.MaxPasswordBytes(),
+	}
+	argon2idPolicy := config.CredentialsPolicy{
+		PasswordMaxBytes: config.PasswordHashingPolicy{Algorithm: config.PasswordHashArgon2id}.MaxPasswordBytes(),
+	}
+
+	assert.Len(t, ValidatePassword(password, bcryptPolicy), 1)
+	assert.Empty(t, ValidatePassword(password, argon2idPolicy))
+	assert.Len(t, ValidatePassword(password, config.CredentialsPolicy{}), 1)
+}
+
+func TestNormalizeLogin(t *testing.T) {
+	assert.Equal(t, "Gopher", NormalizeLogin(" Gopher ", config.CredentialsPolicy{}))
+	assert.Equal(t, "gopher", NormalizeLogin("Gopher", config.CredentialsPolicy{LoginCaseFold: true}))
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encodedHash, password string) (bool, error)
+	NeedsRehash(encodedHash string) bool
+}
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2SaltLen   = 16
+	argon2KeyLen    = 32
+	argon2PHCFields = 6
+)
+
+var (
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+	ErrMalformedHash     = errors.New("malformed password hash")
+)
+
+func NewPasswordHasher(policy config.PasswordHashingPolicy) PasswordHasher {
+	bcryptHasher := &BcryptHasher{Cost: policy.BcryptCost}
+	argon2idHasher := &Argon2idHasher{
+		Time:        policy.Argon2Time,
+		MemoryKiB:   policy.Argon2MemoryKiB,
+		Parallelism: policy.Argon2Parallelism,
+	}
+
+	preferred := PasswordHasher(bcryptHasher)
+	if policy.Algorithm == config.PasswordHashArgon2id {
+		preferred = argon2idHasher
+	}
+
+	return &policyHasher{
+		preferred: preferred,
+		bcrypt:    bcryptHasher,
+		argon2id:  argon2idHasher,
+	}
+}
+
+type policyHasher struct {
+	preferred PasswordHasher
+	bcrypt    *BcryptHasher
+	argon2id  *Argon2idHasher
+}
+
+func (ph *policyHasher) Hash(password string) (string, error) {
+	return ph.preferred.Hash(password)
+}
+
+func (ph *policyHasher) Verify(encodedHash, password string) (bool, error) {
+	hasher, err := ph.hasherFor(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	return hasher.Verify(encodedHash, password)
+}
+
+func (ph *policyHasher) NeedsRehash(encodedHash string) bool {
+	hasher, err := ph.hasherFor(encodedHash)
+	if err != nil || hasher != ph.preferred {
+		return true
+	}
+
+	return hasher.NeedsRehash(encodedHash)
+}
+
+func (ph *policyHasher) hasherFor(encodedHash string) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(encodedHash, argon2idPrefix):
+		return ph.argon2id, nil
+	case isBcryptHash(encodedHash):
+		return ph.bcrypt, nil
+	default:
+		return nil, ErrUnknownHashFormat
+	}
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (bh *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bh.Cost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func (bh *BcryptHasher) Verify(encodedHash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+
+	return true, nil
+}
+
+func (bh *BcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost < bh.Cost
+}
+
+func isBcryptHash(encodedHash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encodedHash, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Argon2idHasher struct {
+	Time        uint32
+	MemoryKiB   uint32
+	Parallelism uint8
+}
+
+type argon2idHash struct {
+	version     int
+	time        uint32
+	memoryKiB   uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (ah *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, ah.Time, ah.MemoryKiB, ah.Parallelism, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, ah.MemoryKiB, ah.Time, ah.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (ah *Argon2idHasher) Verify(encodedHash, password string) (bool, error) {
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey(
+		[]byte(password), decoded.salt, decoded.time, decoded.memoryKiB, decoded.parallelism, uint32(len(decoded.key)),
+	)
+
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+}
+
+func (ah *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return decoded.time < ah.Time ||
+		decoded.memoryKiB < ah.MemoryKiB ||
+		decoded.parallelism < ah.Parallelism ||
+		len(decoded.key) < argon2KeyLen
+}
+
+func decodeArgon2idHash(encodedHash string) (*argon2idHash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != argon2PHCFields || parts[1] != "argon2id" {
+		return nil, ErrMalformedHash
+	}
+
+	var decoded argon2idHash
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &decoded.version); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if decoded.version != argon2.Version {
+		return nil, fmt.Errorf("%w: unsupported argon2 version %d", ErrMalformedHash, decoded.version)
+	}
+
+	if _, err := fmt.Sscanf(
+		parts[3], "m=%d,t=%d,p=%d", &decoded.memoryKiB, &decoded.time, &decoded.parallelism,
+	); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if decoded.time == 0 || decoded.parallelism == 0 {
+		return nil, fmt.Errorf("%w: invalid argon2 parameters", ErrMalformedHash)
+	}
+
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if len(decoded.key) == 0 {
+		return nil, fmt.Errorf("%w: empty argon2 key", ErrMalformedHash)
+	}
+
+	return &decoded, nil
+}
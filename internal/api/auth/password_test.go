@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testArgon2idPolicy() config.PasswordHashingPolicy {
+	return config.PasswordHashingPolicy{
+		Algorithm:         config.PasswordHashArgon2id,
+		BcryptCost:        bcrypt.MinCost,
+		Argon2Time:        1,
+		Argon2MemoryKiB:   64,
+		Argon2Parallelism: 1,
+	}
+}
+
+func TestPasswordHasher_HashAndVerify(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       config.PasswordHashingPolicy
+		expectedHead string
+	}{
+		{
+			name:         "bcrypt",
+			policy:       config.PasswordHashingPolicy{Algorithm: config.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost},
+			expectedHead: "$2a$04$",
+		},
+		{
+			name:         "argon2id",
+			policy:       testArgon2idPolicy(),
+			expectedHead: "$argon2id$v=19$m=64,t=1,p=1$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := NewPasswordHasher(tt.policy)
+
+			hash, err := hasher.Hash("Tr0ub4dor&3")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.expectedHead), hash)
+
+			valid, err := hasher.Verify(hash, "Tr0ub4dor&3")
+			require.NoError(t, err)
+			assert.True(t, valid)
+
+			valid, err = hasher.Verify(hash, "wrong")
+			require.NoError(t, err)
+			assert.False(t, valid)
+
+			assert.False(t, hasher.NeedsRehash(hash))
+		})
+	}
+}
+
+func TestPasswordHasher_VerifiesEitherAlgorithm(t *testing.T) {
+	bcryptHash, err := (&BcryptHasher{Cost: bcrypt.MinCost}).Hash("secret")
+	require.NoError(t, err)
+	argon2idHash, err := NewPasswordHasher(testArgon2idPolicy()).Hash("secret")
+	require.NoError(t, err)
+
+	hasher := NewPasswordHasher(config.PasswordHashingPolicy{Algorithm: config.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost})
+
+	for _, hash := range []string{bcryptHash, argon2idHash} {
+		valid, err := hasher.Verify(hash, "secret")
+		require.NoError(t, err)
+		assert.True(t, valid)
+	}
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	weakBcrypt, err := (&BcryptHasher{Cost: bcrypt.MinCost}).Hash("secret")
+	require.NoError(t, err)
+	weakArgon2id, err := (&Argon2idHasher{Time: 1, MemoryKiB: 32, Parallelism: 1}).Hash("secret")
+	require.NoError(t, err)
+
+	strongerBcrypt := NewPasswordHasher(config.PasswordHashingPolicy{
+		Algorithm:  config.PasswordHashBcrypt,
+		BcryptCost: bcrypt.MinCost + 1,
+	})
+	argon2id := NewPasswordHasher(testArgon2idPolicy())
+
+	assert.True(t, strongerBcrypt.NeedsRehash(weakBcrypt), "lower bcrypt cost")
+	assert.True(t, strongerBcrypt.NeedsRehash(weakArgon2id), "algorithm switched back to bcrypt")
+	assert.True(t, argon2id.NeedsRehash(weakBcrypt), "algorithm switched to argon2id")
+	assert.True(t, argon2id.NeedsRehash(weakArgon2id), "lower argon2 memory")
+	assert.True(t, argon2id.NeedsRehash("plaintext"), "unknown format")
+}
+
+func TestPasswordHasher_RejectsMalformedHashes(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2idPolicy())
+
+	tests := []struct {
+		name        string
+		hash        string
+		expectedErr error
+	}{
+		{name: "Unknown format", hash: "plaintext", expectedErr: ErrUnknownHashFormat},
+		{name: "Empty hash", hash: "", expectedErr: ErrUnknownHashFormat},
+		{name: "Truncated argon2id", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA", expectedErr: ErrMalformedHash},
+		{name: "Unsupported version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Zero iterations", hash: "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Bad key encoding", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!!!", expectedErr: ErrMalformedHash},
+		{name: "Truncated bcrypt", hash: "$2a$04$short", expectedErr: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := hasher.Verify(tt.hash, "secret")
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.False(t, valid)
+		})
+	}
+}
@@ -2,11 +2,15 @@ package controller
 
 import (
 	"fmt"
+	"net/http"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
 	"github.com/frolmr/gophermart/internal/api/handlers"
 	mw "github.com/frolmr/gophermart/internal/api/middleware"
+	"github.com/frolmr/gophermart/internal/api/problem"
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/events"
 	"github.com/frolmr/gophermart/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,10 +19,11 @@ import (
 
 type Controller struct {
 	Storage    *storage.Storage
+	Events     handlers.EventsSubscriber
 	AuthConfig *config.AuthConfig
 }
 
-func NewController(stor *storage.Storage) (*Controller, error) {
+func NewController(stor *storage.Storage, events handlers.EventsSubscriber) (*Controller, error) {
 	authCfg, err := config.NewAuthConfig()
 	if err != nil {
 		return nil, fmt.Errorf("error constructing controller: %w", err)
@@ -26,6 +31,7 @@ func NewController(stor *storage.Storage) (*Controller, error) {
 
 	return &Controller{
 		Storage:    stor,
+		Events:     events,
 		AuthConfig: authCfg,
 	}, nil
 }
@@ -36,28 +42,59 @@ func (c *Controller) SetupRouter(lgr *zap.SugaredLogger) chi.Router {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	rh := handlers.NewRequestHandlers(lgr, c.Storage)
+	r.NotFound(func(w http.ResponseWriter, req *http.Request) {
+		problem.Render(w, req, problem.New(http.StatusNotFound, problem.CodeNotFound, "Resource not found"))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		problem.Render(w, req, problem.New(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method not allowed"))
+	})
+
+	rh := handlers.NewRequestHandlers(
+		lgr, c.Storage, auth.NewPasswordHasher(c.AuthConfig.PasswordHashing), c.Events, events.NewNotifier(lgr, c.Storage),
+	)
+	withAuth := mw.WithAuth(c.AuthConfig, c.Storage)
+
+	r.Get("/.well-known/jwks.json", handlers.JWKS(c.AuthConfig))
 
 	r.Route("/api/user/", func(r chi.Router) {
-		r.Use(middleware.AllowContentType(domain.JSONContentType))
+		r.Use(mw.AllowContentType(domain.JSONContentType))
 		r.Post("/register", rh.UsersHandler.RegisterUser(c.AuthConfig))
 		r.Post("/login", rh.UsersHandler.LoginUser(c.AuthConfig))
 		r.Post("/refresh", rh.UsersHandler.RefreshToken(c.AuthConfig))
+		r.Post("/logout", rh.UsersHandler.LogoutUser(c.AuthConfig))
+		r.With(withAuth).Post("/logout-all", rh.UsersHandler.LogoutAllSessions(c.AuthConfig))
+		r.With(withAuth).Post("/password", rh.UsersHandler.ChangePassword(c.AuthConfig))
 	})
 
+	r.With(withAuth).Delete("/api/user", rh.UsersHandler.DeleteUser(c.AuthConfig))
+
 	r.Route("/api/user/orders", func(r chi.Router) {
-		r.Use(mw.WithAuth(c.AuthConfig))
+		r.Use(withAuth)
 		r.Post("/", rh.OrdersHandler.LoadOrder)
+		r.With(mw.AllowContentType(domain.JSONContentType, domain.TextContentType)).Post("/batch", rh.OrdersHandler.LoadOrdersBatch)
 		r.Get("/", rh.OrdersHandler.GetOrders)
+		r.Get("/{number}", rh.OrdersHandler.GetOrder)
 	})
 
 	r.Route("/api/user/balance", func(r chi.Router) {
-		r.Use(mw.WithAuth(c.AuthConfig))
+		r.Use(withAuth)
 		r.Get("/", rh.BalancesHandler.GetBalance)
 		r.Post("/withdraw", rh.WithdrawalsHandler.RegisterWithdrawal)
 	})
 
-	r.With(mw.WithAuth(c.AuthConfig)).Get("/api/user/withdrawals", rh.WithdrawalsHandler.GetWithdrawals)
+	r.With(withAuth).Get("/api/user/withdrawals", rh.WithdrawalsHandler.GetWithdrawals)
+	r.With(withAuth).Get("/api/user/events", rh.EventsHandler.StreamEvents)
+
+	if c.AuthConfig.AdminToken != "" {
+		r.Route("/api/admin", func(r chi.Router) {
+			r.Use(mw.WithAdminToken(c.AuthConfig))
+			r.Delete("/login-lockouts", rh.AdminHandler.ClearLoginLockout(c.AuthConfig))
+			r.With(mw.AllowContentType(domain.JSONContentType)).Post("/webhooks", rh.WebhooksHandler.CreateSubscription)
+			r.Get("/webhooks", rh.WebhooksHandler.GetSubscriptions)
+			r.Delete("/webhooks/{id}", rh.WebhooksHandler.DeleteSubscription)
+			r.Get("/webhooks/{id}/deliveries", rh.WebhooksHandler.GetDeliveryLog)
+		})
+	}
 
 	return r
 }
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+	"go.uber.org/zap"
+)
+
+type LoginAttemptsRepository interface {
+	ClearLoginAttempts(key domain.LoginAttemptKey) error
+}
+
+type AdminHandler struct {
+	logger *zap.SugaredLogger
+	repo   LoginAttemptsRepository
+}
+
+func NewAdminHandler(lgr *zap.SugaredLogger, repo LoginAttemptsRepository) *AdminHandler {
+	return &AdminHandler{
+		logger: lgr,
+		repo:   repo,
+	}
+}
+
+func (ah *AdminHandler) ClearLoginLockout(authConfig *config.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var keys []domain.LoginAttemptKey
+		if login := auth.NormalizeLogin(req.URL.Query().Get("login"), authConfig.Credentials); login != "" {
+			keys = append(keys, domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeLogin, Value: login})
+		}
+		if ip := strings.TrimSpace(req.URL.Query().Get("ip")); ip != "" {
+			keys = append(keys, domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeIP, Value: ip})
+		}
+
+		if len(keys) == 0 {
+			problem.Render(w, req, problem.BadRequest("Login or ip is required"))
+			return
+		}
+
+		for _, key := range keys {
+			if err := ah.repo.ClearLoginAttempts(key); err != nil {
+				problem.Render(w, req, problem.Internal("Failed to clear lockout"))
+				return
+			}
+			ah.logger.Infof("Login lockout cleared for %s %s", key.Scope, key.Value)
+		}
+
+		_, _ = w.Write([]byte("Lockout cleared"))
+	}
+}
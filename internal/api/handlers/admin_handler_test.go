@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestAdminHandler_ClearLoginLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockLoginAttemptsRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewAdminHandler(logger, mockRepo)
+	authConfig := &config.AuthConfig{Credentials: config.CredentialsPolicy{LoginCaseFold: true}}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Clear login and ip",
+			query: "?login=testuser&ip=192.0.2.1",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					ClearLoginAttempts(domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeLogin, Value: "testuser"}).
+					Return(nil)
+				mockRepo.EXPECT().
+					ClearLoginAttempts(domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeIP, Value: "192.0.2.1"}).
+					Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Lockout cleared",
+		},
+		{
+			name:  "Login is normalized",
+			query: "?login=%20TestUser%20",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					ClearLoginAttempts(domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeLogin, Value: "testuser"}).
+					Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Lockout cleared",
+		},
+		{
+			name:           "Nothing to clear",
+			query:          "",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Login or ip is required",
+		},
+		{
+			name:  "Database error",
+			query: "?login=testuser",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					ClearLoginAttempts(gomock.Any()).
+					Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to clear lockout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/admin/login-lockouts"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.ClearLoginLockout(authConfig).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/internal/mocks"
 	"github.com/stretchr/testify/assert"
@@ -22,58 +23,40 @@ func TestBalancesHandler_GetBalance(t *testing.T) {
 
 	tests := []struct {
 		name           string
-		userID         string
+		userID         int64
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name:   "Successful balance retrieval",
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetUserCurrentBalance(int64(1)).
-					Return(100.5, nil)
-
-				mockRepo.EXPECT().
-					GetUserWithdrawalsSum(int64(1)).
-					Return(50.25, nil)
+					GetUserBalance(int64(1)).
+					Return(&domain.Balance{BalanceSum: 100.5, WithdrawalSum: 50.25}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"current":100.5,"withdrawn":50.25}`,
 		},
 		{
-			name:   "Failed to get user current balance",
-			userID: "1",
-			mockSetup: func() {
-				mockRepo.EXPECT().
-					GetUserCurrentBalance(int64(1)).
-					Return(0.0, assert.AnError)
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Failed to get user accrual sum"}`,
-		},
-		{
-			name:   "Failed to get user withdrawals sum",
-			userID: "1",
+			name:   "Failed to get user balance",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetUserCurrentBalance(int64(1)).
-					Return(100.5, nil)
-
-				mockRepo.EXPECT().
-					GetUserWithdrawalsSum(int64(1)).
-					Return(0.0, assert.AnError)
+					GetUserBalance(int64(1)).
+					Return(nil, assert.AnError)
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Failed to get user withdrawal sum"}`,
+			expectedBody: `{"type":"about:blank","title":"Internal Server Error","status":500,` +
+				`"detail":"Failed to get user balance","instance":"/balance","code":"internal_error"}`,
 		},
 		{
-			name:           "Invalid user ID",
-			userID:         "invalid",
+			name:           "Missing user in context",
 			mockSetup:      func() {},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Invalid user id"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody: `{"type":"about:blank","title":"Unauthorized","status":401,` +
+				`"detail":"Unauthorized","instance":"/balance","code":"unauthorized"}`,
 		},
 	}
 
@@ -82,7 +65,9 @@ func TestBalancesHandler_GetBalance(t *testing.T) {
 			tt.mockSetup()
 
 			req := httptest.NewRequest(http.MethodGet, "/balance", nil)
-			req.Header.Set(domain.UserIDHeader, tt.userID)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
 			w := httptest.NewRecorder()
 
 			handler.GetBalance(w, req)
@@ -94,3 +79,18 @@ func TestBalancesHandler_GetBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestBalancesHandler_GetBalance_IgnoresUserIDHeader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewBalancesHandler(zap.NewNop().Sugar(), mocks.NewMockBalanceRepository(ctrl))
+
+	req := httptest.NewRequest(http.MethodGet, "/balance", nil)
+	req.Header.Set("X-User-ID", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetBalance(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
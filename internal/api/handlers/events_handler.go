@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	eventsHeartbeatInterval = 15 * time.Second
+)
+
+type EventsSubscriber interface {
+	Subscribe(userID int64) (<-chan *domain.Event, func())
+}
+
+type EventsHandler struct {
+	logger            *zap.SugaredLogger
+	subscriber        EventsSubscriber
+	heartbeatInterval time.Duration
+}
+
+func NewEventsHandler(lgr *zap.SugaredLogger, subscriber EventsSubscriber) *EventsHandler {
+	return &EventsHandler{
+		logger:            lgr,
+		subscriber:        subscriber,
+		heartbeatInterval: eventsHeartbeatInterval,
+	}
+}
+
+func (eh *EventsHandler) StreamEvents(w http.ResponseWriter, req *http.Request) {
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return
+	}
+
+	events, unsubscribe := eh.subscriber.Subscribe(userID)
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the server write timeout by design
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", domain.EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		eh.logger.Errorf("Events stream: flushing is not supported, err: %s", err.Error())
+		return
+	}
+
+	heartbeat := time.NewTicker(eh.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-req.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			eh.logger.Debugf("Events stream for user_id: %d closed, err: %s", userID, err.Error())
+			return
+		}
+	}
+}
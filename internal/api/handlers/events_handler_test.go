@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestEventsHandler_StreamEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSubscriber := mocks.NewMockEventsSubscriber(ctrl)
+	handler := NewEventsHandler(zap.NewNop().Sugar(), mockSubscriber)
+
+	events := make(chan *domain.Event, 2)
+	unsubscribed := false
+	mockSubscriber.EXPECT().
+		Subscribe(int64(1)).
+		Return((<-chan *domain.Event)(events), func() { unsubscribed = true })
+
+	events <- &domain.Event{
+		Type:   domain.EventOrderStatus,
+		UserID: 1,
+		Data:   json.RawMessage(`{"number":"12345678903","status":"PROCESSED","accrual":500}`),
+	}
+	events <- &domain.Event{Type: domain.EventBalance, UserID: 1, Data: json.RawMessage(`{"current":500,"withdrawn":0}`)}
+	close(events)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/events", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.StreamEvents(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, domain.EventStreamContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, ": connected\n\n"+
+		"event: order_status\ndata: {\"number\":\"12345678903\",\"status\":\"PROCESSED\",\"accrual\":500}\n\n"+
+		"event: balance\ndata: {\"current\":500,\"withdrawn\":0}\n\n", w.Body.String())
+	assert.True(t, w.Flushed)
+	assert.True(t, unsubscribed)
+}
+
+func TestEventsHandler_StreamEvents_HeartbeatUntilClientLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSubscriber := mocks.NewMockEventsSubscriber(ctrl)
+	handler := NewEventsHandler(zap.NewNop().Sugar(), mockSubscriber)
+	handler.heartbeatInterval = 10 * time.Millisecond
+
+	mockSubscriber.EXPECT().
+		Subscribe(int64(1)).
+		Return(make(<-chan *domain.Event), func() {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/events", nil)
+	req = req.WithContext(auth.WithUserID(ctx, 1))
+	w := httptest.NewRecorder()
+
+	handler.StreamEvents(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), ": connected\n\n: ping\n\n")
+}
+
+func TestEventsHandler_StreamEvents_Unauthorized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewEventsHandler(zap.NewNop().Sugar(), mocks.NewMockEventsSubscriber(ctrl))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/events", nil)
+	w := httptest.NewRecorder()
+
+	handler.StreamEvents(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
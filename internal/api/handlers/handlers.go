@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"github.com/frolmr/gophermart/internal/api/auth"
 	"github.com/frolmr/gophermart/internal/storage"
 	"go.uber.org/zap"
 )
@@ -10,13 +11,25 @@ type RequestHandlers struct {
 	OrdersHandler      *OrdersHandler
 	WithdrawalsHandler *WithdrawalsHandler
 	BalancesHandler    *BalancesHandler
+	AdminHandler       *AdminHandler
+	EventsHandler      *EventsHandler
+	WebhooksHandler    *WebhooksHandler
 }
 
-func NewRequestHandlers(lgr *zap.SugaredLogger, stor *storage.Storage) *RequestHandlers {
+func NewRequestHandlers(
+	lgr *zap.SugaredLogger,
+	stor *storage.Storage,
+	hasher auth.PasswordHasher,
+	subscriber EventsSubscriber,
+	notifier BalanceEventNotifier,
+) *RequestHandlers {
 	return &RequestHandlers{
-		UsersHandler:       NewUsersHandler(lgr, stor),
+		UsersHandler:       NewUsersHandler(lgr, stor, hasher),
 		OrdersHandler:      NewOrdersHandler(lgr, stor),
-		WithdrawalsHandler: NewWithdrawalsHandler(lgr, stor),
+		WithdrawalsHandler: NewWithdrawalsHandler(lgr, stor, notifier),
 		BalancesHandler:    NewBalancesHandler(lgr, stor),
+		AdminHandler:       NewAdminHandler(lgr, stor),
+		EventsHandler:      NewEventsHandler(lgr, subscriber),
+		WebhooksHandler:    NewWebhooksHandler(lgr, stor),
 	}
 }
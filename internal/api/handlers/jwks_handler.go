@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+func JWKS(authConfig *config.AuthConfig) http.HandlerFunc {
+	keySet := auth.BuildJWKSet(authConfig)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", domain.JSONContentType)
+		w.Header().Set("Cache-Control", "public, max-age=300")
+
+		if err := json.NewEncoder(w).Encode(keySet); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to encode response"))
+		}
+	}
+}
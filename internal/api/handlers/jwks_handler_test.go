@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKS(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	authConfig := &config.AuthConfig{
+		JWTVerificationKeys: []*config.VerificationKey{{ID: "key-1", PublicKey: publicKey}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+
+	JWKS(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, domain.JSONContentType, w.Header().Get("Content-Type"))
+
+	var set auth.JWKSet
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&set))
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "key-1", set.Keys[0].KeyID)
+}
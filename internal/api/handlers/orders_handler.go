@@ -2,23 +2,31 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
 	"github.com/frolmr/gophermart/internal/domain"
-	"github.com/frolmr/gophermart/pkg/formatter"
 	"github.com/frolmr/gophermart/pkg/luhn"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
 const (
-	reqBodySizeLimit = int64(10 << 20) // 10 MB
+	reqBodySizeLimit   = int64(10 << 20) // 10 MB
+	maxOrdersBatchSize = 1000
 )
 
 type OrdersRepository interface {
-	FindOrderByNumber(number string) (*domain.DBOrder, error)
-	GetAllUserOrders(userID int64) ([]*domain.Order, error)
-	CreateOrder(number string, userID int64) error
+	GetUserOrders(filter domain.OrderFilter) (*domain.OrderPage, error)
+	GetUserOrderDetails(userID int64, number string) (*domain.OrderDetails, error)
+	CreateOrder(number string, userID int64) (ownerID int64, created bool, err error)
+	CreateOrders(numbers []string, userID int64) ([]*domain.OrderUploadResult, error)
 }
 
 type OrdersHandler struct {
@@ -38,67 +46,210 @@ func (oh *OrdersHandler) LoadOrder(w http.ResponseWriter, req *http.Request) {
 
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		http.Error(w, "Wrong request format", http.StatusBadRequest)
+		problem.Render(w, req, problem.BadRequest("Wrong request format"))
 		return
 	}
 
 	orderNumber := string(body)
 	if orderNumberValid := luhn.Check(orderNumber); !orderNumberValid {
-		http.Error(w, "Order number is invalid", http.StatusUnprocessableEntity)
+		problem.Render(w, req, problem.Wrap(domain.ErrInvalidOrderNumber, "Order number is invalid"))
 		return
 	}
 	defer req.Body.Close()
 
-	userID, err := formatter.StringToInt64(req.Header.Get(domain.UserIDHeader))
-	if err != nil {
-		http.Error(w, "Invalid user id", http.StatusInternalServerError)
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
 		return
 	}
 
-	existingOrder, err := oh.repo.FindOrderByNumber(orderNumber)
+	ownerID, created, err := oh.repo.CreateOrder(orderNumber, userID)
 	if err != nil {
 		oh.logger.Error("database error: ", err.Error())
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		problem.Render(w, req, problem.Internal("Failed to load order"))
 		return
 	}
-	if existingOrder != nil {
-		if existingOrder.UserID == userID {
-			w.WriteHeader(http.StatusOK)
-			return
-		} else {
-			http.Error(w, "Already downloaded", http.StatusConflict)
+
+	switch {
+	case created:
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("Order uploaded"))
+	case ownerID == userID:
+		w.WriteHeader(http.StatusOK)
+	default:
+		problem.Render(w, req, problem.Wrap(domain.ErrOrderUploadedByAnotherUser, "Already downloaded"))
+	}
+}
+
+func (oh *OrdersHandler) LoadOrdersBatch(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", domain.JSONContentType)
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, reqBodySizeLimit)
+	defer req.Body.Close()
+
+	numbers, err := parseOrdersBatch(req)
+	if err != nil {
+		problem.Render(w, req, problem.BadRequest(err.Error()))
+		return
+	}
+
+	valid := make([]string, 0, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	for _, number := range numbers {
+		if !seen[number] && luhn.Check(number) {
+			valid = append(valid, number)
+		}
+		seen[number] = true
+	}
+
+	storedResults := make(map[string]string, len(valid))
+	if len(valid) > 0 {
+		stored, err := oh.repo.CreateOrders(valid, userID)
+		if err != nil {
+			oh.logger.Error("database error: ", err.Error())
+			problem.Render(w, req, problem.Internal("Failed to load orders"))
 			return
 		}
+		for _, result := range stored {
+			storedResults[result.Number] = result.Result
+		}
 	}
 
-	if err := oh.repo.CreateOrder(orderNumber, userID); err != nil {
-		http.Error(w, "Failed to load order", http.StatusInternalServerError)
-		return
+	results := make([]*domain.OrderUploadResult, 0, len(numbers))
+	reported := make(map[string]bool, len(valid))
+	for _, number := range numbers {
+		result, ok := storedResults[number]
+		switch {
+		case !ok:
+			result = domain.OrderUploadInvalid
+		case reported[number]:
+			result = domain.OrderUploadDuplicate
+		}
+		reported[number] = true
+		results = append(results, &domain.OrderUploadResult{Number: number, Result: result})
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		problem.Render(w, req, problem.Internal("Failed to encode response"))
+	}
+}
+
+func parseOrdersBatch(req *http.Request) ([]string, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.New("wrong request format")
+	}
+
+	var numbers []string
+	mediaType, _, _ := strings.Cut(req.Header.Get("Content-Type"), ";")
+	if strings.EqualFold(strings.TrimSpace(mediaType), domain.JSONContentType) {
+		if err := json.Unmarshal(body, &numbers); err != nil {
+			return nil, errors.New("body must be a JSON array of order numbers")
+		}
+		for i := range numbers {
+			numbers[i] = strings.TrimSpace(numbers[i])
+		}
+	} else {
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				numbers = append(numbers, line)
+			}
+		}
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	_, _ = w.Write([]byte("Order uploaded"))
+	if len(numbers) == 0 || len(numbers) > maxOrdersBatchSize {
+		return nil, fmt.Errorf("batch must contain between 1 and %d order numbers", maxOrdersBatchSize)
+	}
+
+	return numbers, nil
 }
 
 //nolint:dupl // Actually code is not the same as in ordes_handler. United code will be more difficult to understand
 func (oh *OrdersHandler) GetOrders(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", domain.JSONContentType)
-	userID, err := formatter.StringToInt64(req.Header.Get(domain.UserIDHeader))
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return
+	}
+
+	filter, err := orderFilterFromQuery(req.URL.Query(), userID)
 	if err != nil {
-		http.Error(w, "Invalid user id", http.StatusInternalServerError)
+		problem.Render(w, req, problem.BadRequest(err.Error()))
 		return
 	}
 
-	orders, err := oh.repo.GetAllUserOrders(userID)
+	page, err := oh.repo.GetUserOrders(filter)
 	if err != nil {
-		http.Error(w, "Failed to load orders", http.StatusInternalServerError)
+		problem.Render(w, req, problem.Internal("Failed to load orders"))
 		return
 	}
-	if len(orders) > 0 {
-		if err := json.NewEncoder(w).Encode(orders); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	if len(page.Orders) > 0 {
+		setNextPageHeaders(w, req, page.NextCursor)
+		if err := json.NewEncoder(w).Encode(page.Orders); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to encode response"))
 		}
 	} else {
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+func (oh *OrdersHandler) GetOrder(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", domain.JSONContentType)
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return
+	}
+
+	orderNumber := chi.URLParam(req, "number")
+	if !luhn.Check(orderNumber) {
+		problem.Render(w, req, problem.Wrap(domain.ErrInvalidOrderNumber, "Order number is invalid"))
+		return
+	}
+
+	details, err := oh.repo.GetUserOrderDetails(userID, orderNumber)
+	if errors.Is(err, domain.ErrNotFound) {
+		problem.Render(w, req, problem.Wrap(err, "Order not found"))
+		return
+	}
+	if err != nil {
+		oh.logger.Error("database error: ", err.Error())
+		problem.Render(w, req, problem.Internal("Failed to load order"))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(details); err != nil {
+		problem.Render(w, req, problem.Internal("Failed to encode response"))
+	}
+}
+
+func orderFilterFromQuery(query url.Values, userID int64) (domain.OrderFilter, error) {
+	filter := domain.OrderFilter{UserID: userID}
+
+	var err error
+	if filter.Limit, err = parsePageLimit(query); err != nil {
+		return domain.OrderFilter{}, err
+	}
+	if filter.After, err = parseCursor(query); err != nil {
+		return domain.OrderFilter{}, err
+	}
+	if filter.Uploaded, err = parseTimeRange(query, "from", "to"); err != nil {
+		return domain.OrderFilter{}, err
+	}
+
+	for _, status := range splitQueryList(query, "status") {
+		status = strings.ToUpper(status)
+		if !domain.IsKnownOrderStatus(status) {
+			return domain.OrderFilter{}, fmt.Errorf("unknown order status %q", status)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	return filter, nil
+}
@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
@@ -25,7 +31,7 @@ func TestOrdersHandler_LoadOrder(t *testing.T) {
 	tests := []struct {
 		name           string
 		orderNumber    string
-		userID         string
+		userID         int64
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
@@ -33,15 +39,11 @@ func TestOrdersHandler_LoadOrder(t *testing.T) {
 		{
 			name:        "Successful order upload",
 			orderNumber: "12345678903",
-			userID:      "1",
+			userID:      1,
 			mockSetup: func() {
-				mockRepo.EXPECT().
-					FindOrderByNumber("12345678903").
-					Return(nil, nil)
-
 				mockRepo.EXPECT().
 					CreateOrder("12345678903", int64(1)).
-					Return(nil)
+					Return(int64(1), true, nil)
 			},
 			expectedStatus: http.StatusAccepted,
 			expectedBody:   "Order uploaded",
@@ -49,11 +51,11 @@ func TestOrdersHandler_LoadOrder(t *testing.T) {
 		{
 			name:        "Order already uploaded by the same user",
 			orderNumber: "12345678903",
-			userID:      "1",
+			userID:      1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					FindOrderByNumber("12345678903").
-					Return(&domain.DBOrder{UserID: 1}, nil)
+					CreateOrder("12345678903", int64(1)).
+					Return(int64(1), false, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
@@ -61,22 +63,34 @@ func TestOrdersHandler_LoadOrder(t *testing.T) {
 		{
 			name:        "Order already uploaded by another user",
 			orderNumber: "12345678903",
-			userID:      "2",
+			userID:      2,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					FindOrderByNumber("12345678903").
-					Return(&domain.DBOrder{UserID: 1}, nil)
+					CreateOrder("12345678903", int64(2)).
+					Return(int64(1), false, nil)
 			},
 			expectedStatus: http.StatusConflict,
-			expectedBody:   "Already downloaded",
+			expectedBody:   `"code":"order_already_uploaded"`,
+		},
+		{
+			name:        "Database error",
+			orderNumber: "12345678903",
+			userID:      1,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateOrder("12345678903", int64(1)).
+					Return(int64(0), false, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"code":"internal_error"`,
 		},
 		{
 			name:           "Invalid order number (Luhn check fails)",
 			orderNumber:    "12345678902",
-			userID:         "1",
+			userID:         1,
 			mockSetup:      func() {},
 			expectedStatus: http.StatusUnprocessableEntity,
-			expectedBody:   "Order number is invalid",
+			expectedBody:   `"code":"invalid_order_number"`,
 		},
 	}
 
@@ -85,7 +99,9 @@ func TestOrdersHandler_LoadOrder(t *testing.T) {
 			tt.mockSetup()
 
 			req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader([]byte(tt.orderNumber)))
-			req.Header.Set(domain.UserIDHeader, tt.userID)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
 			w := httptest.NewRecorder()
 
 			handler.LoadOrder(w, req)
@@ -108,43 +124,42 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 
 	tests := []struct {
 		name           string
-		userID         string
+		userID         int64
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name:   "Successful retrieval of orders",
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetAllUserOrders(int64(1)).
-					Return([]*domain.Order{
+					GetUserOrders(domain.OrderFilter{UserID: 1}).
+					Return(&domain.OrderPage{Orders: []*domain.Order{
 						{Number: "12345678903", Status: "PROCESSED", UploadedAt: time.Now()},
 						{Number: "98765432103", Status: "NEW", UploadedAt: time.Now()},
-					}, nil)
+					}}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `[{"number":"12345678903","status":"PROCESSED","uploaded_at":"`,
 		},
 		{
 			name:   "No orders found",
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetAllUserOrders(int64(1)).
-					Return([]*domain.Order{}, nil)
+					GetUserOrders(gomock.Any()).
+					Return(&domain.OrderPage{}, nil)
 			},
 			expectedStatus: http.StatusNoContent,
 			expectedBody:   "",
 		},
 		{
-			name:   "Invalid user ID",
-			userID: "invalid",
+			name: "Missing user in context",
 			mockSetup: func() {
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "Invalid user id",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
 		},
 	}
 
@@ -153,7 +168,9 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 			tt.mockSetup()
 
 			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
-			req.Header.Set(domain.UserIDHeader, tt.userID)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
 			w := httptest.NewRecorder()
 
 			handler.GetOrders(w, req)
@@ -165,3 +182,374 @@ func TestOrdersHandler_GetOrders(t *testing.T) {
 		})
 	}
 }
+
+func TestOrdersHandler_GetOrders_Pagination(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	handler := NewOrdersHandler(zap.NewNop().Sugar(), mockRepo)
+
+	cursor := &domain.Cursor{Time: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC), ID: 42}
+	next := &domain.Cursor{Time: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), ID: 17}
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.EXPECT().
+		GetUserOrders(domain.OrderFilter{
+			UserID:   1,
+			Statuses: []string{domain.OrderStatusNew, domain.OrderStatusProcessed},
+			Uploaded: domain.TimeRange{From: &from, To: &to},
+			Limit:    2,
+			After:    cursor,
+		}).
+		Return(&domain.OrderPage{
+			Orders:     []*domain.Order{{Number: "12345678903", Status: "NEW", UploadedAt: time.Now()}},
+			NextCursor: next,
+		}, nil)
+
+	query := url.Values{
+		"limit":  {"2"},
+		"cursor": {cursor.Encode()},
+		"status": {"new,PROCESSED"},
+		"from":   {"2024-05-01"},
+		"to":     {"2024-05-31"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders?"+query.Encode(), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.GetOrders(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, next.Encode(), w.Header().Get(domain.NextCursorHeader))
+
+	query.Set("cursor", next.Encode())
+	assert.Equal(t, `</api/user/orders?`+query.Encode()+`>; rel="next"`, w.Header().Get("Link"))
+}
+
+func TestOrdersHandler_GetOrders_CursorWithoutLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	handler := NewOrdersHandler(zap.NewNop().Sugar(), mockRepo)
+
+	cursor := &domain.Cursor{Time: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC), ID: 42}
+	mockRepo.EXPECT().
+		GetUserOrders(domain.OrderFilter{UserID: 1, Limit: domain.DefaultPageLimit, After: cursor}).
+		Return(&domain.OrderPage{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders?cursor="+cursor.Encode(), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.GetOrders(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestOrdersHandler_GetOrders_InvalidQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewOrdersHandler(zap.NewNop().Sugar(), mocks.NewMockOrdersRepository(ctrl))
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedBody string
+	}{
+		{name: "Limit too large", query: "limit=100000", expectedBody: "limit must be between 1 and"},
+		{name: "Limit not a number", query: "limit=ten", expectedBody: "limit must be between 1 and"},
+		{name: "Malformed cursor", query: "cursor=%21%21", expectedBody: "cursor is malformed"},
+		{name: "Unknown status", query: "status=LOST", expectedBody: `unknown order status \"LOST\"`},
+		{name: "Malformed date", query: "from=yesterday", expectedBody: "from must be an RFC3339 timestamp"},
+		{name: "Empty range", query: "from=2024-06-01&to=2024-05-01", expectedBody: "from must be before to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders?"+tt.query, nil)
+			req = req.WithContext(auth.WithUserID(req.Context(), 1))
+			w := httptest.NewRecorder()
+
+			handler.GetOrders(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestOrdersHandler_GetOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	handler := NewOrdersHandler(zap.NewNop().Sugar(), mockRepo)
+
+	uploadedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	processedAt := uploadedAt.Add(time.Minute)
+	accrual := 500.0
+
+	tests := []struct {
+		name           string
+		userID         int64
+		number         string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Order with status timeline",
+			userID: 1,
+			number: "12345678903",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserOrderDetails(int64(1), "12345678903").
+					Return(&domain.OrderDetails{
+						Number:     "12345678903",
+						Status:     "PROCESSED",
+						Accrual:    &accrual,
+						UploadedAt: uploadedAt,
+						PollCount:  2,
+						History: []*domain.OrderStatusChange{
+							{Status: "NEW", ChangedAt: &uploadedAt},
+							{Status: "PROCESSED", ChangedAt: &processedAt},
+						},
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"number":"12345678903","status":"PROCESSED","accrual":500,"uploaded_at":"2024-05-01T10:00:00Z",` +
+				`"poll_count":2,"history":[{"status":"NEW","changed_at":"2024-05-01T10:00:00Z"},` +
+				`{"status":"PROCESSED","changed_at":"2024-05-01T10:01:00Z"}]}`,
+		},
+		{
+			name:   "Backfilled status without timestamp",
+			userID: 1,
+			number: "12345678903",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserOrderDetails(int64(1), "12345678903").
+					Return(&domain.OrderDetails{
+						Number:     "12345678903",
+						Status:     "PROCESSED",
+						Accrual:    &accrual,
+						UploadedAt: uploadedAt,
+						History: []*domain.OrderStatusChange{
+							{Status: "NEW", ChangedAt: &uploadedAt},
+							{Status: "PROCESSED", Backfilled: true},
+						},
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"history":[{"status":"NEW","changed_at":"2024-05-01T10:00:00Z"},{"status":"PROCESSED","backfilled":true}]}`,
+		},
+		{
+			name:   "Order of another user or unknown",
+			userID: 1,
+			number: "12345678903",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserOrderDetails(int64(1), "12345678903").
+					Return(nil, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"not_found"`,
+		},
+		{
+			name:   "Database error",
+			userID: 1,
+			number: "12345678903",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserOrderDetails(int64(1), "12345678903").
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"code":"internal_error"`,
+		},
+		{
+			name:           "Invalid order number",
+			userID:         1,
+			number:         "12345",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `"code":"invalid_order_number"`,
+		},
+		{
+			name:           "Missing user in context",
+			number:         "12345678903",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", tt.number)
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders/"+tt.number, nil)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			if tt.userID != 0 {
+				ctx = auth.WithUserID(ctx, tt.userID)
+			}
+			w := httptest.NewRecorder()
+
+			handler.GetOrder(w, req.WithContext(ctx))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestOrdersHandler_LoadOrdersBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	handler := NewOrdersHandler(zap.NewNop().Sugar(), mockRepo)
+
+	tests := []struct {
+		name           string
+		userID         int64
+		contentType    string
+		body           string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "JSON array with every outcome",
+			userID:      1,
+			contentType: domain.JSONContentType,
+			body:        `["79927398713", "12345", "4561261212345467", "12345678903", "79927398713"]`,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateOrders([]string{"79927398713", "4561261212345467", "12345678903"}, int64(1)).
+					Return([]*domain.OrderUploadResult{
+						{Number: "79927398713", Result: domain.OrderUploadAccepted},
+						{Number: "4561261212345467", Result: domain.OrderUploadAlreadyUploaded},
+						{Number: "12345678903", Result: domain.OrderUploadConflict},
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `[{"number":"79927398713","result":"accepted"},{"number":"12345","result":"invalid"},` +
+				`{"number":"4561261212345467","result":"already_uploaded"},{"number":"12345678903","result":"conflict"},` +
+				`{"number":"79927398713","result":"duplicate"}]`,
+		},
+		{
+			name:        "Repeated numbers report the stored result once",
+			userID:      1,
+			contentType: domain.TextContentType,
+			body:        "4561261212345467\n12345\n4561261212345467\n12345\n4561261212345467",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateOrders([]string{"4561261212345467"}, int64(1)).
+					Return([]*domain.OrderUploadResult{
+						{Number: "4561261212345467", Result: domain.OrderUploadAlreadyUploaded},
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `[{"number":"4561261212345467","result":"already_uploaded"},{"number":"12345","result":"invalid"},` +
+				`{"number":"4561261212345467","result":"duplicate"},{"number":"12345","result":"invalid"},` +
+				`{"number":"4561261212345467","result":"duplicate"}]`,
+		},
+		{
+			name:        "Newline-delimited text",
+			userID:      1,
+			contentType: domain.TextContentType,
+			body:        "79927398713\r\n\n  12345678903  \n",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateOrders([]string{"79927398713", "12345678903"}, int64(1)).
+					Return([]*domain.OrderUploadResult{
+						{Number: "79927398713", Result: domain.OrderUploadAccepted},
+						{Number: "12345678903", Result: domain.OrderUploadAccepted},
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"number":"79927398713","result":"accepted"},{"number":"12345678903","result":"accepted"}]`,
+		},
+		{
+			name:           "Only invalid numbers skip the database",
+			userID:         1,
+			contentType:    domain.TextContentType,
+			body:           "12345\nabc",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"number":"12345","result":"invalid"},{"number":"abc","result":"invalid"}]`,
+		},
+		{
+			name:           "Malformed JSON",
+			userID:         1,
+			contentType:    domain.JSONContentType,
+			body:           `{"orders": []}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "body must be a JSON array of order numbers",
+		},
+		{
+			name:           "Empty batch",
+			userID:         1,
+			contentType:    domain.TextContentType,
+			body:           "\n\n",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "batch must contain between 1 and 1000 order numbers",
+		},
+		{
+			name:           "Too many numbers",
+			userID:         1,
+			contentType:    domain.TextContentType,
+			body:           strings.Repeat("12345678903\n", maxOrdersBatchSize+1),
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "batch must contain between 1 and 1000 order numbers",
+		},
+		{
+			name:        "Database error",
+			userID:      1,
+			contentType: domain.TextContentType,
+			body:        "12345678903",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateOrders([]string{"12345678903"}, int64(1)).
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"code":"internal_error"`,
+		},
+		{
+			name:           "Missing user in context",
+			contentType:    domain.TextContentType,
+			body:           "12345678903",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.LoadOrdersBatch(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+const dateLayout = time.DateOnly
+
+func parsePageLimit(query url.Values) (int, error) {
+	value := query.Get("limit")
+	if value == "" {
+		// Plain list requests keep returning the whole list; paging only starts with limit or cursor.
+		if query.Get("cursor") == "" {
+			return domain.NoPageLimit, nil
+		}
+		return domain.DefaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > domain.MaxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", domain.MaxPageLimit)
+	}
+
+	return limit, nil
+}
+
+func parseCursor(query url.Values) (*domain.Cursor, error) {
+	value := query.Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+
+	cursor, err := domain.DecodeCursor(value)
+	if err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+
+	return cursor, nil
+}
+
+func parseTimeRange(query url.Values, fromParam, toParam string) (domain.TimeRange, error) {
+	var timeRange domain.TimeRange
+
+	if value := query.Get(fromParam); value != "" {
+		from, _, err := parseTimeParam(value)
+		if err != nil {
+			return domain.TimeRange{}, fmt.Errorf("%s must be an RFC3339 timestamp or a YYYY-MM-DD date", fromParam)
+		}
+		timeRange.From = &from
+	}
+
+	if value := query.Get(toParam); value != "" {
+		to, dateOnly, err := parseTimeParam(value)
+		if err != nil {
+			return domain.TimeRange{}, fmt.Errorf("%s must be an RFC3339 timestamp or a YYYY-MM-DD date", toParam)
+		}
+		// A bare date includes the whole day.
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		timeRange.To = &to
+	}
+
+	if timeRange.From != nil && timeRange.To != nil && !timeRange.From.Before(*timeRange.To) {
+		return domain.TimeRange{}, fmt.Errorf("%s must be before %s", fromParam, toParam)
+	}
+
+	return timeRange, nil
+}
+
+func parseTimeParam(value string) (time.Time, bool, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, false, nil
+	}
+
+	parsed, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return parsed, true, nil
+}
+
+func setNextPageHeaders(w http.ResponseWriter, req *http.Request, cursor *domain.Cursor) {
+	if cursor == nil {
+		return
+	}
+
+	encoded := cursor.Encode()
+
+	query := req.URL.Query()
+	query.Set("cursor", encoded)
+	next := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+
+	w.Header().Set(domain.NextCursorHeader, encoded)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
+
+func splitQueryList(query url.Values, param string) []string {
+	var values []string
+	for _, value := range query[param] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+
+	return values
+}
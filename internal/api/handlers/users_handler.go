@@ -2,32 +2,53 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/domain"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UsersRepository interface {
-	CreateAndReturnUser(login, password string) (*domain.DBUser, error)
+	CreateUser(login, passwordHash string) (*domain.DBUser, error)
 	GetUserByLogin(login string) (*domain.DBUser, error)
 	StoreRefreshToken(userID int64, token string, expiresAt time.Time) error
 	GetRefreshToken(token string) (*domain.RefreshToken, error)
+	RotateRefreshToken(oldToken, newToken string, expiresAt time.Time) (*domain.RefreshToken, error)
+	DeleteRefreshToken(token string) error
+	DeleteUserRefreshTokens(userID int64) error
+	RegisterLoginAttempt(keys ...domain.LoginAttemptKey) (time.Time, error)
+	RevertLoginAttempt(key domain.LoginAttemptKey) error
+	ClearLoginAttempts(key domain.LoginAttemptKey) error
+	GetUserByID(userID int64) (*domain.DBUser, error)
+	UpdateUserPassword(userID int64, passwordHash string) error
+	UpdatePasswordHash(userID int64, oldHash, newHash string) error
+	AnonymizeUser(userID int64) error
 }
 
 type UsersHandler struct {
 	logger *zap.SugaredLogger
 	repo   UsersRepository
+	hasher auth.PasswordHasher
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewUsersHandler(lgr *zap.SugaredLogger, repo UsersRepository) *UsersHandler {
+func NewUsersHandler(lgr *zap.SugaredLogger, repo UsersRepository, hasher auth.PasswordHasher) *UsersHandler {
 	return &UsersHandler{
 		logger: lgr,
 		repo:   repo,
+		hasher: hasher,
 	}
 }
 
@@ -37,66 +58,57 @@ func (uh *UsersHandler) RegisterUser(authConfig *config.AuthConfig) http.Handler
 
 		err := json.NewDecoder(req.Body).Decode(&user)
 		if err != nil {
-			http.Error(w, "Invalid input", http.StatusBadRequest)
+			problem.Render(w, req, problem.BadRequest("Invalid input"))
 			return
 		}
 
 		if user.Login == "" || user.Password == "" {
-			http.Error(w, "Login and password are required", http.StatusBadRequest)
+			problem.Render(w, req, problem.BadRequest("Login and password are required"))
 			return
 		}
 
-		existingUser, err := uh.repo.GetUserByLogin(user.Login)
-		if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+		user.Login = auth.NormalizeLogin(user.Login, authConfig.Credentials)
+		if violations := auth.ValidateCredentials(user.Login, user.Password, authConfig.Credentials); len(violations) > 0 {
+			problem.Render(w, req, problem.Validation(violations))
 			return
 		}
-		if existingUser != nil {
-			http.Error(w, "User already registered", http.StatusConflict)
+
+		passwordHash, err := uh.hasher.Hash(user.Password)
+		if err != nil {
+			uh.logger.Errorf("Password hashing failed for user: %s, err: %s", user.Login, err.Error())
+			problem.Render(w, req, problem.Internal("Failed to create user"))
 			return
 		}
 
-		dbUser, err := uh.repo.CreateAndReturnUser(user.Login, user.Password)
+		dbUser, err := uh.repo.CreateUser(user.Login, passwordHash)
+		if errors.Is(err, domain.ErrUserExists) {
+			problem.Render(w, req, problem.Wrap(err, "User already registered"))
+			return
+		}
 		if err != nil {
-			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to create user"))
 			return
 		}
 
 		accessToken, err := auth.GenerateAccessToken(dbUser.ID, authConfig)
 		if err != nil {
-			http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to generate access token"))
 			return
 		}
 
 		refreshToken, err := auth.GenerateRefreshToken(dbUser.ID, authConfig)
 		if err != nil {
-			http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to generate refresh token"))
 			return
 		}
 
 		err = uh.repo.StoreRefreshToken(dbUser.ID, refreshToken, time.Now().Add(authConfig.JWTRefreshTokenExpiresIn))
 		if err != nil {
-			http.Error(w, "Failed to store refresh token", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to store refresh token"))
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token",
-			Value:    accessToken,
-			Expires:  time.Now().Add(authConfig.JWTAccessTokenExpiresIn),
-			HttpOnly: true,
-			Path:     "/",
-		})
-
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshToken,
-			Expires:  time.Now().Add(authConfig.JWTRefreshTokenExpiresIn),
-			HttpOnly: true,
-			Path:     "/",
-		})
-
-		_, _ = w.Write([]byte("User created successfully"))
+		writeAuthResponse(w, req, authConfig, accessToken, refreshToken, "User created successfully")
 	}
 }
 
@@ -105,102 +117,357 @@ func (uh *UsersHandler) LoginUser(authConfig *config.AuthConfig) http.HandlerFun
 		var user domain.User
 
 		if err := json.NewDecoder(req.Body).Decode(&user); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			problem.Render(w, req, problem.BadRequest("Invalid request payload"))
 			return
 		}
 
 		if user.Login == "" || user.Password == "" {
-			http.Error(w, "Login and password are required", http.StatusBadRequest)
+			problem.Render(w, req, problem.BadRequest("Login and password are required"))
 			return
 		}
 
-		dbUser, err := uh.repo.GetUserByLogin(user.Login)
+		user.Login = auth.NormalizeLogin(user.Login, authConfig.Credentials)
+		attemptKeys := loginAttemptKeys(user.Login, clientIP(req, authConfig.TrustedProxies))
+
+		lockedUntil, err := uh.repo.RegisterLoginAttempt(attemptKeys...)
 		if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Database error"))
+			return
+		}
+		if retryAfter := time.Until(lockedUntil); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			problem.Render(w, req, problem.New(
+				http.StatusTooManyRequests, problem.CodeTooManyLoginAttempts, "Too many failed login attempts",
+			))
 			return
 		}
-		if dbUser == nil {
-			http.Error(w, "Invalid login or password", http.StatusUnauthorized)
+
+		dbUser, err := uh.repo.GetUserByLogin(user.Login)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			problem.Render(w, req, problem.Internal("Database error"))
 			return
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(dbUser.PasswordHash), []byte(user.Password))
+		// Unknown logins are verified against a dummy hash so they take as long as a wrong password.
+		passwordHash := uh.dummyPasswordHash()
+		if dbUser != nil {
+			passwordHash = dbUser.PasswordHash
+		}
+		passwordValid, err := uh.hasher.Verify(passwordHash, user.Password)
 		if err != nil {
-			http.Error(w, "Invalid login or password", http.StatusUnauthorized)
+			uh.logger.Errorf("Password verification failed for user: %s, err: %s", user.Login, err.Error())
+			problem.Render(w, req, problem.Internal("Failed to verify password"))
+			return
+		}
+		if dbUser == nil || !passwordValid {
+			problem.Render(w, req, problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid login or password"))
 			return
 		}
 
+		if err := uh.repo.ClearLoginAttempts(attemptKeys[0]); err != nil {
+			uh.logger.Warnf("Failed to reset login attempts for user: %s, err: %s", user.Login, err.Error())
+		}
+		if err := uh.repo.RevertLoginAttempt(attemptKeys[1]); err != nil {
+			uh.logger.Warnf("Failed to revert login attempt for ip: %s, err: %s", attemptKeys[1].Value, err.Error())
+		}
+
+		uh.rehashPasswordIfNeeded(dbUser, user.Password)
+
 		accessToken, err := auth.GenerateAccessToken(dbUser.ID, authConfig)
 		if err != nil {
-			http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to generate access token"))
 			return
 		}
 
 		refreshToken, err := auth.GenerateRefreshToken(dbUser.ID, authConfig)
 		if err != nil {
-			http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to generate refresh token"))
 			return
 		}
 
 		err = uh.repo.StoreRefreshToken(dbUser.ID, refreshToken, time.Now().Add(authConfig.JWTRefreshTokenExpiresIn))
 		if err != nil {
-			http.Error(w, "Failed to store refresh token", http.StatusInternalServerError)
+			problem.Render(w, req, problem.Internal("Failed to store refresh token"))
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token",
-			Value:    accessToken,
-			Expires:  time.Now().Add(authConfig.JWTAccessTokenExpiresIn),
-			HttpOnly: true,
-			Path:     "/",
-		})
+		writeAuthResponse(w, req, authConfig, accessToken, refreshToken, "Login successful")
+	}
+}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshToken,
-			Expires:  time.Now().Add(authConfig.JWTRefreshTokenExpiresIn),
-			HttpOnly: true,
-			Path:     "/",
-		})
+func (uh *UsersHandler) RefreshToken(authConfig *config.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		presentedToken := refreshTokenFromRequest(req, authConfig)
+		if presentedToken == "" {
+			problem.Render(w, req, problem.BadRequest("Refresh token is required"))
+			return
+		}
 
-		_, _ = w.Write([]byte("Login successful"))
+		claims, err := auth.ParseRefreshToken(presentedToken, authConfig)
+		if err != nil {
+			problem.Render(w, req, problem.Wrap(domain.ErrRefreshTokenInvalid, "Refresh token is expired or invalid"))
+			return
+		}
+
+		refreshToken, err := uh.repo.GetRefreshToken(presentedToken)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			problem.Render(w, req, problem.Internal("Database error"))
+			return
+		}
+
+		if refreshToken == nil || refreshToken.UserID != claims.UserID || refreshToken.ExpiresAt.Before(time.Now()) {
+			problem.Render(w, req, problem.Wrap(domain.ErrRefreshTokenInvalid, "Refresh token is expired or invalid"))
+			return
+		}
+
+		newRefreshToken, err := auth.GenerateRefreshToken(refreshToken.UserID, authConfig)
+		if err != nil {
+			problem.Render(w, req, problem.Internal("Failed to generate refresh token"))
+			return
+		}
+
+		rotatedToken, err := uh.repo.RotateRefreshToken(presentedToken, newRefreshToken, time.Now().Add(authConfig.JWTRefreshTokenExpiresIn))
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrRefreshTokenReused):
+				auth.ClearTokenCookies(w, authConfig)
+				problem.Render(w, req, problem.Wrap(err, "Refresh token reuse detected"))
+			case errors.Is(err, domain.ErrRefreshTokenInvalid):
+				problem.Render(w, req, problem.Wrap(err, "Refresh token is expired or invalid"))
+			default:
+				problem.Render(w, req, problem.Internal("Failed to rotate refresh token"))
+			}
+			return
+		}
+
+		accessToken, err := auth.GenerateAccessToken(rotatedToken.UserID, authConfig)
+		if err != nil {
+			problem.Render(w, req, problem.Internal("Failed to generate access token"))
+			return
+		}
+
+		writeAuthResponse(w, req, authConfig, accessToken, rotatedToken.Token, "Token refreshed successfully")
 	}
 }
 
-func (uh *UsersHandler) RefreshToken(authConfig *config.AuthConfig) http.HandlerFunc {
+func (uh *UsersHandler) LogoutUser(authConfig *config.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if refreshToken := refreshTokenFromRequest(req, authConfig); refreshToken != "" {
+			if err := uh.repo.DeleteRefreshToken(refreshToken); err != nil {
+				problem.Render(w, req, problem.Internal("Failed to revoke refresh token"))
+				return
+			}
+		}
+
+		auth.ClearTokenCookies(w, authConfig)
+
+		_, _ = w.Write([]byte("Logout successful"))
+	}
+}
+
+func (uh *UsersHandler) LogoutAllSessions(authConfig *config.AuthConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		cookie, err := req.Cookie("refresh_token")
+		userID, ok := auth.UserFromContext(req.Context())
+		if !ok {
+			problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		if err := uh.repo.DeleteUserRefreshTokens(userID); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to revoke refresh tokens"))
+			return
+		}
+
+		auth.ClearTokenCookies(w, authConfig)
+
+		_, _ = w.Write([]byte("All sessions terminated"))
+	}
+}
+
+func (uh *UsersHandler) ChangePassword(authConfig *config.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := auth.UserFromContext(req.Context())
+		if !ok {
+			problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		var change domain.PasswordChange
+		if err := json.NewDecoder(req.Body).Decode(&change); err != nil {
+			problem.Render(w, req, problem.BadRequest("Invalid request payload"))
+			return
+		}
+
+		if change.CurrentPassword == "" || change.NewPassword == "" {
+			problem.Render(w, req, problem.BadRequest("Current and new passwords are required"))
+			return
+		}
+
+		dbUser, err := uh.repo.GetUserByID(userID)
+		if errors.Is(err, domain.ErrNotFound) {
+			problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+			return
+		}
+		if err != nil {
+			problem.Render(w, req, problem.Internal("Database error"))
+			return
+		}
+
+		passwordValid, err := uh.hasher.Verify(dbUser.PasswordHash, change.CurrentPassword)
 		if err != nil {
-			http.Error(w, "Refresh token is required", http.StatusBadRequest)
+			uh.logger.Errorf("Password verification failed for user: %d, err: %s", userID, err.Error())
+			problem.Render(w, req, problem.Internal("Failed to verify password"))
+			return
+		}
+		if !passwordValid {
+			problem.Render(w, req, problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid current password"))
+			return
+		}
+
+		if violations := auth.ValidatePassword(change.NewPassword, authConfig.Credentials); len(violations) > 0 {
+			problem.Render(w, req, problem.Validation(violations))
 			return
 		}
 
-		refreshToken, err := uh.repo.GetRefreshToken(cookie.Value)
+		passwordHash, err := uh.hasher.Hash(change.NewPassword)
 		if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			uh.logger.Errorf("Password hashing failed for user: %d, err: %s", userID, err.Error())
+			problem.Render(w, req, problem.Internal("Failed to change password"))
 			return
 		}
 
-		if refreshToken == nil || refreshToken.ExpiresAt.Before(time.Now()) {
-			http.Error(w, "Refresh token is expired or invalid", http.StatusUnauthorized)
+		if err := uh.repo.UpdateUserPassword(userID, passwordHash); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to change password"))
 			return
 		}
 
-		accessToken, err := auth.GenerateAccessToken(refreshToken.UserID, authConfig)
+		auth.ClearTokenCookies(w, authConfig)
+
+		_, _ = w.Write([]byte("Password changed successfully"))
+	}
+}
+
+func (uh *UsersHandler) DeleteUser(authConfig *config.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := auth.UserFromContext(req.Context())
+		if !ok {
+			problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		if err := uh.repo.AnonymizeUser(userID); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to delete user"))
+			return
+		}
+
+		auth.ClearTokenCookies(w, authConfig)
+
+		_, _ = w.Write([]byte("User deleted successfully"))
+	}
+}
+
+func (uh *UsersHandler) rehashPasswordIfNeeded(dbUser *domain.DBUser, password string) {
+	if !uh.hasher.NeedsRehash(dbUser.PasswordHash) {
+		return
+	}
+
+	passwordHash, err := uh.hasher.Hash(password)
+	if err != nil {
+		uh.logger.Warnf("Failed to rehash password for user: %d, err: %s", dbUser.ID, err.Error())
+		return
+	}
+
+	if err := uh.repo.UpdatePasswordHash(dbUser.ID, dbUser.PasswordHash, passwordHash); err != nil {
+		uh.logger.Warnf("Failed to store rehashed password for user: %d, err: %s", dbUser.ID, err.Error())
+	}
+}
+
+func (uh *UsersHandler) dummyPasswordHash() string {
+	uh.dummyHashOnce.Do(func() {
+		hash, err := uh.hasher.Hash("gophermart-dummy-password")
 		if err != nil {
-			http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+			uh.logger.Errorf("Failed to hash dummy password, err: %s", err.Error())
 			return
 		}
+		uh.dummyHash = hash
+	})
+
+	return uh.dummyHash
+}
+
+func loginAttemptKeys(login, clientIP string) []domain.LoginAttemptKey {
+	return []domain.LoginAttemptKey{
+		{Scope: domain.LoginAttemptScopeLogin, Value: login},
+		{Scope: domain.LoginAttemptScopeIP, Value: clientIP},
+	}
+}
+
+// clientIP trusts X-Forwarded-For only when the request came through a configured proxy, and then walks it
+// from the nearest hop, stopping at the first address that isn't a trusted proxy.
+func clientIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token",
-			Value:    accessToken,
-			Expires:  time.Now().Add(authConfig.JWTAccessTokenExpiresIn),
-			HttpOnly: true,
-			Path:     "/",
-		})
+	return ip
+}
+
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
 
-		_, _ = w.Write([]byte("Token refreshed successfully"))
+func refreshTokenFromRequest(req *http.Request, authConfig *config.AuthConfig) string {
+	if refreshToken := auth.TokenFromCookie(req, authConfig, auth.RefreshTokenCookie); refreshToken != "" {
+		return refreshToken
 	}
+
+	var body domain.RefreshTokenRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return ""
+	}
+
+	return body.RefreshToken
+}
+
+func writeAuthResponse(w http.ResponseWriter, req *http.Request, authConfig *config.AuthConfig, accessToken, refreshToken, message string) {
+	auth.SetTokenCookie(w, authConfig, auth.AccessTokenCookie, accessToken, authConfig.JWTAccessTokenExpiresIn)
+	auth.SetTokenCookie(w, authConfig, auth.RefreshTokenCookie, refreshToken, authConfig.JWTRefreshTokenExpiresIn)
+
+	if !strings.Contains(req.Header.Get("Accept"), domain.JSONContentType) {
+		_, _ = w.Write([]byte(message))
+		return
+	}
+
+	w.Header().Set("Content-Type", domain.JSONContentType)
+	_ = json.NewEncoder(w).Encode(domain.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(authConfig.JWTAccessTokenExpiresIn.Seconds()),
+	})
 }
@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
+	mw "github.com/frolmr/gophermart/internal/api/middleware"
+	"github.com/frolmr/gophermart/internal/api/problem"
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
@@ -24,7 +31,7 @@ func TestUsersHandler_RegisterUser(t *testing.T) {
 
 	mockRepo := mocks.NewMockUsersRepository(ctrl)
 	logger := zap.NewNop().Sugar()
-	handler := NewUsersHandler(logger, mockRepo)
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
 
 	tests := []struct {
 		name           string
@@ -41,11 +48,7 @@ func TestUsersHandler_RegisterUser(t *testing.T) {
 			},
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetUserByLogin("testuser").
-					Return(nil, nil)
-
-				mockRepo.EXPECT().
-					CreateAndReturnUser("testuser", gomock.Any()).
+					CreateUser("testuser", hashOf("testpassword")).
 					Return(&domain.DBUser{ID: 1, Login: "testuser"}, nil)
 
 				mockRepo.EXPECT().
@@ -63,12 +66,26 @@ func TestUsersHandler_RegisterUser(t *testing.T) {
 			},
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetUserByLogin("existinguser").
-					Return(&domain.DBUser{ID: 1, Login: "existinguser"}, nil)
+					CreateUser("existinguser", gomock.Any()).
+					Return(nil, domain.ErrUserExists)
 			},
 			expectedStatus: http.StatusConflict,
 			expectedBody:   "User already registered",
 		},
+		{
+			name: "Database error",
+			input: domain.User{
+				Login:    "testuser",
+				Password: "testpassword",
+			},
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateUser("testuser", gomock.Any()).
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to create user",
+		},
 	}
 
 	for _, tt := range tests {
@@ -93,20 +110,174 @@ func TestUsersHandler_RegisterUser(t *testing.T) {
 	}
 }
 
+func TestUsersHandler_RegisterUser_ConcurrentSameLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, testPasswordHasher())
+
+	authConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  time.Hour,
+		JWTRefreshTokenExpiresIn: time.Hour,
+	}
+
+	const registrations = 10
+
+	var mu sync.Mutex
+	registered := make(map[string]int64)
+	mockRepo.EXPECT().
+		CreateUser("racer", gomock.Any()).
+		DoAndReturn(func(login, passwordHash string) (*domain.DBUser, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := registered[login]; ok {
+				return nil, domain.ErrUserExists
+			}
+			registered[login] = int64(len(registered) + 1)
+			return &domain.DBUser{ID: registered[login], Login: login, PasswordHash: passwordHash}, nil
+		}).
+		Times(registrations)
+	mockRepo.EXPECT().StoreRefreshToken(int64(1), gomock.Any(), gomock.Any()).Return(nil)
+
+	body, _ := json.Marshal(domain.User{Login: "racer", Password: "testpassword"})
+
+	start := make(chan struct{})
+	statuses := make(chan int, registrations)
+	var wg sync.WaitGroup
+	for range registrations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.RegisterUser(authConfig).ServeHTTP(w, req)
+			statuses <- w.Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusConflict: registrations - 1}, counts)
+}
+
+func TestUsersHandler_RegisterUser_Validation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mocks.NewMockUsersRepository(ctrl), testPasswordHasher())
+
+	authConfig := &config.AuthConfig{
+		JWTKey: []byte("secret"),
+		Credentials: config.CredentialsPolicy{
+			PasswordMinLength:       8,
+			PasswordRequiredClasses: []string{config.PasswordClassDigit},
+			RejectCommonPasswords:   true,
+			LoginMinLength:          3,
+			LoginMaxLength:          50,
+		},
+	}
+
+	body, _ := json.Marshal(domain.User{Login: strings.Repeat("a", 51), Password: "password"})
+	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.RegisterUser(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+	var response problem.Details
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, problem.CodeValidationFailed, response.Code)
+
+	var rules []string
+	for _, violation := range response.Violations {
+		rules = append(rules, violation.Field+":"+violation.Rule)
+	}
+	assert.Equal(t, []string{"login:max_length", "password:character_class_digit", "password:common_password"}, rules)
+}
+
+func TestUsersHandler_RegisterUser_PasswordTooLongForBcrypt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mocks.NewMockUsersRepository(ctrl), testPasswordHasher())
+
+	authConfig := &config.AuthConfig{
+		JWTKey: []byte("secret"),
+		Credentials: config.CredentialsPolicy{
+			PasswordMinLength: 8,
+			PasswordMaxBytes:  config.BcryptMaxPasswordBytes,
+			LoginMinLength:    3,
+			LoginMaxLength:    50,
+		},
+	}
+
+	body, _ := json.Marshal(domain.User{Login: "gopher", Password: strings.Repeat("p", 73)})
+	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.RegisterUser(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response problem.Details
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, problem.CodeValidationFailed, response.Code)
+	assert.Equal(t, []domain.ValidationViolation{{
+		Field:   "password",
+		Rule:    "max_length",
+		Message: "password must be at most 72 bytes long",
+	}}, response.Violations)
+}
+
+func TestUsersHandler_RegisterUser_CaseFoldsLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, testPasswordHasher())
+
+	mockRepo.EXPECT().
+		CreateUser("gopher", gomock.Any()).
+		Return(nil, domain.ErrUserExists)
+
+	body, _ := json.Marshal(domain.User{Login: "Gopher", Password: "testpassword"})
+	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.RegisterUser(&config.AuthConfig{Credentials: config.CredentialsPolicy{LoginCaseFold: true}}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
 func TestUsersHandler_LoginUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := mocks.NewMockUsersRepository(ctrl)
 	logger := zap.NewNop().Sugar()
-	handler := NewUsersHandler(logger, mockRepo)
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	loginKey := domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeLogin, Value: "testuser"}
+	ipKey := domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeIP, Value: "192.0.2.1"}
 
 	tests := []struct {
-		name           string
-		input          domain.User
-		mockSetup      func()
-		expectedStatus int
-		expectedBody   string
+		name               string
+		input              domain.User
+		mockSetup          func()
+		expectedStatus     int
+		expectedBody       string
+		expectedRetryAfter string
 	}{
 		{
 			name: "Successful login",
@@ -115,11 +286,22 @@ func TestUsersHandler_LoginUser(t *testing.T) {
 				Password: "testpassword",
 			},
 			mockSetup: func() {
+				mockRepo.EXPECT().
+					RegisterLoginAttempt(loginKey, ipKey).
+					Return(time.Time{}, nil)
+
 				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.DefaultCost)
 				mockRepo.EXPECT().
 					GetUserByLogin("testuser").
 					Return(&domain.DBUser{ID: 1, Login: "testuser", PasswordHash: string(hashedPassword)}, nil)
 
+				mockRepo.EXPECT().
+					ClearLoginAttempts(loginKey).
+					Return(nil)
+				mockRepo.EXPECT().
+					RevertLoginAttempt(ipKey).
+					Return(nil)
+
 				mockRepo.EXPECT().
 					StoreRefreshToken(int64(1), gomock.Any(), gomock.Any()).
 					Return(nil)
@@ -134,6 +316,10 @@ func TestUsersHandler_LoginUser(t *testing.T) {
 				Password: "wrongpassword",
 			},
 			mockSetup: func() {
+				mockRepo.EXPECT().
+					RegisterLoginAttempt(loginKey, ipKey).
+					Return(time.Time{}, nil)
+
 				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.DefaultCost)
 				mockRepo.EXPECT().
 					GetUserByLogin("testuser").
@@ -142,6 +328,53 @@ func TestUsersHandler_LoginUser(t *testing.T) {
 			expectedStatus: http.StatusUnauthorized,
 			expectedBody:   "Invalid login or password",
 		},
+		{
+			name: "Unknown login counts as failed attempt",
+			input: domain.User{
+				Login:    "testuser",
+				Password: "testpassword",
+			},
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					RegisterLoginAttempt(loginKey, ipKey).
+					Return(time.Time{}, nil)
+
+				mockRepo.EXPECT().
+					GetUserByLogin("testuser").
+					Return(nil, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Invalid login or password",
+		},
+		{
+			name: "Locked out",
+			input: domain.User{
+				Login:    "testuser",
+				Password: "testpassword",
+			},
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					RegisterLoginAttempt(loginKey, ipKey).
+					Return(time.Now().Add(90*time.Second), nil)
+			},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedBody:       "Too many failed login attempts",
+			expectedRetryAfter: "90",
+		},
+		{
+			name: "Failed attempt tracking error",
+			input: domain.User{
+				Login:    "testuser",
+				Password: "wrongpassword",
+			},
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					RegisterLoginAttempt(loginKey, ipKey).
+					Return(time.Time{}, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Database error",
+		},
 	}
 
 	for _, tt := range tests {
@@ -162,23 +395,143 @@ func TestUsersHandler_LoginUser(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			assert.Equal(t, tt.expectedRetryAfter, w.Header().Get("Retry-After"))
 		})
 	}
 }
 
+func TestUsersHandler_LoginUser_JSONResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	mockRepo.EXPECT().
+		RegisterLoginAttempt(gomock.Any(), gomock.Any()).
+		Return(time.Time{}, nil)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.DefaultCost)
+	mockRepo.EXPECT().
+		GetUserByLogin("testuser").
+		Return(&domain.DBUser{ID: 1, Login: "testuser", PasswordHash: string(hashedPassword)}, nil)
+	mockRepo.EXPECT().
+		ClearLoginAttempts(gomock.Any()).
+		Return(nil)
+	mockRepo.EXPECT().
+		RevertLoginAttempt(gomock.Any()).
+		Return(nil)
+	mockRepo.EXPECT().
+		StoreRefreshToken(int64(1), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	body, _ := json.Marshal(domain.User{Login: "testuser", Password: "testpassword"})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Accept", domain.JSONContentType)
+	w := httptest.NewRecorder()
+
+	authConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  time.Hour,
+		JWTRefreshTokenExpiresIn: time.Hour,
+	}
+
+	handler.LoginUser(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, domain.JSONContentType, w.Header().Get("Content-Type"))
+
+	var tokens domain.TokenResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&tokens))
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, int64(3600), tokens.ExpiresIn)
+}
+
+func testRefreshToken(t *testing.T, authConfig *config.AuthConfig, userID int64) string {
+	t.Helper()
+
+	token, err := auth.GenerateRefreshToken(userID, authConfig)
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
+	}
+
+	return token
+}
+
+func TestUsersHandler_RefreshToken_FromJSONBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	authConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  15 * time.Minute,
+		JWTRefreshTokenExpiresIn: 24 * time.Hour,
+	}
+	validRefreshToken := testRefreshToken(t, authConfig, 1)
+
+	mockRepo.EXPECT().
+		GetRefreshToken(validRefreshToken).
+		Return(&domain.RefreshToken{ID: 1, UserID: 1, Token: validRefreshToken, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	mockRepo.EXPECT().
+		RotateRefreshToken(validRefreshToken, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, newToken string, expiresAt time.Time) (*domain.RefreshToken, error) {
+			return &domain.RefreshToken{ID: 2, UserID: 1, Token: newToken, ExpiresAt: expiresAt}, nil
+		})
+
+	body, _ := json.Marshal(domain.RefreshTokenRequest{RefreshToken: validRefreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body))
+	req.Header.Set("Accept", domain.JSONContentType)
+	w := httptest.NewRecorder()
+
+	handler.RefreshToken(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tokens domain.TokenResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&tokens))
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEqual(t, validRefreshToken, tokens.RefreshToken)
+	assert.Equal(t, int64(900), tokens.ExpiresIn)
+}
+
+func TestUsersHandler_RefreshToken_Missing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mocks.NewMockUsersRepository(ctrl), testPasswordHasher())
+
+	req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+	w := httptest.NewRecorder()
+
+	handler.RefreshToken(&config.AuthConfig{JWTKey: []byte("secret")}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Refresh token is required")
+}
+
 func TestUsersHandler_RefreshToken(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := mocks.NewMockUsersRepository(ctrl)
 	logger := zap.NewNop().Sugar()
-	handler := NewUsersHandler(logger, mockRepo)
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
 
 	authConfig := &config.AuthConfig{
 		JWTKey:                   []byte("secret"),
 		JWTAccessTokenExpiresIn:  15 * time.Minute,
 		JWTRefreshTokenExpiresIn: 24 * time.Hour,
 	}
+	validRefreshToken := testRefreshToken(t, authConfig, 1)
+	usedRefreshToken := testRefreshToken(t, authConfig, 1)
+	invalidRefreshToken := testRefreshToken(t, authConfig, 1)
+	expiredRefreshToken := testRefreshToken(t, authConfig, 1)
+	accessToken, _ := auth.GenerateAccessToken(1, authConfig)
 
 	tests := []struct {
 		name           string
@@ -189,53 +542,121 @@ func TestUsersHandler_RefreshToken(t *testing.T) {
 	}{
 		{
 			name:         "Successful token refresh",
-			refreshToken: "valid-refresh-token",
+			refreshToken: validRefreshToken,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetRefreshToken("valid-refresh-token").
+					GetRefreshToken(validRefreshToken).
 					Return(&domain.RefreshToken{
 						ID:        1,
 						UserID:    1,
-						Token:     "valid-refresh-token",
+						Token:     validRefreshToken,
 						ExpiresAt: time.Now().Add(time.Hour),
 					}, nil)
+				mockRepo.EXPECT().
+					RotateRefreshToken(validRefreshToken, gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_, newToken string, expiresAt time.Time) (*domain.RefreshToken, error) {
+						return &domain.RefreshToken{ID: 2, UserID: 1, Token: newToken, ExpiresAt: expiresAt}, nil
+					})
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "Token refreshed successfully",
 		},
+		{
+			name:         "Reused refresh token",
+			refreshToken: usedRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(usedRefreshToken).
+					Return(&domain.RefreshToken{
+						ID:        1,
+						UserID:    1,
+						Token:     usedRefreshToken,
+						ExpiresAt: time.Now().Add(time.Hour),
+					}, nil)
+				mockRepo.EXPECT().
+					RotateRefreshToken(usedRefreshToken, gomock.Any(), gomock.Any()).
+					Return(nil, domain.ErrRefreshTokenReused)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Refresh token reuse detected",
+		},
+		{
+			name:         "Rotation database error",
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(&domain.RefreshToken{
+						ID:        1,
+						UserID:    1,
+						Token:     validRefreshToken,
+						ExpiresAt: time.Now().Add(time.Hour),
+					}, nil)
+				mockRepo.EXPECT().
+					RotateRefreshToken(validRefreshToken, gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to rotate refresh token",
+		},
 		{
 			name:         "Invalid refresh token",
-			refreshToken: "invalid-refresh-token",
+			refreshToken: invalidRefreshToken,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetRefreshToken("invalid-refresh-token").
-					Return(nil, nil)
+					GetRefreshToken(invalidRefreshToken).
+					Return(nil, domain.ErrNotFound)
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedBody:   "Refresh token is expired or invalid",
 		},
 		{
 			name:         "Expired refresh token",
-			refreshToken: "expired-refresh-token",
+			refreshToken: expiredRefreshToken,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetRefreshToken("expired-refresh-token").
+					GetRefreshToken(expiredRefreshToken).
 					Return(&domain.RefreshToken{
 						ID:        1,
 						UserID:    1,
-						Token:     "expired-refresh-token",
+						Token:     expiredRefreshToken,
 						ExpiresAt: time.Now().Add(-time.Hour),
 					}, nil)
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedBody:   "Refresh token is expired or invalid",
 		},
+		{
+			name:           "Access token presented as refresh token",
+			refreshToken:   accessToken,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Refresh token is expired or invalid",
+		},
+		{
+			name:           "Malformed refresh token",
+			refreshToken:   "not-a-jwt",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Refresh token is expired or invalid",
+		},
+		{
+			name:         "Refresh token stored for another user",
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(&domain.RefreshToken{ID: 1, UserID: 2, Token: validRefreshToken, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Refresh token is expired or invalid",
+		},
 		{
 			name:         "Database error",
-			refreshToken: "valid-refresh-token",
+			refreshToken: validRefreshToken,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetRefreshToken("valid-refresh-token").
+					GetRefreshToken(validRefreshToken).
 					Return(nil, errors.New("database error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -255,6 +676,599 @@ func TestUsersHandler_RefreshToken(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 			assert.Contains(t, w.Body.String(), tt.expectedBody)
+
+			cookies := map[string]string{}
+			for _, cookie := range w.Result().Cookies() {
+				cookies[cookie.Name] = cookie.Value
+			}
+			switch tt.expectedStatus {
+			case http.StatusOK:
+				assert.NotEmpty(t, cookies["access_token"])
+				assert.NotEmpty(t, cookies["refresh_token"])
+				assert.NotEqual(t, tt.refreshToken, cookies["refresh_token"])
+			case http.StatusUnauthorized:
+				assert.Empty(t, cookies["refresh_token"])
+			}
 		})
 	}
 }
+
+func TestUsersHandler_LogoutUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	tests := []struct {
+		name           string
+		refreshToken   string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:         "Successful logout",
+			refreshToken: "valid-refresh-token",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					DeleteRefreshToken("valid-refresh-token").
+					Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Logout successful",
+		},
+		{
+			name:           "Logout without refresh token",
+			refreshToken:   "",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Logout successful",
+		},
+		{
+			name:         "Database error",
+			refreshToken: "valid-refresh-token",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					DeleteRefreshToken("valid-refresh-token").
+					Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to revoke refresh token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+			if tt.refreshToken != "" {
+				req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tt.refreshToken})
+			}
+			w := httptest.NewRecorder()
+
+			handler.LogoutUser(&config.AuthConfig{}).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.expectedStatus == http.StatusOK {
+				cookies := w.Result().Cookies()
+				assert.Len(t, cookies, 2)
+				for _, cookie := range cookies {
+					assert.Empty(t, cookie.Value)
+					assert.Equal(t, -1, cookie.MaxAge)
+				}
+			}
+		})
+	}
+}
+
+func TestUsersHandler_LogoutUser_FromJSONBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, testPasswordHasher())
+
+	mockRepo.EXPECT().
+		DeleteRefreshToken("valid-refresh-token").
+		Return(nil)
+
+	body, _ := json.Marshal(domain.RefreshTokenRequest{RefreshToken: "valid-refresh-token"})
+	req := httptest.NewRequest(http.MethodPost, "/logout", bytes.NewReader(body))
+	req.Header.Set("Content-Type", domain.JSONContentType)
+	w := httptest.NewRecorder()
+
+	handler.LogoutUser(&config.AuthConfig{}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Logout successful")
+}
+
+func TestUsersHandler_LogoutAllSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Successful logout from all sessions",
+			userID: 1,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					DeleteUserRefreshTokens(int64(1)).
+					Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "All sessions terminated",
+		},
+		{
+			name:   "Database error",
+			userID: 1,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					DeleteUserRefreshTokens(int64(1)).
+					Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to revoke refresh tokens",
+		},
+		{
+			name:           "Missing user in context",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/logout-all", nil)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.LogoutAllSessions(&config.AuthConfig{}).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestUsersHandler_ChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	authConfig := &config.AuthConfig{
+		JWTKey:      []byte("secret"),
+		Credentials: config.CredentialsPolicy{PasswordMinLength: 8},
+	}
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("oldpassword"), bcrypt.DefaultCost)
+	dbUser := &domain.DBUser{ID: 1, Login: "testuser", PasswordHash: string(hashedPassword)}
+
+	tests := []struct {
+		name           string
+		userID         int64
+		input          domain.PasswordChange
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Successful password change",
+			userID: 1,
+			input:  domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: "newpassword"},
+			mockSetup: func() {
+				mockRepo.EXPECT().GetUserByID(int64(1)).Return(dbUser, nil)
+				mockRepo.EXPECT().UpdateUserPassword(int64(1), hashOf("newpassword")).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Password changed successfully",
+		},
+		{
+			name:   "Wrong current password",
+			userID: 1,
+			input:  domain.PasswordChange{CurrentPassword: "guess", NewPassword: "newpassword"},
+			mockSetup: func() {
+				mockRepo.EXPECT().GetUserByID(int64(1)).Return(dbUser, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Invalid current password",
+		},
+		{
+			name:   "New password violates policy",
+			userID: 1,
+			input:  domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: "short"},
+			mockSetup: func() {
+				mockRepo.EXPECT().GetUserByID(int64(1)).Return(dbUser, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"rule":"min_length"`,
+		},
+		{
+			name:   "New password exceeds bcrypt limit",
+			userID: 1,
+			input:  domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: strings.Repeat("p", 73)},
+			mockSetup: func() {
+				mockRepo.EXPECT().GetUserByID(int64(1)).Return(dbUser, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"rule":"max_length"`,
+		},
+		{
+			name:           "Missing passwords",
+			userID:         1,
+			input:          domain.PasswordChange{CurrentPassword: "oldpassword"},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Current and new passwords are required",
+		},
+		{
+			name:   "Deleted user",
+			userID: 1,
+			input:  domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: "newpassword"},
+			mockSetup: func() {
+				mockRepo.EXPECT().GetUserByID(int64(1)).Return(nil, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
+		},
+		{
+			name:   "Database error",
+			userID: 1,
+			input:  domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: "newpassword"},
+			mockSetup: func() {
+				mockRepo.EXPECT().GetUserByID(int64(1)).Return(dbUser, nil)
+				mockRepo.EXPECT().UpdateUserPassword(int64(1), hashOf("newpassword")).Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to change password",
+		},
+		{
+			name:           "Missing user in context",
+			input:          domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: "newpassword"},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			body, _ := json.Marshal(tt.input)
+			req := httptest.NewRequest(http.MethodPost, "/password", bytes.NewReader(body))
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.ChangePassword(authConfig).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestUsersHandler_LoginUser_RehashesWeakPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	hasher := auth.NewPasswordHasher(config.PasswordHashingPolicy{
+		Algorithm:         config.PasswordHashArgon2id,
+		BcryptCost:        bcrypt.MinCost,
+		Argon2Time:        1,
+		Argon2MemoryKiB:   64,
+		Argon2Parallelism: 1,
+	})
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, hasher)
+
+	authConfig := &config.AuthConfig{JWTKey: []byte("secret")}
+
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	dbUser := &domain.DBUser{ID: 1, Login: "testuser", PasswordHash: string(legacyHash)}
+
+	mockRepo.EXPECT().RegisterLoginAttempt(gomock.Any(), gomock.Any()).Return(time.Time{}, nil)
+	mockRepo.EXPECT().GetUserByLogin("testuser").Return(dbUser, nil)
+	mockRepo.EXPECT().ClearLoginAttempts(gomock.Any()).Return(nil)
+	mockRepo.EXPECT().RevertLoginAttempt(gomock.Any()).Return(nil)
+	mockRepo.EXPECT().
+		UpdatePasswordHash(int64(1), string(legacyHash), gomock.Cond(func(newHash string) bool {
+			valid, err := hasher.Verify(newHash, "testpassword")
+			return strings.HasPrefix(newHash, "$argon2id$") && valid && err == nil
+		})).
+		Return(nil)
+	mockRepo.EXPECT().StoreRefreshToken(int64(1), gomock.Any(), gomock.Any()).Return(nil)
+
+	body, _ := json.Marshal(domain.User{Login: "testuser", Password: "testpassword"})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.LoginUser(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Login successful")
+}
+
+func TestUsersHandler_LoginUser_RehashFailureDoesNotBlockLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	hasher := auth.NewPasswordHasher(config.PasswordHashingPolicy{
+		Algorithm:  config.PasswordHashBcrypt,
+		BcryptCost: bcrypt.MinCost + 1,
+	})
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, hasher)
+
+	authConfig := &config.AuthConfig{JWTKey: []byte("secret")}
+
+	weakHash, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	dbUser := &domain.DBUser{ID: 1, Login: "testuser", PasswordHash: string(weakHash)}
+
+	mockRepo.EXPECT().RegisterLoginAttempt(gomock.Any(), gomock.Any()).Return(time.Time{}, nil)
+	mockRepo.EXPECT().GetUserByLogin("testuser").Return(dbUser, nil)
+	mockRepo.EXPECT().ClearLoginAttempts(gomock.Any()).Return(nil)
+	mockRepo.EXPECT().RevertLoginAttempt(gomock.Any()).Return(nil)
+	mockRepo.EXPECT().
+		UpdatePasswordHash(int64(1), string(weakHash), gomock.Any()).
+		Return(errors.New("database error"))
+	mockRepo.EXPECT().StoreRefreshToken(int64(1), gomock.Any(), gomock.Any()).Return(nil)
+
+	body, _ := json.Marshal(domain.User{Login: "testuser", Password: "testpassword"})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.LoginUser(authConfig).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUsersHandler_LoginUser_UnknownLoginVerifiesDummyHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	hasher := &countingHasher{PasswordHasher: testPasswordHasher()}
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, hasher)
+
+	mockRepo.EXPECT().RegisterLoginAttempt(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).Times(2)
+	mockRepo.EXPECT().GetUserByLogin("ghost").Return(nil, domain.ErrNotFound).Times(2)
+
+	for range 2 {
+		body, _ := json.Marshal(domain.User{Login: "ghost", Password: "testpassword"})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.LoginUser(&config.AuthConfig{}).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	assert.Equal(t, 2, hasher.verifies)
+	assert.Equal(t, 1, hasher.hashes)
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxyNet, _ := net.ParseCIDR("10.0.0.0/8")
+	trustedProxies := []*net.IPNet{proxyNet}
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		trustedProxies []*net.IPNet
+		expected       string
+	}{
+		{name: "Direct client", remoteAddr: "192.0.2.1:1234", expected: "192.0.2.1"},
+		{
+			name:         "Forwarded header ignored without trusted proxies",
+			remoteAddr:   "192.0.2.1:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "192.0.2.1",
+		},
+		{
+			name:           "Forwarded header ignored from untrusted peer",
+			remoteAddr:     "192.0.2.1:1234",
+			forwardedFor:   []string{"198.51.100.7"},
+			trustedProxies: trustedProxies,
+			expected:       "192.0.2.1",
+		},
+		{
+			name:           "Client behind trusted proxy",
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   []string{"198.51.100.7"},
+			trustedProxies: trustedProxies,
+			expected:       "198.51.100.7",
+		},
+		{
+			name:           "Spoofed hops left of the client are ignored",
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   []string{"203.0.113.9, 198.51.100.7", "10.0.0.3"},
+			trustedProxies: trustedProxies,
+			expected:       "198.51.100.7",
+		},
+		{
+			name:           "Garbage hop stops the walk",
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   []string{"not-an-ip"},
+			trustedProxies: trustedProxies,
+			expected:       "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			assert.Equal(t, tt.expected, clientIP(req, tt.trustedProxies))
+		})
+	}
+}
+
+func TestUsersHandler_DeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewUsersHandler(logger, mockRepo, testPasswordHasher())
+
+	tests := []struct {
+		name           string
+		userID         int64
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Successful deletion",
+			userID: 1,
+			mockSetup: func() {
+				mockRepo.EXPECT().AnonymizeUser(int64(1)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "User deleted successfully",
+		},
+		{
+			name:   "Database error",
+			userID: 1,
+			mockSetup: func() {
+				mockRepo.EXPECT().AnonymizeUser(int64(1)).Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to delete user",
+		},
+		{
+			name:           "Missing user in context",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.DeleteUser(&config.AuthConfig{}).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Len(t, w.Result().Cookies(), 2)
+			}
+		})
+	}
+}
+
+func TestUsersHandler_DeleteUser_RevokesAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockUsersRepository(ctrl)
+	handler := NewUsersHandler(zap.NewNop().Sugar(), mockRepo, testPasswordHasher())
+
+	authConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  time.Hour,
+		JWTRefreshTokenExpiresIn: time.Hour,
+	}
+	accessToken, _ := auth.GenerateAccessToken(1, authConfig)
+
+	deleted := false
+	mockRepo.EXPECT().
+		GetUserByID(int64(1)).
+		DoAndReturn(func(userID int64) (*domain.DBUser, error) {
+			if deleted {
+				return nil, domain.ErrNotFound
+			}
+			return &domain.DBUser{ID: userID, Login: "testuser"}, nil
+		}).
+		AnyTimes()
+	mockRepo.EXPECT().
+		AnonymizeUser(int64(1)).
+		DoAndReturn(func(int64) error {
+			deleted = true
+			return nil
+		})
+
+	router := chi.NewRouter()
+	router.Use(mw.WithAuth(authConfig, mockRepo))
+	router.Delete("/api/user", handler.DeleteUser(authConfig))
+	router.Post("/api/user/password", handler.ChangePassword(authConfig))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+	req.Header.Set(domain.AuthorizationHeader, domain.BearerPrefix+accessToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body, _ := json.Marshal(domain.PasswordChange{CurrentPassword: "oldpassword", NewPassword: "newpassword"})
+	req = httptest.NewRequest(http.MethodPost, "/api/user/password", bytes.NewReader(body))
+	req.Header.Set(domain.AuthorizationHeader, domain.BearerPrefix+accessToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func testPasswordHasher() auth.PasswordHasher {
+	return auth.NewPasswordHasher(config.PasswordHashingPolicy{
+		Algorithm:  config.PasswordHashBcrypt,
+		BcryptCost: bcrypt.MinCost,
+	})
+}
+
+func hashOf(password string) gomock.Matcher {
+	return gomock.Cond(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	})
+}
+
+type countingHasher struct {
+	auth.PasswordHasher
+	hashes   int
+	verifies int
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	h.hashes++
+	return h.PasswordHasher.Hash(password)
+}
+
+func (h *countingHasher) Verify(encodedHash, password string) (bool, error) {
+	h.verifies++
+	return h.PasswordHasher.Verify(encodedHash, password)
+}
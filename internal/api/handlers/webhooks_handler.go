@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookSecretBytes     = 32
+	minWebhookSecretLength = 16
+	webhookDeliveryLogSize = 100
+)
+
+type WebhooksRepository interface {
+	CreateWebhookSubscription(subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	GetWebhookSubscriptions() ([]*domain.WebhookSubscription, error)
+	DeleteWebhookSubscription(id int64) error
+	GetWebhookDeliveryLog(subscriptionID int64, limit int) ([]*domain.WebhookDeliveryLogEntry, error)
+}
+
+type WebhooksHandler struct {
+	logger *zap.SugaredLogger
+	repo   WebhooksRepository
+}
+
+func NewWebhooksHandler(lgr *zap.SugaredLogger, repo WebhooksRepository) *WebhooksHandler {
+	return &WebhooksHandler{
+		logger: lgr,
+		repo:   repo,
+	}
+}
+
+func (wh *WebhooksHandler) CreateSubscription(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", domain.JSONContentType)
+
+	var subscription domain.WebhookSubscription
+	if err := json.NewDecoder(req.Body).Decode(&subscription); err != nil {
+		problem.Render(w, req, problem.BadRequest("Invalid input"))
+		return
+	}
+
+	if violations := validateWebhookSubscription(&subscription); len(violations) > 0 {
+		problem.Render(w, req, problem.Validation(violations))
+		return
+	}
+
+	if subscription.Secret == "" {
+		secret := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to generate webhook secret"))
+			return
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	created, err := wh.repo.CreateWebhookSubscription(&subscription)
+	if err != nil {
+		problem.Render(w, req, problem.Internal("Failed to create webhook subscription"))
+		return
+	}
+	wh.logger.Infof("Webhook subscription %d created for %s", created.ID, created.URL)
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		wh.logger.Errorf("Failed to encode webhook subscription: %s", err.Error())
+	}
+}
+
+func (wh *WebhooksHandler) GetSubscriptions(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", domain.JSONContentType)
+
+	subscriptions, err := wh.repo.GetWebhookSubscriptions()
+	if err != nil {
+		problem.Render(w, req, problem.Internal("Failed to load webhook subscriptions"))
+		return
+	}
+	if subscriptions == nil {
+		subscriptions = []*domain.WebhookSubscription{}
+	}
+
+	if err := json.NewEncoder(w).Encode(subscriptions); err != nil {
+		problem.Render(w, req, problem.Internal("Failed to encode response"))
+	}
+}
+
+func (wh *WebhooksHandler) DeleteSubscription(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		problem.Render(w, req, problem.BadRequest("Subscription id must be a number"))
+		return
+	}
+
+	err = wh.repo.DeleteWebhookSubscription(id)
+	if errors.Is(err, domain.ErrNotFound) {
+		problem.Render(w, req, problem.Wrap(err, "Webhook subscription not found"))
+		return
+	}
+	if err != nil {
+		problem.Render(w, req, problem.Internal("Failed to delete webhook subscription"))
+		return
+	}
+	wh.logger.Infof("Webhook subscription %d deleted", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wh *WebhooksHandler) GetDeliveryLog(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", domain.JSONContentType)
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		problem.Render(w, req, problem.BadRequest("Subscription id must be a number"))
+		return
+	}
+
+	entries, err := wh.repo.GetWebhookDeliveryLog(id, webhookDeliveryLogSize)
+	if errors.Is(err, domain.ErrNotFound) {
+		problem.Render(w, req, problem.Wrap(err, "Webhook subscription not found"))
+		return
+	}
+	if err != nil {
+		problem.Render(w, req, problem.Internal("Failed to load webhook delivery log"))
+		return
+	}
+	if entries == nil {
+		entries = []*domain.WebhookDeliveryLogEntry{}
+	}
+
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		problem.Render(w, req, problem.Internal("Failed to encode response"))
+	}
+}
+
+func validateWebhookSubscription(subscription *domain.WebhookSubscription) []domain.ValidationViolation {
+	var violations []domain.ValidationViolation
+
+	if endpoint, err := url.Parse(subscription.URL); err != nil ||
+		(endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		violations = append(violations, domain.ValidationViolation{
+			Field: "url", Rule: "url", Message: "url must be an absolute http or https URL",
+		})
+	}
+
+	if len(subscription.EventTypes) == 0 {
+		violations = append(violations, domain.ValidationViolation{
+			Field: "event_types", Rule: "required", Message: "at least one event type is required",
+		})
+	}
+	for _, eventType := range subscription.EventTypes {
+		if !domain.IsKnownWebhookEvent(eventType) {
+			violations = append(violations, domain.ValidationViolation{
+				Field: "event_types", Rule: "enum", Message: fmt.Sprintf("unknown event type %q", eventType),
+			})
+		}
+	}
+
+	if subscription.Secret != "" && len(subscription.Secret) < minWebhookSecretLength {
+		violations = append(violations, domain.ValidationViolation{
+			Field:   "secret",
+			Rule:    "min_length",
+			Message: fmt.Sprintf("secret must be at least %d characters long", minWebhookSecretLength),
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestWebhooksHandler_CreateSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhooksRepository(ctrl)
+	logger := zap.NewNop().Sugar()
+	handler := NewWebhooksHandler(logger, mockRepo)
+
+	created := func(subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+		result := *subscription
+		result.ID = 1
+		result.CreatedAt = time.Now()
+		return &result, nil
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Explicit secret",
+			body: `{"url":"https://example.com/hooks","secret":"0123456789abcdef","event_types":["order.processed"]}`,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateWebhookSubscription(&domain.WebhookSubscription{
+						URL:        "https://example.com/hooks",
+						Secret:     "0123456789abcdef",
+						EventTypes: []string{domain.WebhookOrderProcessed},
+					}).
+					DoAndReturn(created)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `"secret":"0123456789abcdef"`,
+		},
+		{
+			name:           "Invalid url scheme",
+			body:           `{"url":"ftp://example.com/hooks","event_types":["order.processed"]}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"field":"url"`,
+		},
+		{
+			name:           "Unknown event type",
+			body:           `{"url":"https://example.com/hooks","event_types":["order.deleted"]}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "order.deleted",
+		},
+		{
+			name:           "No event types",
+			body:           `{"url":"https://example.com/hooks","event_types":[]}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"field":"event_types"`,
+		},
+		{
+			name:           "Short secret",
+			body:           `{"url":"https://example.com/hooks","secret":"short","event_types":["order.invalid"]}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"field":"secret"`,
+		},
+		{
+			name:           "Malformed body",
+			body:           `{"url":`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Invalid input",
+		},
+		{
+			name: "Database error",
+			body: `{"url":"https://example.com/hooks","event_types":["withdrawal.created"]}`,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateWebhookSubscription(gomock.Any()).
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to create webhook subscription",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handler.CreateSubscription(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestWebhooksHandler_CreateSubscription_GeneratesSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhooksRepository(ctrl)
+	handler := NewWebhooksHandler(zap.NewNop().Sugar(), mockRepo)
+
+	mockRepo.EXPECT().
+		CreateWebhookSubscription(gomock.Any()).
+		DoAndReturn(func(subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+			result := *subscription
+			result.ID = 2
+			return &result, nil
+		})
+
+	body := `{"url":"http://localhost:9000/hooks","event_types":["order.processed","order.invalid"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateSubscription(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var subscription domain.WebhookSubscription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subscription))
+	assert.Len(t, subscription.Secret, 2*webhookSecretBytes)
+}
+
+func TestWebhooksHandler_GetSubscriptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhooksRepository(ctrl)
+	handler := NewWebhooksHandler(zap.NewNop().Sugar(), mockRepo)
+
+	tests := []struct {
+		name           string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Subscriptions listed",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetWebhookSubscriptions().
+					Return([]*domain.WebhookSubscription{
+						{ID: 1, URL: "https://example.com/hooks", EventTypes: []string{domain.WebhookOrderProcessed}},
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"url":"https://example.com/hooks"`,
+		},
+		{
+			name: "No subscriptions",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetWebhookSubscriptions().
+					Return(nil, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "[]",
+		},
+		{
+			name: "Database error",
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetWebhookSubscriptions().
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Failed to load webhook subscriptions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/webhooks", nil)
+			w := httptest.NewRecorder()
+
+			handler.GetSubscriptions(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			assert.NotContains(t, w.Body.String(), "secret")
+		})
+	}
+}
+
+func TestWebhooksHandler_DeleteSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhooksRepository(ctrl)
+	handler := NewWebhooksHandler(zap.NewNop().Sugar(), mockRepo)
+
+	tests := []struct {
+		name           string
+		id             string
+		mockSetup      func()
+		expectedStatus int
+	}{
+		{
+			name: "Deleted",
+			id:   "1",
+			mockSetup: func() {
+				mockRepo.EXPECT().DeleteWebhookSubscription(int64(1)).Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "Not found",
+			id:   "2",
+			mockSetup: func() {
+				mockRepo.EXPECT().DeleteWebhookSubscription(int64(2)).Return(domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid id",
+			id:             "abc",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req := httptest.NewRequest(http.MethodDelete, "/api/admin/webhooks/"+tt.id, nil)
+			w := httptest.NewRecorder()
+
+			handler.DeleteSubscription(w, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestWebhooksHandler_GetDeliveryLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhooksRepository(ctrl)
+	handler := NewWebhooksHandler(zap.NewNop().Sugar(), mockRepo)
+
+	responseStatus := http.StatusOK
+	mockRepo.EXPECT().
+		GetWebhookDeliveryLog(int64(3), webhookDeliveryLogSize).
+		Return([]*domain.WebhookDeliveryLogEntry{
+			{
+				DeliveryID:     1,
+				EventID:        4,
+				EventType:      domain.WebhookWithdrawalCreated,
+				Status:         domain.WebhookDeliveryDelivered,
+				ResponseStatus: &responseStatus,
+				DurationMS:     12,
+			},
+		}, nil)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "3")
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/webhooks/3/deliveries", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetDeliveryLog(w, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"DELIVERED"`)
+	assert.Contains(t, w.Body.String(), `"response_status":200`)
+}
+
+func TestWebhooksHandler_GetDeliveryLog_UnknownSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhooksRepository(ctrl)
+	handler := NewWebhooksHandler(zap.NewNop().Sugar(), mockRepo)
+
+	mockRepo.EXPECT().
+		GetWebhookDeliveryLog(int64(404), webhookDeliveryLogSize).
+		Return(nil, domain.ErrNotFound)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "404")
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/webhooks/404/deliveries", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetDeliveryLog(w, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Webhook subscription not found")
+}
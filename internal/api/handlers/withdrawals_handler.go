@@ -2,29 +2,39 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
 	"github.com/frolmr/gophermart/internal/domain"
-	"github.com/frolmr/gophermart/pkg/formatter"
 	"github.com/frolmr/gophermart/pkg/luhn"
 	"go.uber.org/zap"
 )
 
 type WithdrawalRepository interface {
 	CreateWithdrawal(orderNumber string, sum float64, userID int64) error
-	GetUserCurrentBalance(userID int64) (float64, error)
-	GetAllUserWithdrawals(userID int64) ([]*domain.Withdrawal, error)
+	GetUserWithdrawals(filter domain.WithdrawalFilter) (*domain.WithdrawalPage, error)
+}
+
+type BalanceEventNotifier interface {
+	BalanceChanged(userID int64)
 }
 
 type WithdrawalsHandler struct {
-	logger *zap.SugaredLogger
-	repo   WithdrawalRepository
+	logger   *zap.SugaredLogger
+	repo     WithdrawalRepository
+	notifier BalanceEventNotifier
 }
 
-func NewWithdrawalsHandler(lgr *zap.SugaredLogger, repo WithdrawalRepository) *WithdrawalsHandler {
+func NewWithdrawalsHandler(lgr *zap.SugaredLogger, repo WithdrawalRepository, notifier BalanceEventNotifier) *WithdrawalsHandler {
 	return &WithdrawalsHandler{
-		logger: lgr,
-		repo:   repo,
+		logger:   lgr,
+		repo:     repo,
+		notifier: notifier,
 	}
 }
 
@@ -32,41 +42,35 @@ func (wh *WithdrawalsHandler) RegisterWithdrawal(w http.ResponseWriter, req *htt
 	var withdrawal domain.Withdrawal
 
 	if err := json.NewDecoder(req.Body).Decode(&withdrawal); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		problem.Render(w, req, problem.BadRequest("Invalid request payload"))
 		return
 	}
 
 	if withdrawal.Order == "" || withdrawal.Sum <= 0 {
-		http.Error(w, "Invalid order number or sum", http.StatusBadRequest)
+		problem.Render(w, req, problem.BadRequest("Invalid order number or sum"))
 		return
 	}
 
 	if orderNumberValid := luhn.Check(withdrawal.Order); !orderNumberValid {
-		http.Error(w, "Order number is invalid", http.StatusUnprocessableEntity)
-		return
-	}
-
-	userID, err := formatter.StringToInt64(req.Header.Get(domain.UserIDHeader))
-	if err != nil {
-		http.Error(w, "Invalid user id", http.StatusInternalServerError)
+		problem.Render(w, req, problem.Wrap(domain.ErrInvalidOrderNumber, "Order number is invalid"))
 		return
 	}
 
-	currentBalance, err := wh.repo.GetUserCurrentBalance(userID)
-	if err != nil {
-		http.Error(w, "Failed to check user balance", http.StatusInternalServerError)
-		return
-	}
-
-	if currentBalance < withdrawal.Sum {
-		http.Error(w, "Not enough funds", http.StatusPaymentRequired)
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
 		return
 	}
 
 	if err := wh.repo.CreateWithdrawal(withdrawal.Order, withdrawal.Sum, userID); err != nil {
-		http.Error(w, "Failed to register withdrawal", http.StatusInternalServerError)
+		if errors.Is(err, domain.ErrInsufficientFunds) {
+			problem.Render(w, req, problem.Wrap(err, "Not enough funds"))
+			return
+		}
+		problem.Render(w, req, problem.Internal("Failed to register withdrawal"))
 		return
 	}
+	wh.notifier.BalanceChanged(userID)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -74,22 +78,101 @@ func (wh *WithdrawalsHandler) RegisterWithdrawal(w http.ResponseWriter, req *htt
 //nolint:dupl // Actually code is not the same as in ordes_handler. United code will be more difficult to understand
 func (wh *WithdrawalsHandler) GetWithdrawals(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", domain.JSONContentType)
-	userID, err := formatter.StringToInt64(req.Header.Get(domain.UserIDHeader))
+	userID, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return
+	}
+
+	filter, err := withdrawalFilterFromQuery(req.URL.Query(), userID)
+	if err != nil {
+		problem.Render(w, req, problem.BadRequest(err.Error()))
+		return
+	}
+
+	// The legacy contract is a bare array, so the summary envelope is opt-in.
+	withSummary, err := parseSummaryFlag(req.URL.Query())
 	if err != nil {
-		http.Error(w, "Invalid user id", http.StatusInternalServerError)
+		problem.Render(w, req, problem.BadRequest(err.Error()))
 		return
 	}
 
-	withdrawals, err := wh.repo.GetAllUserWithdrawals(userID)
+	page, err := wh.repo.GetUserWithdrawals(filter)
 	if err != nil {
-		http.Error(w, "Failed to load withdrawals", http.StatusInternalServerError)
+		problem.Render(w, req, problem.Internal("Failed to load withdrawals"))
 		return
 	}
-	if len(withdrawals) > 0 {
-		if err := json.NewEncoder(w).Encode(withdrawals); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+
+	w.Header().Set(domain.SummaryCountHeader, strconv.FormatInt(page.Summary.Count, 10))
+	w.Header().Set(domain.SummaryTotalHeader, strconv.FormatFloat(page.Summary.Total, 'f', 2, 64))
+
+	if withSummary {
+		setNextPageHeaders(w, req, page.NextCursor)
+		if err := json.NewEncoder(w).Encode(withdrawalHistory(page)); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to encode response"))
+		}
+		return
+	}
+
+	if len(page.Withdrawals) > 0 {
+		setNextPageHeaders(w, req, page.NextCursor)
+		if err := json.NewEncoder(w).Encode(page.Withdrawals); err != nil {
+			problem.Render(w, req, problem.Internal("Failed to encode response"))
 		}
 	} else {
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+func withdrawalFilterFromQuery(query url.Values, userID int64) (domain.WithdrawalFilter, error) {
+	filter := domain.WithdrawalFilter{UserID: userID}
+
+	var err error
+	if filter.Limit, err = parsePageLimit(query); err != nil {
+		return domain.WithdrawalFilter{}, err
+	}
+	if filter.After, err = parseCursor(query); err != nil {
+		return domain.WithdrawalFilter{}, err
+	}
+	if filter.Processed, err = parseTimeRange(query, "from", "to"); err != nil {
+		return domain.WithdrawalFilter{}, err
+	}
+
+	if value := query.Get("min_sum"); value != "" {
+		filter.MinSum, err = strconv.ParseFloat(value, 64)
+		if err != nil || filter.MinSum < 0 || math.IsInf(filter.MinSum, 0) || math.IsNaN(filter.MinSum) {
+			return domain.WithdrawalFilter{}, errors.New("min_sum must be a non-negative number")
+		}
+	}
+
+	return filter, nil
+}
+
+func parseSummaryFlag(query url.Values) (bool, error) {
+	value := query.Get("summary")
+	if value == "" {
+		return false, nil
+	}
+
+	withSummary, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("summary must be true or false")
+	}
+
+	return withSummary, nil
+}
+
+func withdrawalHistory(page *domain.WithdrawalPage) *domain.WithdrawalHistory {
+	history := &domain.WithdrawalHistory{
+		Withdrawals: page.Withdrawals,
+		Summary:     page.Summary,
+	}
+	if history.Withdrawals == nil {
+		history.Withdrawals = []*domain.Withdrawal{}
+	}
+	if page.NextCursor != nil {
+		history.NextCursor = page.NextCursor.Encode()
+	}
+
+	return history
+}
@@ -3,11 +3,14 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/frolmr/gophermart/internal/api/auth"
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/internal/mocks"
 	"github.com/stretchr/testify/assert"
@@ -20,13 +23,14 @@ func TestWithdrawalsHandler_RegisterWithdrawal(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mocks.NewMockWithdrawalRepository(ctrl)
+	mockNotifier := mocks.NewMockBalanceEventNotifier(ctrl)
 	logger := zap.NewNop().Sugar()
-	handler := NewWithdrawalsHandler(logger, mockRepo)
+	handler := NewWithdrawalsHandler(logger, mockRepo, mockNotifier)
 
 	tests := []struct {
 		name           string
 		withdrawal     domain.Withdrawal
-		userID         string
+		userID         int64
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
@@ -37,15 +41,12 @@ func TestWithdrawalsHandler_RegisterWithdrawal(t *testing.T) {
 				Order: "12345678903",
 				Sum:   50.0,
 			},
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
-				mockRepo.EXPECT().
-					GetUserCurrentBalance(int64(1)).
-					Return(100.0, nil)
-
 				mockRepo.EXPECT().
 					CreateWithdrawal("12345678903", 50.0, int64(1)).
 					Return(nil)
+				mockNotifier.EXPECT().BalanceChanged(int64(1))
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
@@ -56,7 +57,7 @@ func TestWithdrawalsHandler_RegisterWithdrawal(t *testing.T) {
 				Order: "12345678902",
 				Sum:   50.0,
 			},
-			userID:         "1",
+			userID:         1,
 			mockSetup:      func() {},
 			expectedStatus: http.StatusUnprocessableEntity,
 			expectedBody:   "Order number is invalid",
@@ -67,25 +68,39 @@ func TestWithdrawalsHandler_RegisterWithdrawal(t *testing.T) {
 				Order: "12345678903",
 				Sum:   150.0,
 			},
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetUserCurrentBalance(int64(1)).
-					Return(100.0, nil)
+					CreateWithdrawal("12345678903", 150.0, int64(1)).
+					Return(domain.ErrInsufficientFunds)
 			},
 			expectedStatus: http.StatusPaymentRequired,
-			expectedBody:   "Not enough funds",
+			expectedBody:   `"detail":"Not enough funds","instance":"/withdrawals","code":"insufficient_funds"`,
 		},
 		{
-			name: "Invalid user ID",
+			name: "Database error",
 			withdrawal: domain.Withdrawal{
 				Order: "12345678903",
 				Sum:   50.0,
 			},
-			userID:         "invalid",
-			mockSetup:      func() {},
+			userID: 1,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					CreateWithdrawal("12345678903", 50.0, int64(1)).
+					Return(errors.New("database error"))
+			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "Invalid user id",
+			expectedBody:   "Failed to register withdrawal",
+		},
+		{
+			name: "Missing user in context",
+			withdrawal: domain.Withdrawal{
+				Order: "12345678903",
+				Sum:   50.0,
+			},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
 		},
 	}
 
@@ -95,7 +110,9 @@ func TestWithdrawalsHandler_RegisterWithdrawal(t *testing.T) {
 
 			body, _ := json.Marshal(tt.withdrawal)
 			req := httptest.NewRequest(http.MethodPost, "/withdrawals", bytes.NewReader(body))
-			req.Header.Set(domain.UserIDHeader, tt.userID)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
 			w := httptest.NewRecorder()
 
 			handler.RegisterWithdrawal(w, req)
@@ -114,24 +131,27 @@ func TestWithdrawalsHandler_GetWithdrawals(t *testing.T) {
 
 	mockRepo := mocks.NewMockWithdrawalRepository(ctrl)
 	logger := zap.NewNop().Sugar()
-	handler := NewWithdrawalsHandler(logger, mockRepo)
+	handler := NewWithdrawalsHandler(logger, mockRepo, nil)
 
 	tests := []struct {
 		name           string
-		userID         string
+		userID         int64
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name:   "Successful retrieval of withdrawals",
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetAllUserWithdrawals(int64(1)).
-					Return([]*domain.Withdrawal{
-						{Order: "12345678903", Sum: 50.0, ProcessedAt: time.Now()},
-						{Order: "98765432103", Sum: 30.0, ProcessedAt: time.Now()},
+					GetUserWithdrawals(domain.WithdrawalFilter{UserID: 1}).
+					Return(&domain.WithdrawalPage{
+						Withdrawals: []*domain.Withdrawal{
+							{Order: "12345678903", Sum: 50.0, ProcessedAt: time.Now()},
+							{Order: "98765432103", Sum: 30.0, ProcessedAt: time.Now()},
+						},
+						Summary: domain.WithdrawalSummary{Count: 2, Total: 80},
 					}, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -139,21 +159,20 @@ func TestWithdrawalsHandler_GetWithdrawals(t *testing.T) {
 		},
 		{
 			name:   "No withdrawals found",
-			userID: "1",
+			userID: 1,
 			mockSetup: func() {
 				mockRepo.EXPECT().
-					GetAllUserWithdrawals(int64(1)).
-					Return([]*domain.Withdrawal{}, nil)
+					GetUserWithdrawals(domain.WithdrawalFilter{UserID: 1}).
+					Return(&domain.WithdrawalPage{}, nil)
 			},
 			expectedStatus: http.StatusNoContent,
 			expectedBody:   "",
 		},
 		{
-			name:           "Invalid user ID",
-			userID:         "invalid",
+			name:           "Missing user in context",
 			mockSetup:      func() {},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "Invalid user id",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized",
 		},
 	}
 
@@ -162,7 +181,9 @@ func TestWithdrawalsHandler_GetWithdrawals(t *testing.T) {
 			tt.mockSetup()
 
 			req := httptest.NewRequest(http.MethodGet, "/withdrawals", nil)
-			req.Header.Set(domain.UserIDHeader, tt.userID)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
 			w := httptest.NewRecorder()
 
 			handler.GetWithdrawals(w, req)
@@ -174,3 +195,159 @@ func TestWithdrawalsHandler_GetWithdrawals(t *testing.T) {
 		})
 	}
 }
+
+func TestWithdrawalsHandler_GetWithdrawals_PaginationAndSummary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWithdrawalRepository(ctrl)
+	handler := NewWithdrawalsHandler(zap.NewNop().Sugar(), mockRepo, nil)
+
+	next := &domain.Cursor{Time: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), ID: 17}
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.EXPECT().
+		GetUserWithdrawals(domain.WithdrawalFilter{
+			UserID:    1,
+			Processed: domain.TimeRange{From: &from, To: &to},
+			MinSum:    25.5,
+			Limit:     1,
+		}).
+		Return(&domain.WithdrawalPage{
+			Withdrawals: []*domain.Withdrawal{{Order: "12345678903", Sum: 50.0, ProcessedAt: time.Now()}},
+			NextCursor:  next,
+			Summary:     domain.WithdrawalSummary{Count: 3, Total: 125.5},
+		}, nil)
+
+	query := url.Values{
+		"limit":   {"1"},
+		"from":    {"2024-05-01"},
+		"to":      {"2024-05-31"},
+		"min_sum": {"25.5"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals?"+query.Encode(), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.GetWithdrawals(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get(domain.SummaryCountHeader))
+	assert.Equal(t, "125.50", w.Header().Get(domain.SummaryTotalHeader))
+	assert.Equal(t, next.Encode(), w.Header().Get(domain.NextCursorHeader))
+
+	query.Set("cursor", next.Encode())
+	assert.Equal(t, `</api/user/withdrawals?`+query.Encode()+`>; rel="next"`, w.Header().Get("Link"))
+}
+
+func TestWithdrawalsHandler_GetWithdrawals_EmptyPageKeepsSummary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWithdrawalRepository(ctrl)
+	handler := NewWithdrawalsHandler(zap.NewNop().Sugar(), mockRepo, nil)
+
+	mockRepo.EXPECT().
+		GetUserWithdrawals(gomock.Any()).
+		Return(&domain.WithdrawalPage{Summary: domain.WithdrawalSummary{Count: 0, Total: 0}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals?min_sum=1000", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.GetWithdrawals(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "0", w.Header().Get(domain.SummaryCountHeader))
+	assert.Equal(t, "0.00", w.Header().Get(domain.SummaryTotalHeader))
+	assert.Empty(t, w.Header().Get("Link"))
+}
+
+func TestWithdrawalsHandler_GetWithdrawals_SummaryInBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWithdrawalRepository(ctrl)
+	handler := NewWithdrawalsHandler(zap.NewNop().Sugar(), mockRepo, nil)
+
+	processedAt := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	next := &domain.Cursor{Time: processedAt, ID: 17}
+
+	mockRepo.EXPECT().
+		GetUserWithdrawals(domain.WithdrawalFilter{UserID: 1, Limit: 1}).
+		Return(&domain.WithdrawalPage{
+			Withdrawals: []*domain.Withdrawal{{Order: "12345678903", Sum: 50.0, ProcessedAt: processedAt}},
+			NextCursor:  next,
+			Summary:     domain.WithdrawalSummary{Count: 3, Total: 125.5},
+		}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals?limit=1&summary=true", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.GetWithdrawals(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"withdrawals": [{"order": "12345678903", "sum": 50, "processed_at": "2024-05-02T10:00:00Z"}],
+		"summary": {"count": 3, "total": 125.5},
+		"next_cursor": "`+next.Encode()+`"
+	}`, w.Body.String())
+	assert.Equal(t, next.Encode(), w.Header().Get(domain.NextCursorHeader))
+}
+
+func TestWithdrawalsHandler_GetWithdrawals_SummaryForEmptyWindow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWithdrawalRepository(ctrl)
+	handler := NewWithdrawalsHandler(zap.NewNop().Sugar(), mockRepo, nil)
+
+	mockRepo.EXPECT().
+		GetUserWithdrawals(domain.WithdrawalFilter{UserID: 1, MinSum: 1000}).
+		Return(&domain.WithdrawalPage{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals?min_sum=1000&summary=true", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), 1))
+	w := httptest.NewRecorder()
+
+	handler.GetWithdrawals(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"withdrawals": [], "summary": {"count": 0, "total": 0}}`, w.Body.String())
+}
+
+func TestWithdrawalsHandler_GetWithdrawals_InvalidQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := NewWithdrawalsHandler(zap.NewNop().Sugar(), mocks.NewMockWithdrawalRepository(ctrl), nil)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedBody string
+	}{
+		{name: "Limit too large", query: "limit=100000", expectedBody: "limit must be between 1 and"},
+		{name: "Malformed cursor", query: "cursor=%21%21", expectedBody: "cursor is malformed"},
+		{name: "Negative min_sum", query: "min_sum=-1", expectedBody: "min_sum must be a non-negative number"},
+		{name: "Non-numeric min_sum", query: "min_sum=lots", expectedBody: "min_sum must be a non-negative number"},
+		{name: "NaN min_sum", query: "min_sum=NaN", expectedBody: "min_sum must be a non-negative number"},
+		{name: "Empty range", query: "from=2024-06-01&to=2024-05-01", expectedBody: "from must be before to"},
+		{name: "Non-boolean summary", query: "summary=yes", expectedBody: "summary must be true or false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals?"+tt.query, nil)
+			req = req.WithContext(auth.WithUserID(req.Context(), 1))
+			w := httptest.NewRecorder()
+
+			handler.GetWithdrawals(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
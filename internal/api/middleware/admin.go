@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+func WithAdminToken(authCfg *config.AuthConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			header := req.Header.Get(domain.AuthorizationHeader)
+			token := strings.TrimPrefix(header, domain.BearerPrefix)
+
+			if authCfg.AdminToken == "" || token == header ||
+				subtle.ConstantTimeCompare([]byte(token), []byte(authCfg.AdminToken)) != 1 {
+				problem.Render(w, req, problem.New(http.StatusForbidden, problem.CodeForbidden, "Forbidden"))
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithAdminToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		adminToken     string
+		header         string
+		expectedStatus int
+	}{
+		{name: "Valid token", adminToken: "admin-secret", header: "Bearer admin-secret", expectedStatus: http.StatusOK},
+		{name: "Wrong token", adminToken: "admin-secret", header: "Bearer guess", expectedStatus: http.StatusForbidden},
+		{name: "Missing bearer prefix", adminToken: "admin-secret", header: "admin-secret", expectedStatus: http.StatusForbidden},
+		{name: "Admin token not configured", adminToken: "", header: "Bearer ", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/admin/login-lockouts", nil)
+			req.Header.Set(domain.AuthorizationHeader, tt.header)
+			w := httptest.NewRecorder()
+
+			WithAdminToken(&config.AuthConfig{AdminToken: tt.adminToken})(next).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
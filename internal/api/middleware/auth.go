@@ -1,71 +1,101 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/api/problem"
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/domain"
-	"github.com/frolmr/gophermart/pkg/formatter"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func WithAuth(authCfg *config.AuthConfig) func(next http.Handler) http.Handler {
+type AuthRepository interface {
+	GetRefreshToken(token string) (*domain.RefreshToken, error)
+	GetUserByID(userID int64) (*domain.DBUser, error)
+}
+
+func WithAuth(authCfg *config.AuthConfig, repo AuthRepository) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, req *http.Request) {
-			accessCookie, err := req.Cookie("access_token")
-			if err != nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			accessTokenString := accessTokenFromRequest(req, authCfg)
+			if accessTokenString == "" {
+				problem.Render(w, req, problem.Unauthorized("Unauthorized"))
 				return
 			}
 
-			accessTokenString := accessCookie.Value
-
-			claims := &auth.Claims{}
-			accessToken, err := jwt.ParseWithClaims(accessTokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return authCfg.JWTKey, nil
-			})
-
-			if err != nil || !accessToken.Valid {
-				refreshCookie, err := req.Cookie("refresh_token")
-				if err != nil {
-					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			claims, err := auth.ParseAccessToken(accessTokenString, authCfg)
+			refreshed := err != nil
+			if refreshed {
+				var ok bool
+				if claims, ok = claimsFromRefreshCookie(w, req, authCfg, repo); !ok {
 					return
 				}
+			}
 
-				refreshTokenString := refreshCookie.Value
-
-				refreshToken, err := jwt.ParseWithClaims(refreshTokenString, claims, func(token *jwt.Token) (interface{}, error) {
-					return authCfg.JWTKey, nil
-				})
-
-				if err != nil || !refreshToken.Valid {
-					http.Error(w, "Unauthorized", http.StatusUnauthorized)
-					return
+			// Access tokens outlive account deletion, so every request checks that the user still exists.
+			if _, err := repo.GetUserByID(claims.UserID); err != nil {
+				if errors.Is(err, domain.ErrNotFound) {
+					problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+				} else {
+					problem.Render(w, req, problem.Internal("Database error"))
 				}
+				return
+			}
 
+			if refreshed {
 				newAccessToken, err := auth.GenerateAccessToken(claims.UserID, authCfg)
 				if err != nil {
-					http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+					problem.Render(w, req, problem.Internal("Failed to generate access token"))
 					return
 				}
 
-				http.SetCookie(w, &http.Cookie{
-					Name:     "access_token",
-					Value:    newAccessToken,
-					Expires:  time.Now().Add(authCfg.JWTAccessTokenExpiresIn),
-					HttpOnly: true,
-					Path:     "/",
-				})
+				auth.SetTokenCookie(w, authCfg, auth.AccessTokenCookie, newAccessToken, authCfg.JWTAccessTokenExpiresIn)
 
-				req.Header.Set("Authorization", "Bearer "+newAccessToken)
+				req.Header.Set(domain.AuthorizationHeader, domain.BearerPrefix+newAccessToken)
 			}
 
-			req.Header.Set(domain.UserIDHeader, formatter.Int64ToString(claims.UserID))
-			next.ServeHTTP(w, req)
+			next.ServeHTTP(w, req.WithContext(auth.WithUserID(req.Context(), claims.UserID)))
 		}
 
 		return http.HandlerFunc(fn)
 	}
 }
+
+func claimsFromRefreshCookie(w http.ResponseWriter, req *http.Request, authCfg *config.AuthConfig, repo AuthRepository) (*auth.Claims, bool) {
+	refreshTokenString := auth.TokenFromCookie(req, authCfg, auth.RefreshTokenCookie)
+	if refreshTokenString == "" {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return nil, false
+	}
+
+	claims, err := auth.ParseRefreshToken(refreshTokenString, authCfg)
+	if err != nil {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return nil, false
+	}
+
+	storedToken, err := repo.GetRefreshToken(refreshTokenString)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		problem.Render(w, req, problem.Internal("Database error"))
+		return nil, false
+	}
+
+	if storedToken == nil || storedToken.UsedAt != nil || storedToken.UserID != claims.UserID ||
+		storedToken.ExpiresAt.Before(time.Now()) {
+		problem.Render(w, req, problem.Unauthorized("Unauthorized"))
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func accessTokenFromRequest(req *http.Request, authCfg *config.AuthConfig) string {
+	if header := req.Header.Get(domain.AuthorizationHeader); strings.HasPrefix(header, domain.BearerPrefix) {
+		return strings.TrimSpace(strings.TrimPrefix(header, domain.BearerPrefix))
+	}
+
+	return auth.TokenFromCookie(req, authCfg, auth.AccessTokenCookie)
+}
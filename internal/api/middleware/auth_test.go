@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/api/auth"
+	"github.com/frolmr/gophermart/internal/config"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestWithAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockAuthRepository(ctrl)
+
+	authConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  time.Hour,
+		JWTRefreshTokenExpiresIn: time.Hour,
+	}
+	expiredConfig := &config.AuthConfig{
+		JWTKey:                   []byte("secret"),
+		JWTAccessTokenExpiresIn:  -time.Minute,
+		JWTRefreshTokenExpiresIn: -time.Minute,
+	}
+
+	validAccessToken, _ := auth.GenerateAccessToken(1, authConfig)
+	expiredAccessToken, _ := auth.GenerateAccessToken(1, expiredConfig)
+	validRefreshToken, _ := auth.GenerateRefreshToken(1, authConfig)
+
+	tests := []struct {
+		name            string
+		bearerToken     string
+		accessToken     string
+		refreshToken    string
+		mockSetup       func()
+		expectedStatus  int
+		expectedUserID  int64
+		expectNewAccess bool
+	}{
+		{
+			name:        "Valid access token",
+			accessToken: validAccessToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserByID(int64(1)).
+					Return(&domain.DBUser{ID: 1, Login: "testuser"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedUserID: 1,
+		},
+		{
+			name:        "Valid bearer token",
+			bearerToken: validAccessToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserByID(int64(1)).
+					Return(&domain.DBUser{ID: 1, Login: "testuser"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedUserID: 1,
+		},
+		{
+			name:           "Bearer token takes precedence over cookie",
+			bearerToken:    "malformed-token",
+			accessToken:    validAccessToken,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "Deleted user with valid access token",
+			accessToken: validAccessToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserByID(int64(1)).
+					Return(nil, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Deleted user with stored refresh token",
+			accessToken:  expiredAccessToken,
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(&domain.RefreshToken{ID: 1, UserID: 1, Token: validRefreshToken, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+				mockRepo.EXPECT().
+					GetUserByID(int64(1)).
+					Return(nil, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "Database error on user lookup",
+			accessToken: validAccessToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetUserByID(int64(1)).
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Refresh token as bearer token",
+			bearerToken:    validRefreshToken,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Refresh token in access token cookie",
+			accessToken:    validRefreshToken,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Access token in refresh token cookie",
+			accessToken:    expiredAccessToken,
+			refreshToken:   validAccessToken,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "No access token",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Expired access token with stored refresh token",
+			accessToken:  expiredAccessToken,
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(&domain.RefreshToken{ID: 1, UserID: 1, Token: validRefreshToken, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+				mockRepo.EXPECT().
+					GetUserByID(int64(1)).
+					Return(&domain.DBUser{ID: 1, Login: "testuser"}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedUserID:  1,
+			expectNewAccess: true,
+		},
+		{
+			name:         "Expired access token with revoked refresh token",
+			accessToken:  expiredAccessToken,
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(nil, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Expired access token with already rotated refresh token",
+			accessToken:  expiredAccessToken,
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				usedAt := time.Now().Add(-time.Minute)
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(&domain.RefreshToken{
+						ID: 1, UserID: 1, Token: validRefreshToken, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt,
+					}, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Refresh token stored for another user",
+			accessToken:  expiredAccessToken,
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(&domain.RefreshToken{ID: 1, UserID: 2, Token: validRefreshToken, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Database error on refresh token lookup",
+			accessToken:  expiredAccessToken,
+			refreshToken: validRefreshToken,
+			mockSetup: func() {
+				mockRepo.EXPECT().
+					GetRefreshToken(validRefreshToken).
+					Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			var gotUserID int64
+			next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				gotUserID, _ = auth.UserFromContext(req.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			if tt.bearerToken != "" {
+				req.Header.Set(domain.AuthorizationHeader, domain.BearerPrefix+tt.bearerToken)
+			}
+			if tt.accessToken != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.accessToken})
+			}
+			if tt.refreshToken != "" {
+				req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tt.refreshToken})
+			}
+			w := httptest.NewRecorder()
+
+			WithAuth(authConfig, mockRepo)(next).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedUserID, gotUserID)
+
+			var newAccessCookie *http.Cookie
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.Name == "access_token" {
+					newAccessCookie = cookie
+				}
+			}
+			assert.Equal(t, tt.expectNewAccess, newAccessCookie != nil)
+		})
+	}
+}
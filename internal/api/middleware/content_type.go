@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/frolmr/gophermart/internal/api/problem"
+)
+
+func AllowContentType(contentTypes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			if req.ContentLength == 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			mediaType, _, _ := strings.Cut(req.Header.Get("Content-Type"), ";")
+			if !slices.Contains(contentTypes, strings.ToLower(strings.TrimSpace(mediaType))) {
+				problem.Render(w, req, problem.New(
+					http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "Unsupported content type",
+				))
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/api/problem"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowContentType(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Allowed type", contentType: "application/json", body: "{}", expectedStatus: http.StatusOK},
+		{name: "Allowed type with params", contentType: "Application/JSON; charset=utf-8", body: "{}", expectedStatus: http.StatusOK},
+		{name: "Empty body", contentType: "", body: "", expectedStatus: http.StatusOK},
+		{name: "Wrong type", contentType: "text/plain", body: "{}", expectedStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			AllowContentType(domain.JSONContentType)(next).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), `"code":"unsupported_media_type"`)
+			}
+		})
+	}
+}
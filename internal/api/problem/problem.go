@@ -0,0 +1,159 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+const (
+	ContentType = "application/problem+json"
+
+	defaultType   = "about:blank"
+	defaultDetail = "Internal server error"
+)
+
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeUserExists           = "user_exists"
+	CodeOrderAlreadyUploaded = "order_already_uploaded"
+	CodeInsufficientFunds    = "insufficient_funds"
+	CodeInvalidOrderNumber   = "invalid_order_number"
+	CodeRefreshTokenReused   = "refresh_token_reused"
+	CodeTooManyLoginAttempts = "too_many_login_attempts"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInternal             = "internal_error"
+)
+
+type Details struct {
+	Type       string                       `json:"type"`
+	Title      string                       `json:"title"`
+	Status     int                          `json:"status"`
+	Detail     string                       `json:"detail,omitempty"`
+	Instance   string                       `json:"instance,omitempty"`
+	Code       string                       `json:"code"`
+	Violations []domain.ValidationViolation `json:"violations,omitempty"`
+}
+
+type Error struct {
+	Status     int
+	Code       string
+	Detail     string
+	Violations []domain.ValidationViolation
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var sentinels = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{domain.ErrUserExists, http.StatusConflict, CodeUserExists},
+	{domain.ErrOrderUploadedByAnotherUser, http.StatusConflict, CodeOrderAlreadyUploaded},
+	{domain.ErrConflict, http.StatusConflict, CodeConflict},
+	{domain.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{domain.ErrInsufficientFunds, http.StatusPaymentRequired, CodeInsufficientFunds},
+	{domain.ErrInvalidOrderNumber, http.StatusUnprocessableEntity, CodeInvalidOrderNumber},
+	{domain.ErrRefreshTokenReused, http.StatusUnauthorized, CodeRefreshTokenReused},
+	{domain.ErrRefreshTokenInvalid, http.StatusUnauthorized, CodeUnauthorized},
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func Wrap(err error, detail string) *Error {
+	return &Error{Detail: detail, Err: err}
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Internal(detail string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail)
+}
+
+func Validation(violations []domain.ValidationViolation) *Error {
+	return &Error{
+		Status:     http.StatusBadRequest,
+		Code:       CodeValidationFailed,
+		Detail:     "validation failed",
+		Violations: violations,
+	}
+}
+
+func Render(w http.ResponseWriter, req *http.Request, err error) {
+	details := detailsFromError(err)
+	if req != nil {
+		details.Instance = req.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(details.Status)
+	_ = json.NewEncoder(w).Encode(details)
+}
+
+func detailsFromError(err error) Details {
+	details := Details{Type: defaultType}
+
+	var problemErr *Error
+	if errors.As(err, &problemErr) {
+		details.Status = problemErr.Status
+		details.Code = problemErr.Code
+		details.Detail = problemErr.Detail
+		details.Violations = problemErr.Violations
+	}
+
+	if details.Status == 0 || details.Code == "" {
+		status, code, known := statusForError(err)
+		if !known && details.Detail == "" {
+			details.Detail = defaultDetail
+		}
+		if details.Status == 0 {
+			details.Status = status
+		}
+		if details.Code == "" {
+			details.Code = code
+		}
+	}
+
+	details.Title = http.StatusText(details.Status)
+
+	return details
+}
+
+func statusForError(err error) (int, string, bool) {
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.status, sentinel.code, true
+		}
+	}
+
+	return http.StatusInternalServerError, CodeInternal, false
+}
@@ -0,0 +1,100 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected Details
+	}{
+		{
+			name: "Explicit problem",
+			err:  BadRequest("Invalid request payload"),
+			expected: Details{
+				Status: http.StatusBadRequest,
+				Code:   CodeInvalidRequest,
+				Detail: "Invalid request payload",
+			},
+		},
+		{
+			name: "Wrapped sentinel keeps its detail",
+			err:  Wrap(domain.ErrInsufficientFunds, "Not enough funds"),
+			expected: Details{
+				Status: http.StatusPaymentRequired,
+				Code:   CodeInsufficientFunds,
+				Detail: "Not enough funds",
+			},
+		},
+		{
+			name:     "Specific conflict",
+			err:      fmt.Errorf("error creating user: %w", domain.ErrUserExists),
+			expected: Details{Status: http.StatusConflict, Code: CodeUserExists},
+		},
+		{
+			name:     "Generic conflict",
+			err:      domain.ErrConflict,
+			expected: Details{Status: http.StatusConflict, Code: CodeConflict},
+		},
+		{
+			name:     "Not found",
+			err:      domain.ErrNotFound,
+			expected: Details{Status: http.StatusNotFound, Code: CodeNotFound},
+		},
+		{
+			name:     "Invalid order number",
+			err:      domain.ErrInvalidOrderNumber,
+			expected: Details{Status: http.StatusUnprocessableEntity, Code: CodeInvalidOrderNumber},
+		},
+		{
+			name: "Unknown error is not leaked",
+			err:  errors.New("pq: connection refused"),
+			expected: Details{
+				Status: http.StatusInternalServerError,
+				Code:   CodeInternal,
+				Detail: "Internal server error",
+			},
+		},
+		{
+			name: "Validation violations",
+			err:  Validation([]domain.ValidationViolation{{Field: "password", Rule: "min_length", Message: "too short"}}),
+			expected: Details{
+				Status:     http.StatusBadRequest,
+				Code:       CodeValidationFailed,
+				Detail:     "validation failed",
+				Violations: []domain.ValidationViolation{{Field: "password", Rule: "min_length", Message: "too short"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			w := httptest.NewRecorder()
+
+			Render(w, req, tt.err)
+
+			assert.Equal(t, tt.expected.Status, w.Code)
+			assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+			var details Details
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&details))
+
+			tt.expected.Type = "about:blank"
+			tt.expected.Title = http.StatusText(tt.expected.Status)
+			tt.expected.Instance = "/api/user/orders"
+			assert.Equal(t, tt.expected, details)
+		})
+	}
+}
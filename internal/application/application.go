@@ -4,17 +4,23 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/frolmr/gophermart/internal/api"
 	"github.com/frolmr/gophermart/internal/client"
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/db/migrator"
+	"github.com/frolmr/gophermart/internal/events"
 	"github.com/frolmr/gophermart/internal/service"
 	"github.com/frolmr/gophermart/internal/storage"
 	"github.com/go-resty/resty/v2"
 	"go.uber.org/zap"
 )
 
+const (
+	webhookTimeout = 10 * time.Second
+)
+
 type App struct {
 	config        *config.AppConfig
 	logger        *zap.SugaredLogger
@@ -22,6 +28,9 @@ type App struct {
 	api           *api.API
 	accrualClient *client.AccrualClient
 	orderP        *service.OrderProcessor
+	balanceR      *service.BalanceReconciler
+	eventsL       *events.Listener
+	webhookD      *service.WebhookDispatcher
 }
 
 func NewApp() (*App, error) {
@@ -44,14 +53,18 @@ func NewApp() (*App, error) {
 	}
 
 	stor := storage.NewStorage(db, lgr)
+	broker := events.NewBroker(lgr)
 
-	srv, err := api.NewAPI(lgr, conf, stor)
+	srv, err := api.NewAPI(lgr, conf, stor, broker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup api: %w", err)
 	}
 
 	client := client.NewAccrualClient(resty.New(), conf, lgr)
-	orderP := service.NewOrderProcessor(lgr, stor, client)
+	orderP := service.NewOrderProcessor(lgr, stor, client, events.NewNotifier(lgr, stor), conf.OrderWorkersCount)
+	balanceR := service.NewBalanceReconciler(lgr, stor)
+	eventsL := events.NewListener(lgr, conf.DatabaseURI, broker)
+	webhookD := service.NewWebhookDispatcher(lgr, stor, resty.New().SetTimeout(webhookTimeout))
 
 	return &App{
 		config:        conf,
@@ -60,6 +73,9 @@ func NewApp() (*App, error) {
 		api:           srv,
 		accrualClient: client,
 		orderP:        orderP,
+		balanceR:      balanceR,
+		eventsL:       eventsL,
+		webhookD:      webhookD,
 	}, nil
 }
 
@@ -71,6 +87,18 @@ func (app *App) RunOrdersWorker(stopCh <-chan struct{}, wg *sync.WaitGroup) {
 	app.orderP.Run(stopCh, wg)
 }
 
+func (app *App) RunBalanceReconciler(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	app.balanceR.Run(stopCh, wg)
+}
+
+func (app *App) RunEventsListener(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	app.eventsL.Run(stopCh, wg)
+}
+
+func (app *App) RunWebhookDispatcher(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	app.webhookD.Run(stopCh, wg)
+}
+
 func setupLogger() (*zap.SugaredLogger, error) {
 	l, err := zap.NewDevelopment()
 
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/frolmr/gophermart/internal/domain"
@@ -11,6 +13,18 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	defaultRetryAfter = 60 * time.Second
+)
+
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("accrual system rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
 type AccrualClient struct {
 	httpClient *resty.Client
 	config     *config.AppConfig
@@ -45,8 +59,28 @@ func (ac AccrualClient) RequestOrderState(number string) (*domain.AccrualOrder,
 		return orderResp, nil
 	} else if resp.StatusCode() == http.StatusNoContent {
 		return nil, nil
+	} else if resp.StatusCode() == http.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(resp.Header().Get("Retry-After"))
+		ac.logger.Warnf("Accrual system rate limit exceeded, retry after %s", retryAfter)
+		return nil, &RateLimitError{RetryAfter: retryAfter}
 	} else {
 		ac.logger.Error("Accrual system responsed error ", resp.StatusCode(), resp.Body())
 		return nil, errors.New("Accrual system responded with status code: " + resp.Status())
 	}
 }
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return defaultRetryAfter
+}
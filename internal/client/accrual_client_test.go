@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/frolmr/gophermart/internal/config"
 	"github.com/go-resty/resty/v2"
@@ -68,3 +69,53 @@ func TestRequestOrderState_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, order)
 }
+
+func TestRequestOrderState_TooManyRequests(t *testing.T) {
+	client, teardown := setupTest()
+	defer teardown()
+
+	responder := httpmock.NewStringResponder(http.StatusTooManyRequests, "No more than N requests per minute allowed").
+		HeaderSet(http.Header{"Retry-After": []string{"30"}})
+	httpmock.RegisterResponder("GET", "http://accrual-system/api/orders/123", responder)
+
+	order, err := client.RequestOrderState("123")
+
+	var rateLimitErr *RateLimitError
+	assert.ErrorAs(t, err, &rateLimitErr)
+	assert.Equal(t, 30*time.Second, rateLimitErr.RetryAfter)
+	assert.Nil(t, order)
+}
+
+func TestRequestOrderState_TooManyRequestsWithoutRetryAfter(t *testing.T) {
+	client, teardown := setupTest()
+	defer teardown()
+
+	responder := httpmock.NewStringResponder(http.StatusTooManyRequests, "")
+	httpmock.RegisterResponder("GET", "http://accrual-system/api/orders/123", responder)
+
+	order, err := client.RequestOrderState("123")
+
+	var rateLimitErr *RateLimitError
+	assert.ErrorAs(t, err, &rateLimitErr)
+	assert.Equal(t, defaultRetryAfter, rateLimitErr.RetryAfter)
+	assert.Nil(t, order)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "seconds", value: "60", want: 60 * time.Second},
+		{name: "empty", value: "", want: defaultRetryAfter},
+		{name: "garbage", value: "soon", want: defaultRetryAfter},
+		{name: "date in the past", value: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.value))
+		})
+	}
+}
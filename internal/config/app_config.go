@@ -3,25 +3,32 @@ package config
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
 )
 
 type AppConfig struct {
 	RunAddress           string
 	DatabaseURI          string
 	AccrualSystemAddress string
+	OrderWorkersCount    int
 }
 
 const (
 	runAddressEnvName                 = "RUN_ADDRESS"
 	dadatabaseURIEnvName              = "DATABASE_URI"
 	accruaaccrualSystemAddressEnvName = "ACCRUAL_SYSTEM_ADDRESS"
+	orderWorkersCountEnvName          = "ORDER_WORKERS_COUNT"
+
+	defaultOrderWorkersCount = 5
 )
 
 var (
 	ErrMissingAddress             = errors.New("missing run address")
 	ErrMissingDDURI               = errors.New("missing database URI")
 	ErrMissingAccrualSystemAddres = errors.New("missing accrual system address")
+	ErrInvalidOrderWorkersCount   = errors.New("invalid order workers count")
 )
 
 func NewAppConfig() (*AppConfig, error) {
@@ -29,12 +36,15 @@ func NewAppConfig() (*AppConfig, error) {
 		runAddress           string
 		databaseURI          string
 		accrualSystemAddress string
+		orderWorkersCount    = defaultOrderWorkersCount
 		errs                 []error
 	)
 
 	flag.StringVar(&runAddress, "a", runAddress, "sets host and port to run")
 	flag.StringVar(&databaseURI, "d", databaseURI, "set database URI to use")
 	flag.StringVar(&accrualSystemAddress, "r", accrualSystemAddress, "set accrual system address and port")
+	flag.IntVar(&orderWorkersCount, "w", orderWorkersCount, "set number of workers polling accrual system")
+	registerAuthFlags()
 	flag.Parse()
 
 	if runAddressEnv := os.Getenv(runAddressEnvName); runAddressEnv != "" {
@@ -49,6 +59,14 @@ func NewAppConfig() (*AppConfig, error) {
 		accrualSystemAddress = accrualSystemAddressEnv
 	}
 
+	if orderWorkersCountEnv := os.Getenv(orderWorkersCountEnvName); orderWorkersCountEnv != "" {
+		count, err := strconv.Atoi(orderWorkersCountEnv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidOrderWorkersCount, err)
+		}
+		orderWorkersCount = count
+	}
+
 	if runAddress == "" {
 		errs = append(errs, ErrMissingAddress)
 	}
@@ -61,6 +79,10 @@ func NewAppConfig() (*AppConfig, error) {
 		errs = append(errs, ErrMissingAccrualSystemAddres)
 	}
 
+	if orderWorkersCount <= 0 {
+		errs = append(errs, ErrInvalidOrderWorkersCount)
+	}
+
 	if len(errs) != 0 {
 		return nil, errors.Join(errs...)
 	}
@@ -69,5 +91,6 @@ func NewAppConfig() (*AppConfig, error) {
 		RunAddress:           runAddress,
 		DatabaseURI:          databaseURI,
 		AccrualSystemAddress: accrualSystemAddress,
+		OrderWorkersCount:    orderWorkersCount,
 	}, nil
 }
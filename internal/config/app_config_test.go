@@ -94,3 +94,55 @@ func TestEmptyFlags(t *testing.T) {
 		assert.EqualError(t, err, test.expectedMsg)
 	}
 }
+
+func TestOrderWorkersCount(t *testing.T) {
+	tests := []struct {
+		args        []string
+		envs        map[string]string
+		want        int
+		expectedErr error
+	}{
+		{
+			args: []string{"-a", "localhost:8080", "-d", "postgres:tra-ta-ta", "-r", "localhost:3333"},
+			envs: map[string]string{},
+			want: defaultOrderWorkersCount,
+		},
+		{
+			args: []string{"-a", "localhost:8080", "-d", "postgres:tra-ta-ta", "-r", "localhost:3333", "-w", "10"},
+			envs: map[string]string{},
+			want: 10,
+		},
+		{
+			args: []string{"-a", "localhost:8080", "-d", "postgres:tra-ta-ta", "-r", "localhost:3333", "-w", "10"},
+			envs: map[string]string{"ORDER_WORKERS_COUNT": "3"},
+			want: 3,
+		},
+		{
+			args:        []string{"-a", "localhost:8080", "-d", "postgres:tra-ta-ta", "-r", "localhost:3333"},
+			envs:        map[string]string{"ORDER_WORKERS_COUNT": "many"},
+			expectedErr: ErrInvalidOrderWorkersCount,
+		},
+		{
+			args:        []string{"-a", "localhost:8080", "-d", "postgres:tra-ta-ta", "-r", "localhost:3333", "-w", "0"},
+			envs:        map[string]string{},
+			expectedErr: ErrInvalidOrderWorkersCount,
+		},
+	}
+
+	for _, test := range tests {
+		for k, v := range test.envs {
+			os.Setenv(k, v)
+		}
+		os.Args = append([]string{"cmd"}, test.args...)
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		config, err := NewAppConfig()
+		os.Clearenv()
+
+		if test.expectedErr != nil {
+			assert.ErrorIs(t, err, test.expectedErr)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, config.OrderWorkersCount)
+	}
+}
@@ -1,58 +1,578 @@
 package config
 
 import (
-	"crypto/rand"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
+	"flag"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthConfig struct {
 	JWTKey                   []byte
+	JWTSigningKey            *SigningKey
+	JWTVerificationKeys      []*VerificationKey
 	JWTAccessTokenExpiresIn  time.Duration
 	JWTRefreshTokenExpiresIn time.Duration
+	Cookie                   CookieConfig
+	AdminToken               string
+	TrustedProxies           []*net.IPNet
+	Credentials              CredentialsPolicy
+	PasswordHashing          PasswordHashingPolicy
+}
+
+type CredentialsPolicy struct {
+	PasswordMinLength       int
+	PasswordMaxBytes        int
+	PasswordRequiredClasses []string
+	RejectCommonPasswords   bool
+	LoginMinLength          int
+	LoginMaxLength          int
+	LoginPattern            *regexp.Regexp
+	LoginCaseFold           bool
+}
+
+type PasswordHashingPolicy struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Time        uint32
+	Argon2MemoryKiB   uint32
+	Argon2Parallelism uint8
+}
+
+type CookieConfig struct {
+	Secure     bool
+	SameSite   http.SameSite
+	Domain     string
+	NamePrefix string
+}
+
+type SigningKey struct {
+	ID     string
+	Signer crypto.Signer
+}
+
+type VerificationKey struct {
+	ID        string
+	PublicKey crypto.PublicKey
 }
 
 const (
-	jwtKeyEnvName         = "JWT_SECRET"
-	jwtAccessTokenExpiry  = 15 * time.Minute
-	jwtRefreshTokenExpiry = 24 * time.Hour
-	jwtSecureLength       = 32
+	jwtKeyEnvName                  = "JWT_SECRET"
+	jwtSigningKeyFileEnvName       = "JWT_SIGNING_KEY_FILE"
+	jwtVerificationKeyFilesEnvName = "JWT_VERIFICATION_KEY_FILES"
+	jwtAccessTokenTTLEnvName       = "JWT_ACCESS_TOKEN_TTL"
+	jwtRefreshTokenTTLEnvName      = "JWT_REFRESH_TOKEN_TTL"
+	cookieSecureEnvName            = "COOKIE_SECURE"
+	cookieSameSiteEnvName          = "COOKIE_SAMESITE"
+	cookieDomainEnvName            = "COOKIE_DOMAIN"
+	cookieNamePrefixEnvName        = "COOKIE_NAME_PREFIX"
+	adminTokenEnvName              = "ADMIN_TOKEN"
+	trustedProxiesEnvName          = "TRUSTED_PROXIES"
+	passwordMinLengthEnvName       = "PASSWORD_MIN_LENGTH"
+	passwordRequiredClassesEnvName = "PASSWORD_REQUIRED_CLASSES"
+	passwordRejectCommonEnvName    = "PASSWORD_REJECT_COMMON"
+	loginMinLengthEnvName          = "LOGIN_MIN_LENGTH"
+	loginMaxLengthEnvName          = "LOGIN_MAX_LENGTH"
+	loginPatternEnvName            = "LOGIN_PATTERN"
+	loginCaseFoldEnvName           = "LOGIN_CASE_FOLD"
+	passwordHashAlgorithmEnvName   = "PASSWORD_HASH_ALGORITHM"
+	bcryptCostEnvName              = "BCRYPT_COST"
+	argon2TimeEnvName              = "ARGON2_TIME"
+	argon2MemoryEnvName            = "ARGON2_MEMORY_KIB"
+	argon2ParallelismEnvName       = "ARGON2_PARALLELISM"
+	jwtAccessTokenExpiry           = 15 * time.Minute
+	jwtRefreshTokenExpiry          = 24 * time.Hour
+	jwtKeyIDLength                 = 16
+
+	defaultPasswordMinLength = 8
+	defaultLoginMinLength    = 3
+	defaultLoginPattern      = `^[[:graph:]]+$`
+
+	defaultArgon2Time        = 3
+	defaultArgon2MemoryKiB   = 64 * 1024
+	defaultArgon2Parallelism = 4
+
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+
+	BcryptMaxPasswordBytes   = 72
+	Argon2idMaxPasswordBytes = 1024
+
+	PasswordClassLower  = "lower"
+	PasswordClassUpper  = "upper"
+	PasswordClassDigit  = "digit"
+	PasswordClassSymbol = "symbol"
+
+	hostCookiePrefix   = "__Host-"
+	secureCookiePrefix = "__Secure-"
 )
 
+// Auth flags are registered by NewAppConfig so one flag.Parse covers every option; environment variables win over them.
+var authFlags = struct {
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	cookieSecure     bool
+	cookieSameSite   string
+	cookieDomain     string
+	cookieNamePrefix string
+}{
+	accessTokenTTL:  jwtAccessTokenExpiry,
+	refreshTokenTTL: jwtRefreshTokenExpiry,
+}
+
 var (
-	ErrMissingJwtKey = errors.New("missing jwt secret")
+	ErrMissingJwtKey     = errors.New("missing jwt signing key")
+	ErrInvalidJwtKeyFile = errors.New("invalid jwt key file")
+	ErrUnsupportedJwtKey = errors.New("unsupported jwt key type, only RSA and Ed25519 are allowed")
+	ErrInvalidTokenTTL   = errors.New("invalid token ttl")
+	ErrInvalidCookie     = errors.New("invalid cookie configuration")
+	ErrInvalidPolicy     = errors.New("invalid credentials policy")
+	ErrInvalidProxy      = errors.New("invalid trusted proxy")
 )
 
 func NewAuthConfig() (*AuthConfig, error) {
-	jwtSecret := os.Getenv(jwtKeyEnvName)
+	accessTokenTTL, err := parseTokenTTL(jwtAccessTokenTTLEnvName, authFlags.accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenTTL, err := parseTokenTTL(jwtRefreshTokenTTLEnvName, authFlags.refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	cookieConfig, err := parseCookieConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseTrustedProxies()
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsPolicy, err := parseCredentialsPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	hashingPolicy, err := parsePasswordHashingPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsPolicy.PasswordMaxBytes = hashingPolicy.MaxPasswordBytes()
+
+	authConfig := &AuthConfig{
+		JWTAccessTokenExpiresIn:  accessTokenTTL,
+		JWTRefreshTokenExpiresIn: refreshTokenTTL,
+		Cookie:                   cookieConfig,
+		AdminToken:               os.Getenv(adminTokenEnvName),
+		TrustedProxies:           trustedProxies,
+		Credentials:              credentialsPolicy,
+		PasswordHashing:          hashingPolicy,
+	}
+
+	if signingKeyFile := os.Getenv(jwtSigningKeyFileEnvName); signingKeyFile != "" {
+		signingKey, err := loadSigningKey(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		authConfig.JWTSigningKey = signingKey
+		authConfig.JWTVerificationKeys = append(authConfig.JWTVerificationKeys, &VerificationKey{
+			ID:        signingKey.ID,
+			PublicKey: signingKey.Signer.Public(),
+		})
+
+		for _, keyFile := range strings.Split(os.Getenv(jwtVerificationKeyFilesEnvName), ",") {
+			if keyFile = strings.TrimSpace(keyFile); keyFile == "" {
+				continue
+			}
+			verificationKey, err := loadVerificationKey(keyFile)
+			if err != nil {
+				return nil, err
+			}
+			authConfig.JWTVerificationKeys = append(authConfig.JWTVerificationKeys, verificationKey)
+		}
 
+		return authConfig, nil
+	}
+
+	jwtSecret := os.Getenv(jwtKeyEnvName)
 	if jwtSecret == "" {
+		return nil, fmt.Errorf("%w: set %s or %s", ErrMissingJwtKey, jwtKeyEnvName, jwtSigningKeyFileEnvName)
+	}
+	authConfig.JWTKey = []byte(jwtSecret)
+
+	return authConfig, nil
+}
+
+func (hp PasswordHashingPolicy) MaxPasswordBytes() int {
+	if hp.Algorithm == PasswordHashArgon2id {
+		return Argon2idMaxPasswordBytes
+	}
+
+	return BcryptMaxPasswordBytes
+}
+
+func (ac *AuthConfig) VerificationKey(keyID string) (crypto.PublicKey, bool) {
+	for _, key := range ac.JWTVerificationKeys {
+		if key.ID == keyID {
+			return key.PublicKey, true
+		}
+	}
+
+	return nil, false
+}
+
+func registerAuthFlags() {
+	flag.DurationVar(&authFlags.accessTokenTTL, "access-token-ttl", jwtAccessTokenExpiry, "set access token lifetime")
+	flag.DurationVar(&authFlags.refreshTokenTTL, "refresh-token-ttl", jwtRefreshTokenExpiry, "set refresh token lifetime")
+	flag.BoolVar(&authFlags.cookieSecure, "cookie-secure", false, "set Secure attribute on auth cookies")
+	flag.StringVar(&authFlags.cookieSameSite, "cookie-samesite", "", "set SameSite mode of auth cookies: lax, strict or none")
+	flag.StringVar(&authFlags.cookieDomain, "cookie-domain", "", "set Domain attribute of auth cookies")
+	flag.StringVar(&authFlags.cookieNamePrefix, "cookie-name-prefix", "", "set auth cookie name prefix: __Host- or __Secure-")
+}
+
+func parseTokenTTL(envName string, defaultTTL time.Duration) (time.Duration, error) {
+	ttl := defaultTTL
+	if ttlEnv := os.Getenv(envName); ttlEnv != "" {
 		var err error
-		jwtSecret, err = generateSecureSecret()
+		if ttl, err = time.ParseDuration(ttlEnv); err != nil {
+			return 0, fmt.Errorf("%w: %s: %w", ErrInvalidTokenTTL, envName, err)
+		}
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%w: %s must be positive", ErrInvalidTokenTTL, envName)
+	}
+
+	return ttl, nil
+}
+
+func parseCookieConfig() (CookieConfig, error) {
+	cookieConfig := CookieConfig{
+		Secure:     authFlags.cookieSecure,
+		SameSite:   http.SameSiteLaxMode,
+		Domain:     stringFromEnv(cookieDomainEnvName, authFlags.cookieDomain),
+		NamePrefix: stringFromEnv(cookieNamePrefixEnvName, authFlags.cookieNamePrefix),
+	}
+
+	if secureEnv := os.Getenv(cookieSecureEnvName); secureEnv != "" {
+		secure, err := strconv.ParseBool(secureEnv)
+		if err != nil {
+			return CookieConfig{}, fmt.Errorf("%w: %s: %w", ErrInvalidCookie, cookieSecureEnvName, err)
+		}
+		cookieConfig.Secure = secure
+	}
+
+	sameSite := stringFromEnv(cookieSameSiteEnvName, authFlags.cookieSameSite)
+	switch strings.ToLower(sameSite) {
+	case "", "lax":
+		cookieConfig.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookieConfig.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookieConfig.SameSite = http.SameSiteNoneMode
+	default:
+		return CookieConfig{}, fmt.Errorf("%w: unknown SameSite mode %q", ErrInvalidCookie, sameSite)
+	}
+
+	if cookieConfig.SameSite == http.SameSiteNoneMode && !cookieConfig.Secure {
+		return CookieConfig{}, fmt.Errorf("%w: SameSite=None requires Secure", ErrInvalidCookie)
+	}
+
+	switch cookieConfig.NamePrefix {
+	case "":
+	case hostCookiePrefix:
+		if !cookieConfig.Secure || cookieConfig.Domain != "" {
+			return CookieConfig{}, fmt.Errorf("%w: %s prefix requires Secure and no Domain", ErrInvalidCookie, hostCookiePrefix)
+		}
+	case secureCookiePrefix:
+		if !cookieConfig.Secure {
+			return CookieConfig{}, fmt.Errorf("%w: %s prefix requires Secure", ErrInvalidCookie, secureCookiePrefix)
+		}
+	default:
+		return CookieConfig{}, fmt.Errorf("%w: unknown cookie name prefix %q", ErrInvalidCookie, cookieConfig.NamePrefix)
+	}
+
+	return cookieConfig, nil
+}
+
+func parseTrustedProxies() ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, proxy := range strings.Split(os.Getenv(trustedProxiesEnvName), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
 		if err != nil {
-			return nil, ErrMissingJwtKey
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidProxy, trustedProxiesEnvName, err)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+func parseCredentialsPolicy() (CredentialsPolicy, error) {
+	policy := CredentialsPolicy{
+		PasswordMinLength:     defaultPasswordMinLength,
+		RejectCommonPasswords: true,
+		LoginMinLength:        defaultLoginMinLength,
+		LoginMaxLength:        domain.MaxLoginLength,
+		LoginPattern:          regexp.MustCompile(defaultLoginPattern),
+	}
+
+	var err error
+	if policy.PasswordMinLength, err = intFromEnv(passwordMinLengthEnvName, policy.PasswordMinLength); err != nil {
+		return CredentialsPolicy{}, err
+	}
+	if policy.LoginMinLength, err = intFromEnv(loginMinLengthEnvName, policy.LoginMinLength); err != nil {
+		return CredentialsPolicy{}, err
+	}
+	if policy.LoginMaxLength, err = intFromEnv(loginMaxLengthEnvName, policy.LoginMaxLength); err != nil {
+		return CredentialsPolicy{}, err
+	}
+	if policy.RejectCommonPasswords, err = boolFromEnv(passwordRejectCommonEnvName, policy.RejectCommonPasswords); err != nil {
+		return CredentialsPolicy{}, err
+	}
+	if policy.LoginCaseFold, err = boolFromEnv(loginCaseFoldEnvName, policy.LoginCaseFold); err != nil {
+		return CredentialsPolicy{}, err
+	}
+
+	if policy.PasswordMinLength < 1 {
+		return CredentialsPolicy{}, fmt.Errorf("%w: %s must be positive", ErrInvalidPolicy, passwordMinLengthEnvName)
+	}
+	if policy.LoginMinLength < 1 || policy.LoginMinLength > policy.LoginMaxLength || policy.LoginMaxLength > domain.MaxLoginLength {
+		return CredentialsPolicy{}, fmt.Errorf("%w: login length must be between 1 and %d", ErrInvalidPolicy, domain.MaxLoginLength)
+	}
+
+	for _, class := range strings.Split(os.Getenv(passwordRequiredClassesEnvName), ",") {
+		switch class = strings.ToLower(strings.TrimSpace(class)); class {
+		case "":
+		case PasswordClassLower, PasswordClassUpper, PasswordClassDigit, PasswordClassSymbol:
+			policy.PasswordRequiredClasses = append(policy.PasswordRequiredClasses, class)
+		default:
+			return CredentialsPolicy{}, fmt.Errorf("%w: unknown password character class %q", ErrInvalidPolicy, class)
+		}
+	}
+
+	if pattern := os.Getenv(loginPatternEnvName); pattern != "" {
+		if policy.LoginPattern, err = regexp.Compile(pattern); err != nil {
+			return CredentialsPolicy{}, fmt.Errorf("%w: %s: %w", ErrInvalidPolicy, loginPatternEnvName, err)
 		}
 	}
 
-	return &AuthConfig{
-		JWTKey:                   []byte(jwtSecret),
-		JWTAccessTokenExpiresIn:  jwtAccessTokenExpiry,
-		JWTRefreshTokenExpiresIn: jwtRefreshTokenExpiry,
-	}, nil
+	return policy, nil
+}
+
+func parsePasswordHashingPolicy() (PasswordHashingPolicy, error) {
+	policy := PasswordHashingPolicy{
+		Algorithm: PasswordHashBcrypt,
+	}
+
+	if algorithm := os.Getenv(passwordHashAlgorithmEnvName); algorithm != "" {
+		policy.Algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	}
+	if policy.Algorithm != PasswordHashBcrypt && policy.Algorithm != PasswordHashArgon2id {
+		return PasswordHashingPolicy{}, fmt.Errorf("%w: unknown password hash algorithm %q", ErrInvalidPolicy, policy.Algorithm)
+	}
+
+	bcryptCost, err := intFromEnv(bcryptCostEnvName, bcrypt.DefaultCost)
+	if err != nil {
+		return PasswordHashingPolicy{}, err
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return PasswordHashingPolicy{}, fmt.Errorf(
+			"%w: %s must be between %d and %d", ErrInvalidPolicy, bcryptCostEnvName, bcrypt.MinCost, bcrypt.MaxCost,
+		)
+	}
+	policy.BcryptCost = bcryptCost
+
+	argon2Time, err := intFromEnv(argon2TimeEnvName, defaultArgon2Time)
+	if err != nil {
+		return PasswordHashingPolicy{}, err
+	}
+	argon2Memory, err := intFromEnv(argon2MemoryEnvName, defaultArgon2MemoryKiB)
+	if err != nil {
+		return PasswordHashingPolicy{}, err
+	}
+	argon2Parallelism, err := intFromEnv(argon2ParallelismEnvName, defaultArgon2Parallelism)
+	if err != nil {
+		return PasswordHashingPolicy{}, err
+	}
+
+	if argon2Time < 1 || argon2Time > math.MaxUint32 {
+		return PasswordHashingPolicy{}, fmt.Errorf("%w: %s must be positive", ErrInvalidPolicy, argon2TimeEnvName)
+	}
+	if argon2Parallelism < 1 || argon2Parallelism > math.MaxUint8 {
+		return PasswordHashingPolicy{}, fmt.Errorf(
+			"%w: %s must be between 1 and %d", ErrInvalidPolicy, argon2ParallelismEnvName, math.MaxUint8,
+		)
+	}
+	if argon2Memory < 8*argon2Parallelism || argon2Memory > math.MaxUint32 {
+		return PasswordHashingPolicy{}, fmt.Errorf(
+			"%w: %s must be at least 8 KiB per lane", ErrInvalidPolicy, argon2MemoryEnvName,
+		)
+	}
+	policy.Argon2Time = uint32(argon2Time)
+	policy.Argon2MemoryKiB = uint32(argon2Memory)
+	policy.Argon2Parallelism = uint8(argon2Parallelism)
+
+	return policy, nil
+}
+
+func stringFromEnv(envName, defaultValue string) string {
+	if value := os.Getenv(envName); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+func intFromEnv(envName string, defaultValue int) (int, error) {
+	value := os.Getenv(envName)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %w", ErrInvalidPolicy, envName, err)
+	}
+
+	return parsed, nil
+}
+
+func boolFromEnv(envName string, defaultValue bool) (bool, error) {
+	value := os.Getenv(envName)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %w", ErrInvalidPolicy, envName, err)
+	}
+
+	return parsed, nil
 }
 
-func generateSecureSecret() (string, error) {
-	secret := make([]byte, jwtSecureLength)
+func loadSigningKey(path string) (*SigningKey, error) {
+	block, err := readPEMBlock(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s: unexpected PEM block %q", ErrInvalidJwtKeyFile, path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidJwtKeyFile, path, err)
+	}
+
+	var signer crypto.Signer
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signer = k
+	case ed25519.PrivateKey:
+		signer = k
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedJwtKey, path)
+	}
+
+	keyID, err := keyIDFromPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidJwtKeyFile, path, err)
+	}
+
+	return &SigningKey{ID: keyID, Signer: signer}, nil
+}
+
+func loadVerificationKey(path string) (*VerificationKey, error) {
+	block, err := readPEMBlock(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s: unexpected PEM block %q", ErrInvalidJwtKeyFile, path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidJwtKeyFile, path, err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedJwtKey, path)
+	}
+
+	keyID, err := keyIDFromPublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidJwtKeyFile, path, err)
+	}
+
+	return &VerificationKey{ID: keyID, PublicKey: key}, nil
+}
+
+func readPEMBlock(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJwtKeyFile, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s: no PEM data found", ErrInvalidJwtKeyFile, path)
+	}
+
+	return block, nil
+}
 
-	_, err := rand.Read(secret)
+func keyIDFromPublicKey(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
 	}
 
-	encodedSecret := base64.URLEncoding.EncodeToString(secret)
+	sum := sha256.Sum256(der)
 
-	return encodedSecret, nil
+	return base64.RawURLEncoding.EncodeToString(sum[:jwtKeyIDLength]), nil
 }
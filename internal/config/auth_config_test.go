@@ -1,8 +1,19 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -10,6 +21,7 @@ import (
 func TestParseSecretEnv(t *testing.T) {
 	type want struct {
 		JWTKey []byte
+		err    error
 	}
 	tests := []struct {
 		envs map[string]string
@@ -23,6 +35,12 @@ func TestParseSecretEnv(t *testing.T) {
 				JWTKey: []byte("zna40k_dura40k"),
 			},
 		},
+		{
+			envs: map[string]string{},
+			want: want{
+				err: ErrMissingJwtKey,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -33,7 +51,435 @@ func TestParseSecretEnv(t *testing.T) {
 		config, err := NewAuthConfig()
 		os.Clearenv()
 
+		if test.want.err != nil {
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Nil(t, config)
+			continue
+		}
 		assert.Equal(t, test.want.JWTKey, config.JWTKey)
 		assert.NoError(t, err)
 	}
 }
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	return path
+}
+
+func TestAsymmetricKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	_, signingKey, _ := ed25519.GenerateKey(rand.Reader)
+	signingDER, _ := x509.MarshalPKCS8PrivateKey(signingKey)
+	signingPath := writePEM(t, dir, "signing.pem", "PRIVATE KEY", signingDER)
+
+	previousKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	previousDER, _ := x509.MarshalPKIXPublicKey(&previousKey.PublicKey)
+	previousPath := writePEM(t, dir, "previous.pub.pem", "PUBLIC KEY", previousDER)
+
+	os.Setenv("JWT_SIGNING_KEY_FILE", signingPath)
+	os.Setenv("JWT_VERIFICATION_KEY_FILES", previousPath)
+	config, err := NewAuthConfig()
+	os.Clearenv()
+
+	assert.NoError(t, err)
+	assert.Nil(t, config.JWTKey)
+	assert.NotEmpty(t, config.JWTSigningKey.ID)
+	assert.Len(t, config.JWTVerificationKeys, 2)
+	assert.Equal(t, config.JWTSigningKey.ID, config.JWTVerificationKeys[0].ID)
+	assert.NotEqual(t, config.JWTVerificationKeys[0].ID, config.JWTVerificationKeys[1].ID)
+
+	publicKey, ok := config.VerificationKey(config.JWTVerificationKeys[1].ID)
+	assert.True(t, ok)
+	assert.Equal(t, &previousKey.PublicKey, publicKey)
+}
+
+func TestInvalidKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	garbagePath := filepath.Join(dir, "garbage.pem")
+	_ = os.WriteFile(garbagePath, []byte("not a key"), 0o600)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	ecPath := writePEM(t, dir, "ec.pem", "PRIVATE KEY", ecDER)
+
+	tests := []struct {
+		name        string
+		path        string
+		expectedErr error
+	}{
+		{name: "Missing file", path: filepath.Join(dir, "missing.pem"), expectedErr: ErrInvalidJwtKeyFile},
+		{name: "Not a PEM file", path: garbagePath, expectedErr: ErrInvalidJwtKeyFile},
+		{name: "Unsupported key type", path: ecPath, expectedErr: ErrUnsupportedJwtKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("JWT_SIGNING_KEY_FILE", test.path)
+			_, err := NewAuthConfig()
+			os.Clearenv()
+
+			assert.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestTokenTTLs(t *testing.T) {
+	tests := []struct {
+		name            string
+		envs            map[string]string
+		expectedAccess  time.Duration
+		expectedRefresh time.Duration
+		expectedErr     error
+	}{
+		{
+			name:            "Defaults",
+			envs:            map[string]string{},
+			expectedAccess:  jwtAccessTokenExpiry,
+			expectedRefresh: jwtRefreshTokenExpiry,
+		},
+		{
+			name:            "Custom lifetimes",
+			envs:            map[string]string{"JWT_ACCESS_TOKEN_TTL": "5m", "JWT_REFRESH_TOKEN_TTL": "168h"},
+			expectedAccess:  5 * time.Minute,
+			expectedRefresh: 168 * time.Hour,
+		},
+		{
+			name:        "Malformed lifetime",
+			envs:        map[string]string{"JWT_ACCESS_TOKEN_TTL": "forever"},
+			expectedErr: ErrInvalidTokenTTL,
+		},
+		{
+			name:        "Non-positive lifetime",
+			envs:        map[string]string{"JWT_REFRESH_TOKEN_TTL": "0s"},
+			expectedErr: ErrInvalidTokenTTL,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("JWT_SECRET", "secret")
+			for k, v := range test.envs {
+				os.Setenv(k, v)
+			}
+
+			config, err := NewAuthConfig()
+			os.Clearenv()
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedAccess, config.JWTAccessTokenExpiresIn)
+			assert.Equal(t, test.expectedRefresh, config.JWTRefreshTokenExpiresIn)
+		})
+	}
+}
+
+func TestAuthFlags(t *testing.T) {
+	t.Cleanup(func() {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		registerAuthFlags()
+		os.Clearenv()
+	})
+
+	os.Args = []string{
+		"cmd", "-a", "localhost:8080", "-d", "postgres:tra-ta-ta", "-r", "localhost:3333",
+		"-access-token-ttl", "5m", "-refresh-token-ttl", "168h",
+		"-cookie-secure", "-cookie-samesite", "strict", "-cookie-domain", "example.com", "-cookie-name-prefix", "__Secure-",
+	}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	_, err := NewAppConfig()
+	assert.NoError(t, err)
+
+	os.Setenv("JWT_SECRET", "secret")
+	os.Setenv("JWT_REFRESH_TOKEN_TTL", "48h")
+	os.Setenv("COOKIE_SAMESITE", "none")
+
+	config, err := NewAuthConfig()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, config.JWTAccessTokenExpiresIn)
+	assert.Equal(t, 48*time.Hour, config.JWTRefreshTokenExpiresIn)
+	assert.Equal(t, CookieConfig{
+		Secure:     true,
+		SameSite:   http.SameSiteNoneMode,
+		Domain:     "example.com",
+		NamePrefix: "__Secure-",
+	}, config.Cookie)
+}
+
+func TestCookieConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		envs        map[string]string
+		want        CookieConfig
+		expectedErr error
+	}{
+		{
+			name: "Defaults",
+			envs: map[string]string{},
+			want: CookieConfig{SameSite: http.SameSiteLaxMode},
+		},
+		{
+			name: "Host prefixed secure cookies",
+			envs: map[string]string{"COOKIE_SECURE": "true", "COOKIE_SAMESITE": "Strict", "COOKIE_NAME_PREFIX": "__Host-"},
+			want: CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode, NamePrefix: "__Host-"},
+		},
+		{
+			name: "Shared domain",
+			envs: map[string]string{"COOKIE_SECURE": "true", "COOKIE_SAMESITE": "none", "COOKIE_DOMAIN": "example.com"},
+			want: CookieConfig{Secure: true, SameSite: http.SameSiteNoneMode, Domain: "example.com"},
+		},
+		{
+			name:        "SameSite None without Secure",
+			envs:        map[string]string{"COOKIE_SAMESITE": "none"},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "Host prefix with Domain",
+			envs:        map[string]string{"COOKIE_SECURE": "true", "COOKIE_DOMAIN": "example.com", "COOKIE_NAME_PREFIX": "__Host-"},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "Secure prefix without Secure",
+			envs:        map[string]string{"COOKIE_NAME_PREFIX": "__Secure-"},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "Unknown SameSite mode",
+			envs:        map[string]string{"COOKIE_SAMESITE": "sometimes"},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "Malformed Secure flag",
+			envs:        map[string]string{"COOKIE_SECURE": "yes please"},
+			expectedErr: ErrInvalidCookie,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("JWT_SECRET", "secret")
+			for k, v := range test.envs {
+				os.Setenv(k, v)
+			}
+
+			config, err := NewAuthConfig()
+			os.Clearenv()
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, config.Cookie)
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         string
+		want        []string
+		expectedErr error
+	}{
+		{name: "None", env: ""},
+		{
+			name: "Addresses and networks",
+			env:  "10.0.0.0/8, 192.0.2.1,2001:db8::1",
+			want: []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::1/128"},
+		},
+		{name: "Malformed proxy", env: "proxy.local", expectedErr: ErrInvalidProxy},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("JWT_SECRET", "secret")
+			os.Setenv("TRUSTED_PROXIES", test.env)
+
+			config, err := NewAuthConfig()
+			os.Clearenv()
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			var got []string
+			for _, proxy := range config.TrustedProxies {
+				got = append(got, proxy.String())
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestCredentialsPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		envs        map[string]string
+		check       func(t *testing.T, policy CredentialsPolicy)
+		expectedErr error
+	}{
+		{
+			name: "Defaults",
+			envs: map[string]string{},
+			check: func(t *testing.T, policy CredentialsPolicy) {
+				assert.Equal(t, defaultPasswordMinLength, policy.PasswordMinLength)
+				assert.Empty(t, policy.PasswordRequiredClasses)
+				assert.True(t, policy.RejectCommonPasswords)
+				assert.Equal(t, defaultLoginMinLength, policy.LoginMinLength)
+				assert.Equal(t, 50, policy.LoginMaxLength)
+				assert.False(t, policy.LoginCaseFold)
+			},
+		},
+		{
+			name: "Custom policy",
+			envs: map[string]string{
+				"PASSWORD_MIN_LENGTH":       "12",
+				"PASSWORD_REQUIRED_CLASSES": "upper, digit,symbol",
+				"PASSWORD_REJECT_COMMON":    "false",
+				"LOGIN_MAX_LENGTH":          "32",
+				"LOGIN_PATTERN":             `^[a-z]+$`,
+				"LOGIN_CASE_FOLD":           "true",
+			},
+			check: func(t *testing.T, policy CredentialsPolicy) {
+				assert.Equal(t, 12, policy.PasswordMinLength)
+				assert.Equal(t, []string{PasswordClassUpper, PasswordClassDigit, PasswordClassSymbol}, policy.PasswordRequiredClasses)
+				assert.False(t, policy.RejectCommonPasswords)
+				assert.Equal(t, 32, policy.LoginMaxLength)
+				assert.Equal(t, `^[a-z]+$`, policy.LoginPattern.String())
+				assert.True(t, policy.LoginCaseFold)
+			},
+		},
+		{
+			name:        "Login longer than the column",
+			envs:        map[string]string{"LOGIN_MAX_LENGTH": "64"},
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "Unknown character class",
+			envs:        map[string]string{"PASSWORD_REQUIRED_CLASSES": "emoji"},
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "Malformed login pattern",
+			envs:        map[string]string{"LOGIN_PATTERN": "[a-z"},
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "Malformed min length",
+			envs:        map[string]string{"PASSWORD_MIN_LENGTH": "eight"},
+			expectedErr: ErrInvalidPolicy,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("JWT_SECRET", "secret")
+			for k, v := range test.envs {
+				os.Setenv(k, v)
+			}
+
+			config, err := NewAuthConfig()
+			os.Clearenv()
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			test.check(t, config.Credentials)
+		})
+	}
+}
+
+func TestPasswordHashingPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		envs        map[string]string
+		check       func(t *testing.T, policy PasswordHashingPolicy)
+		expectedErr error
+	}{
+		{
+			name: "Defaults",
+			envs: map[string]string{},
+			check: func(t *testing.T, policy PasswordHashingPolicy) {
+				assert.Equal(t, PasswordHashBcrypt, policy.Algorithm)
+				assert.Equal(t, 10, policy.BcryptCost)
+				assert.Equal(t, uint32(defaultArgon2Time), policy.Argon2Time)
+				assert.Equal(t, uint32(defaultArgon2MemoryKiB), policy.Argon2MemoryKiB)
+				assert.Equal(t, uint8(defaultArgon2Parallelism), policy.Argon2Parallelism)
+				assert.Equal(t, BcryptMaxPasswordBytes, policy.MaxPasswordBytes())
+			},
+		},
+		{
+			name: "Custom argon2id",
+			envs: map[string]string{
+				"PASSWORD_HASH_ALGORITHM": "Argon2id",
+				"BCRYPT_COST":             "12",
+				"ARGON2_TIME":             "2",
+				"ARGON2_MEMORY_KIB":       "19456",
+				"ARGON2_PARALLELISM":      "1",
+			},
+			check: func(t *testing.T, policy PasswordHashingPolicy) {
+				assert.Equal(t, PasswordHashArgon2id, policy.Algorithm)
+				assert.Equal(t, 12, policy.BcryptCost)
+				assert.Equal(t, uint32(2), policy.Argon2Time)
+				assert.Equal(t, uint32(19456), policy.Argon2MemoryKiB)
+				assert.Equal(t, uint8(1), policy.Argon2Parallelism)
+				assert.Equal(t, Argon2idMaxPasswordBytes, policy.MaxPasswordBytes())
+			},
+		},
+		{
+			name:        "Unknown algorithm",
+			envs:        map[string]string{"PASSWORD_HASH_ALGORITHM": "md5"},
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "Bcrypt cost out of range",
+			envs:        map[string]string{"BCRYPT_COST": "40"},
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "Argon2 memory below minimum",
+			envs:        map[string]string{"ARGON2_MEMORY_KIB": "8", "ARGON2_PARALLELISM": "4"},
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "Argon2 parallelism out of range",
+			envs:        map[string]string{"ARGON2_PARALLELISM": "256"},
+			expectedErr: ErrInvalidPolicy,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("JWT_SECRET", "secret")
+			for k, v := range test.envs {
+				os.Setenv(k, v)
+			}
+
+			config, err := NewAuthConfig()
+			os.Clearenv()
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			test.check(t, config.PasswordHashing)
+			assert.Equal(t, config.PasswordHashing.MaxPasswordBytes(), config.Credentials.PasswordMaxBytes)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+BEGIN;
+CREATE TYPE ledger_entry_type AS ENUM ('ACCRUAL', 'WITHDRAWAL');
+
+-- Append-only audit trail of balance movements; the balance itself lives in user_balances.
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    entry_type ledger_entry_type NOT NULL,
+    amount INT NOT NULL,
+    order_number TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ledger_entries_user_id_id ON ledger_entries (user_id, id DESC);
+
+INSERT INTO ledger_entries (user_id, entry_type, amount, order_number, created_at)
+SELECT user_id, entry_type, amount, order_number, created_at
+FROM (
+    SELECT o.user_id, 'ACCRUAL'::ledger_entry_type AS entry_type, a.accrual AS amount,
+           o.number AS order_number, o.uploaded_at AS created_at, a.id AS source_id
+    FROM accruals a
+    JOIN orders o ON a.order_id = o.id
+    WHERE a.accrual <> 0
+    UNION ALL
+    SELECT w.user_id, 'WITHDRAWAL'::ledger_entry_type, -w.sum,
+           w.order_number, w.processed_at, w.id
+    FROM withdrawals w
+) movements
+ORDER BY user_id, created_at, entry_type, source_id;
+COMMIT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+BEGIN;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TYPE IF EXISTS ledger_entry_type;
+COMMIT;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+BEGIN;
+CREATE TABLE user_balances (
+    user_id INT PRIMARY KEY REFERENCES users(id),
+    current INT NOT NULL DEFAULT 0,
+    withdrawn INT NOT NULL DEFAULT 0
+);
+
+INSERT INTO user_balances (user_id, current, withdrawn)
+SELECT
+    u.id,
+    COALESCE(a.total, 0) - COALESCE(w.total, 0),
+    COALESCE(w.total, 0)
+FROM users u
+LEFT JOIN (
+    SELECT o.user_id, SUM(a.accrual) AS total
+    FROM accruals a
+    JOIN orders o ON a.order_id = o.id
+    GROUP BY o.user_id
+) a ON a.user_id = u.id
+LEFT JOIN (
+    SELECT user_id, SUM(sum) AS total
+    FROM withdrawals
+    GROUP BY user_id
+) w ON w.user_id = u.id;
+COMMIT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_balances;
+-- +goose StatementEnd
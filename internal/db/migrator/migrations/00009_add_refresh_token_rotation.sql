@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+BEGIN;
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN used_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN replaced_by INT REFERENCES refresh_tokens(id) ON DELETE SET NULL;
+
+DELETE FROM refresh_tokens a
+USING refresh_tokens b
+WHERE a.token = b.token AND a.id < b.id;
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token ON refresh_tokens(token);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+COMMIT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+BEGIN;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_token;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS replaced_by,
+    DROP COLUMN IF EXISTS used_at,
+    DROP COLUMN IF EXISTS family_id;
+COMMIT;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+BEGIN;
+CREATE TYPE login_attempt_scope AS ENUM ('LOGIN', 'IP');
+
+CREATE TABLE login_attempts (
+    scope login_attempt_scope NOT NULL,
+    key TEXT NOT NULL,
+    failed_count INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP WITH TIME ZONE,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, key)
+);
+COMMIT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+BEGIN;
+DROP TABLE IF EXISTS login_attempts;
+DROP TYPE IF EXISTS login_attempt_scope;
+COMMIT;
+-- +goose StatementEnd
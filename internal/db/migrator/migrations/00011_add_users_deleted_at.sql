@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+BEGIN;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ALTER COLUMN login DROP NOT NULL;
+COMMIT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+BEGIN;
+DELETE FROM users WHERE login IS NULL;
+ALTER TABLE users ALTER COLUMN login SET NOT NULL;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+COMMIT;
+-- +goose StatementEnd
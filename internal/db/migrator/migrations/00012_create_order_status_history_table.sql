@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+BEGIN;
+
+CREATE TABLE order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    status order_status NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_status_history_order_id_changed_at ON order_status_history (order_id, changed_at);
+
+-- Existing orders only tell which status they reached, not when, so backfilled rows have no changed_at.
+INSERT INTO order_status_history (order_id, status, changed_at)
+SELECT id, status, NULL FROM orders WHERE status <> 'NEW';
+
+ALTER TABLE orders ADD COLUMN poll_count INT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN last_polled_at TIMESTAMP WITH TIME ZONE;
+
+COMMIT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+BEGIN;
+ALTER TABLE orders DROP COLUMN IF EXISTS last_polled_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS poll_count;
+DROP TABLE IF EXISTS order_status_history;
+COMMIT;
+-- +goose StatementEnd
@@ -4,3 +4,13 @@ type Balance struct {
 	BalanceSum    float64 `json:"current"`
 	WithdrawalSum float64 `json:"withdrawn"`
 }
+
+type BalanceDrift struct {
+	UserID            int64
+	Current           float64
+	Withdrawn         float64
+	ExpectedCurrent   float64
+	ExpectedWithdrawn float64
+	LedgerCurrent     float64
+	LedgerWithdrawn   float64
+}
@@ -5,9 +5,12 @@ const (
 	HTMLContentType = "text/html"
 	JSONContentType = "application/json"
 
+	EventStreamContentType = "text/event-stream"
+
 	CompressFormat = "gzip"
 
-	UserIDHeader = "X-User-ID"
+	AuthorizationHeader = "Authorization"
+	BearerPrefix        = "Bearer "
 
 	ToSubunitDelimeter = 100
 )
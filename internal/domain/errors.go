@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrInvalidOrderNumber = errors.New("invalid order number")
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+
+	ErrUserExists                 = fmt.Errorf("%w: user already exists", ErrConflict)
+	ErrOrderUploadedByAnotherUser = fmt.Errorf("%w: order uploaded by another user", ErrConflict)
+)
@@ -0,0 +1,22 @@
+package domain
+
+import "encoding/json"
+
+const (
+	EventsChannel = "gophermart_events"
+
+	EventOrderStatus = "order_status"
+	EventBalance     = "balance"
+)
+
+type Event struct {
+	Type   string          `json:"type"`
+	UserID int64           `json:"user_id"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type OrderStatusEvent struct {
+	Number  string   `json:"number"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
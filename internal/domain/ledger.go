@@ -0,0 +1,6 @@
+package domain
+
+const (
+	LedgerEntryAccrual    = "ACCRUAL"
+	LedgerEntryWithdrawal = "WITHDRAWAL"
+)
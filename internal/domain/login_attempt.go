@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+type LoginAttemptScope string
+
+const (
+	LoginAttemptScopeLogin LoginAttemptScope = "LOGIN"
+	LoginAttemptScopeIP    LoginAttemptScope = "IP"
+)
+
+type LoginAttemptKey struct {
+	Scope LoginAttemptScope
+	Value string
+}
+
+type LockoutPolicy struct {
+	Threshold   int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	Window      time.Duration
+}
+
+var (
+	LoginLockoutPolicy = LockoutPolicy{
+		Threshold:   5,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  time.Hour,
+		Window:      15 * time.Minute,
+	}
+	IPLockoutPolicy = LockoutPolicy{
+		Threshold:   20,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  time.Hour,
+		Window:      15 * time.Minute,
+	}
+)
+
+func LockoutPolicyFor(scope LoginAttemptScope) LockoutPolicy {
+	if scope == LoginAttemptScopeIP {
+		return IPLockoutPolicy
+	}
+	return LoginLockoutPolicy
+}
+
+func (p LockoutPolicy) LockoutFor(failedCount int) time.Duration {
+	if failedCount < p.Threshold {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for range failedCount - p.Threshold {
+		lockout *= 2
+		if lockout >= p.MaxLockout {
+			return p.MaxLockout
+		}
+	}
+
+	return min(lockout, p.MaxLockout)
+}
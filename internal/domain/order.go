@@ -1,6 +1,37 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+const (
+	OrderStatusNew        = "NEW"
+	OrderStatusRegistered = "REGISTERED"
+	OrderStatusProcessing = "PROCESSING"
+	OrderStatusInvalid    = "INVALID"
+	OrderStatusProcessed  = "PROCESSED"
+)
+
+const (
+	OrderUploadAccepted        = "accepted"
+	OrderUploadAlreadyUploaded = "already_uploaded"
+	OrderUploadConflict        = "conflict"
+	OrderUploadInvalid         = "invalid"
+	OrderUploadDuplicate       = "duplicate"
+)
+
+var (
+	ErrOrderStatusRegression = errors.New("order status can't go backwards")
+)
+
+var orderStatusRanks = map[string]int{
+	OrderStatusNew:        0,
+	OrderStatusRegistered: 1,
+	OrderStatusProcessing: 2,
+	OrderStatusInvalid:    3,
+	OrderStatusProcessed:  3,
+}
 
 type Order struct {
 	Number     string    `json:"number"`
@@ -17,8 +48,64 @@ type DBOrder struct {
 	UserID     int64
 }
 
+type OrderFilter struct {
+	UserID   int64
+	Statuses []string
+	Uploaded TimeRange
+	Limit    int
+	After    *Cursor
+}
+
+type OrderPage struct {
+	Orders     []*Order
+	NextCursor *Cursor
+}
+
+type OrderStatusChange struct {
+	Status     string     `json:"status"`
+	ChangedAt  *time.Time `json:"changed_at,omitempty"`
+	Backfilled bool       `json:"backfilled,omitempty"`
+}
+
+type OrderDetails struct {
+	Number       string               `json:"number"`
+	Status       string               `json:"status"`
+	Accrual      *float64             `json:"accrual,omitempty"`
+	UploadedAt   time.Time            `json:"uploaded_at"`
+	PollCount    int                  `json:"poll_count"`
+	LastPolledAt *time.Time           `json:"last_polled_at,omitempty"`
+	History      []*OrderStatusChange `json:"history"`
+}
+
+type OrderUploadResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+}
+
 type AccrualOrder struct {
 	Order   string  `json:"order"`
 	Status  string  `json:"status"`
 	Accrual float64 `json:"accrual"`
 }
+
+func IsKnownOrderStatus(status string) bool {
+	_, ok := orderStatusRanks[status]
+	return ok
+}
+
+func IsOrderStatusTransitionAllowed(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	fromRank, ok := orderStatusRanks[from]
+	if !ok {
+		return false
+	}
+	toRank, ok := orderStatusRanks[to]
+	if !ok {
+		return false
+	}
+
+	return toRank > fromRank
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NoPageLimit      = 0
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+
+	NextCursorHeader   = "X-Next-Cursor"
+	SummaryCountHeader = "X-Summary-Count"
+	SummaryTotalHeader = "X-Summary-Total"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	timePart, idPart, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	cursorTime, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Time: cursorTime, ID: id}, nil
+}
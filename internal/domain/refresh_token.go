@@ -1,10 +1,30 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is expired or invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
 
 type RefreshToken struct {
 	ID        int64
 	UserID    int64
 	Token     string
 	ExpiresAt time.Time
+	FamilyID  string
+	UsedAt    *time.Time
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
@@ -10,3 +10,8 @@ type DBUser struct {
 	Login        string
 	PasswordHash string
 }
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
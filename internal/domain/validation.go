@@ -0,0 +1,9 @@
+package domain
+
+const MaxLoginLength = 50
+
+type ValidationViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
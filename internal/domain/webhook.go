@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+const (
+	WebhookOrderProcessed    = "order.processed"
+	WebhookOrderInvalid      = "order.invalid"
+	WebhookWithdrawalCreated = "withdrawal.created"
+
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryFailed    = "FAILED"
+)
+
+var webhookEventTypes = []string{WebhookOrderProcessed, WebhookOrderInvalid, WebhookWithdrawalCreated}
+
+type WebhookSubscription struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookOrderEvent struct {
+	UserID  int64    `json:"user_id"`
+	Order   string   `json:"order"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+type WebhookWithdrawalEvent struct {
+	UserID int64   `json:"user_id"`
+	Order  string  `json:"order"`
+	Sum    float64 `json:"sum"`
+}
+
+type WebhookDelivery struct {
+	ID        int64
+	Attempts  int
+	URL       string
+	Secret    string
+	EventID   int64
+	EventType string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+type WebhookAttempt struct {
+	DeliveryID     int64
+	ResponseStatus int
+	Error          string
+	Duration       time.Duration
+	Delivered      bool
+	NextAttemptAt  *time.Time
+}
+
+type WebhookDeliveryLogEntry struct {
+	DeliveryID     int64     `json:"delivery_id"`
+	EventID        int64     `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Status         string    `json:"status"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+	ResponseStatus *int      `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMS     int64     `json:"duration_ms"`
+}
+
+func IsKnownWebhookEvent(eventType string) bool {
+	return slices.Contains(webhookEventTypes, eventType)
+}
@@ -15,3 +15,28 @@ type DBWithdrawal struct {
 	ProcessedAt time.Time
 	UserID      int64
 }
+
+type WithdrawalFilter struct {
+	UserID    int64
+	Processed TimeRange
+	MinSum    float64
+	Limit     int
+	After     *Cursor
+}
+
+type WithdrawalSummary struct {
+	Count int64   `json:"count"`
+	Total float64 `json:"total"`
+}
+
+type WithdrawalPage struct {
+	Withdrawals []*Withdrawal
+	NextCursor  *Cursor
+	Summary     WithdrawalSummary
+}
+
+type WithdrawalHistory struct {
+	Withdrawals []*Withdrawal     `json:"withdrawals"`
+	Summary     WithdrawalSummary `json:"summary"`
+	NextCursor  string            `json:"next_cursor,omitempty"`
+}
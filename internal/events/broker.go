@@ -0,0 +1,98 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	subscriptionBuffer = 16
+)
+
+type subscription struct {
+	ch chan *domain.Event
+}
+
+type Broker struct {
+	logger *zap.SugaredLogger
+
+	mu          sync.RWMutex
+	closed      bool
+	subscribers map[int64]map[*subscription]struct{}
+}
+
+func NewBroker(lgr *zap.SugaredLogger) *Broker {
+	return &Broker{
+		logger:      lgr,
+		subscribers: make(map[int64]map[*subscription]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe(userID int64) (<-chan *domain.Event, func()) {
+	sub := &subscription{ch: make(chan *domain.Event, subscriptionBuffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() { b.unsubscribe(userID, sub) })
+	}
+}
+
+func (b *Broker) unsubscribe(userID int64, sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[userID][sub]; !ok {
+		return
+	}
+
+	delete(b.subscribers[userID], sub)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+	close(sub.ch)
+}
+
+func (b *Broker) Publish(event *domain.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers[event.UserID] {
+		select {
+		case sub.ch <- event:
+		default:
+			b.logger.Warnf("Events broker: dropped %s event for slow subscriber of user_id: %d", event.Type, event.UserID)
+		}
+	}
+}
+
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+	for _, subs := range b.subscribers {
+		for sub := range subs {
+			close(sub.ch)
+		}
+	}
+	b.subscribers = make(map[int64]map[*subscription]struct{})
+}
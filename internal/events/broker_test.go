@@ -0,0 +1,70 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestBroker_PublishRoutesByUser(t *testing.T) {
+	broker := NewBroker(zap.NewNop().Sugar())
+
+	first, unsubscribeFirst := broker.Subscribe(1)
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := broker.Subscribe(1)
+	defer unsubscribeSecond()
+	other, unsubscribeOther := broker.Subscribe(2)
+	defer unsubscribeOther()
+
+	event := &domain.Event{Type: domain.EventBalance, UserID: 1, Data: json.RawMessage(`{"current":10,"withdrawn":0}`)}
+	broker.Publish(event)
+
+	assert.Equal(t, event, <-first)
+	assert.Equal(t, event, <-second)
+	assert.Empty(t, other)
+}
+
+func TestBroker_UnsubscribeClosesChannel(t *testing.T) {
+	broker := NewBroker(zap.NewNop().Sugar())
+
+	events, unsubscribe := broker.Subscribe(1)
+	unsubscribe()
+	unsubscribe()
+
+	_, ok := <-events
+	assert.False(t, ok)
+	assert.Empty(t, broker.subscribers)
+
+	broker.Publish(&domain.Event{Type: domain.EventBalance, UserID: 1})
+}
+
+func TestBroker_DropsEventsForSlowSubscriber(t *testing.T) {
+	broker := NewBroker(zap.NewNop().Sugar())
+
+	events, unsubscribe := broker.Subscribe(1)
+	defer unsubscribe()
+
+	for range subscriptionBuffer + 5 {
+		broker.Publish(&domain.Event{Type: domain.EventOrderStatus, UserID: 1})
+	}
+
+	assert.Len(t, events, subscriptionBuffer)
+}
+
+func TestBroker_CloseEndsAllSubscriptions(t *testing.T) {
+	broker := NewBroker(zap.NewNop().Sugar())
+
+	events, unsubscribe := broker.Subscribe(1)
+	broker.Close()
+	unsubscribe()
+
+	_, ok := <-events
+	assert.False(t, ok)
+
+	late, _ := broker.Subscribe(1)
+	_, ok = <-late
+	assert.False(t, ok)
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	reconnectDelay = 5 * time.Second
+)
+
+type Publisher interface {
+	Publish(event *domain.Event)
+	Close()
+}
+
+type Listener struct {
+	logger      *zap.SugaredLogger
+	databaseURI string
+	publisher   Publisher
+}
+
+func NewListener(lgr *zap.SugaredLogger, databaseURI string, publisher Publisher) *Listener {
+	return &Listener{
+		logger:      lgr,
+		databaseURI: databaseURI,
+		publisher:   publisher,
+	}
+}
+
+func (l *Listener) Run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer l.publisher.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		if err := l.listen(ctx); err != nil && ctx.Err() == nil {
+			l.logger.Errorf("Events Listener: %s, reconnecting in %s", err.Error(), reconnectDelay)
+		}
+
+		select {
+		case <-ctx.Done():
+			l.logger.Info("Shutting down Events Listener")
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, l.databaseURI)
+	if err != nil {
+		return fmt.Errorf("error connecting: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{domain.EventsChannel}.Sanitize()); err != nil {
+		return fmt.Errorf("error subscribing to %s: %w", domain.EventsChannel, err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("error waiting for notification: %w", err)
+		}
+		l.dispatch(notification.Payload)
+	}
+}
+
+func (l *Listener) dispatch(payload string) {
+	var event domain.Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		l.logger.Warnf("Events Listener: skipping malformed notification, err: %s", err.Error())
+		return
+	}
+
+	l.publisher.Publish(&event)
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestListener_DispatchPublishesDecodedEvents(t *testing.T) {
+	broker := NewBroker(zap.NewNop().Sugar())
+	listener := NewListener(zap.NewNop().Sugar(), "", broker)
+
+	events, unsubscribe := broker.Subscribe(3)
+	defer unsubscribe()
+
+	listener.dispatch(`not json`)
+	listener.dispatch(`{"type":"order_status","user_id":3,"data":{"number":"12345678903","status":"PROCESSED"}}`)
+
+	assert.Equal(t, &domain.Event{
+		Type:   domain.EventOrderStatus,
+		UserID: 3,
+		Data:   json.RawMessage(`{"number":"12345678903","status":"PROCESSED"}`),
+	}, <-events)
+	assert.Empty(t, events)
+}
+
+func TestListener_RunStopsAndClosesBroker(t *testing.T) {
+	broker := NewBroker(zap.NewNop().Sugar())
+	listener := NewListener(zap.NewNop().Sugar(), "postgres://127.0.0.1:1/gophermart?connect_timeout=1", broker)
+
+	events, _ := broker.Subscribe(1)
+
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go listener.Run(stopCh, &wg)
+
+	close(stopCh)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("listener did not stop")
+	}
+
+	_, ok := <-events
+	assert.False(t, ok)
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"go.uber.org/zap"
+)
+
+type NotifierRepository interface {
+	GetUserBalance(userID int64) (*domain.Balance, error)
+	NotifyEvent(event *domain.Event) error
+}
+
+type Notifier struct {
+	logger *zap.SugaredLogger
+	repo   NotifierRepository
+}
+
+func NewNotifier(lgr *zap.SugaredLogger, repo NotifierRepository) *Notifier {
+	return &Notifier{
+		logger: lgr,
+		repo:   repo,
+	}
+}
+
+func (n *Notifier) OrderStatusChanged(userID int64, number, status string, accrual *float64) {
+	n.notify(userID, domain.EventOrderStatus, &domain.OrderStatusEvent{Number: number, Status: status, Accrual: accrual})
+
+	if accrual != nil {
+		n.BalanceChanged(userID)
+	}
+}
+
+func (n *Notifier) BalanceChanged(userID int64) {
+	balance, err := n.repo.GetUserBalance(userID)
+	if err != nil {
+		n.logger.Warnf("Events notifier: can't load balance for user_id: %d, err: %s", userID, err.Error())
+		return
+	}
+	n.notify(userID, domain.EventBalance, balance)
+}
+
+func (n *Notifier) notify(userID int64, eventType string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		n.logger.Warnf("Events notifier: can't encode %s event for user_id: %d, err: %s", eventType, userID, err.Error())
+		return
+	}
+
+	if err := n.repo.NotifyEvent(&domain.Event{Type: eventType, UserID: userID, Data: payload}); err != nil {
+		n.logger.Warnf("Events notifier: %s event for user_id: %d was not sent, err: %s", eventType, userID, err.Error())
+	}
+}
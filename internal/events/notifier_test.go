@@ -0,0 +1,86 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestNotifier_OrderStatusChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockNotifierRepository(ctrl)
+	notifier := NewNotifier(zap.NewNop().Sugar(), mockRepo)
+
+	accrual := 500.0
+
+	gomock.InOrder(
+		mockRepo.EXPECT().NotifyEvent(&domain.Event{
+			Type:   domain.EventOrderStatus,
+			UserID: 1,
+			Data:   json.RawMessage(`{"number":"12345678903","status":"PROCESSED","accrual":500}`),
+		}).Return(nil),
+		mockRepo.EXPECT().GetUserBalance(int64(1)).Return(&domain.Balance{BalanceSum: 729.98, WithdrawalSum: 10}, nil),
+		mockRepo.EXPECT().NotifyEvent(&domain.Event{
+			Type:   domain.EventBalance,
+			UserID: 1,
+			Data:   json.RawMessage(`{"current":729.98,"withdrawn":10}`),
+		}).Return(nil),
+	)
+
+	notifier.OrderStatusChanged(1, "12345678903", domain.OrderStatusProcessed, &accrual)
+}
+
+func TestNotifier_OrderStatusChanged_WithoutAccrual(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockNotifierRepository(ctrl)
+	notifier := NewNotifier(zap.NewNop().Sugar(), mockRepo)
+
+	mockRepo.EXPECT().NotifyEvent(&domain.Event{
+		Type:   domain.EventOrderStatus,
+		UserID: 1,
+		Data:   json.RawMessage(`{"number":"12345678903","status":"PROCESSING"}`),
+	}).Return(errors.New("database error"))
+
+	notifier.OrderStatusChanged(1, "12345678903", domain.OrderStatusProcessing, nil)
+}
+
+func TestNotifier_OrderStatusChanged_BalanceError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockNotifierRepository(ctrl)
+	notifier := NewNotifier(zap.NewNop().Sugar(), mockRepo)
+
+	accrual := 1.0
+
+	mockRepo.EXPECT().NotifyEvent(gomock.Any()).Return(nil)
+	mockRepo.EXPECT().GetUserBalance(int64(1)).Return(nil, errors.New("database error"))
+
+	notifier.OrderStatusChanged(1, "12345678903", domain.OrderStatusProcessed, &accrual)
+}
+
+func TestNotifier_BalanceChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockNotifierRepository(ctrl)
+	notifier := NewNotifier(zap.NewNop().Sugar(), mockRepo)
+
+	mockRepo.EXPECT().GetUserBalance(int64(1)).Return(&domain.Balance{BalanceSum: 679.98, WithdrawalSum: 60}, nil)
+	mockRepo.EXPECT().NotifyEvent(&domain.Event{
+		Type:   domain.EventBalance,
+		UserID: 1,
+		Data:   json.RawMessage(`{"current":679.98,"withdrawn":60}`),
+	}).Return(nil)
+
+	notifier.BalanceChanged(1)
+}
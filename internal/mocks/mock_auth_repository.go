@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/api/middleware/auth.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/api/middleware/auth.go -destination=internal/mocks/mock_auth_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAuthRepository is a mock of AuthRepository interface.
+type MockAuthRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockAuthRepositoryMockRecorder is the mock recorder for MockAuthRepository.
+type MockAuthRepositoryMockRecorder struct {
+	mock *MockAuthRepository
+}
+
+// NewMockAuthRepository creates a new mock instance.
+func NewMockAuthRepository(ctrl *gomock.Controller) *MockAuthRepository {
+	mock := &MockAuthRepository{ctrl: ctrl}
+	mock.recorder = &MockAuthRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthRepository) EXPECT() *MockAuthRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetRefreshToken mocks base method.
+func (m *MockAuthRepository) GetRefreshToken(token string) (*domain.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshToken", token)
+	ret0, _ := ret[0].(*domain.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshToken indicates an expected call of GetRefreshToken.
+func (mr *MockAuthRepositoryMockRecorder) GetRefreshToken(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockAuthRepository)(nil).GetRefreshToken), token)
+}
+
+// GetUserByID mocks base method.
+func (m *MockAuthRepository) GetUserByID(userID int64) (*domain.DBUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", userID)
+	ret0, _ := ret[0].(*domain.DBUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockAuthRepositoryMockRecorder) GetUserByID(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockAuthRepository)(nil).GetUserByID), userID)
+}
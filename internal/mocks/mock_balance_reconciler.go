@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/service/balance_reconciler.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/service/balance_reconciler.go -destination=internal/mocks/mock_balance_reconciler.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockBalanceDriftsRepository is a mock of BalanceDriftsRepository interface.
+type MockBalanceDriftsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockBalanceDriftsRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockBalanceDriftsRepositoryMockRecorder is the mock recorder for MockBalanceDriftsRepository.
+type MockBalanceDriftsRepositoryMockRecorder struct {
+	mock *MockBalanceDriftsRepository
+}
+
+// NewMockBalanceDriftsRepository creates a new mock instance.
+func NewMockBalanceDriftsRepository(ctrl *gomock.Controller) *MockBalanceDriftsRepository {
+	mock := &MockBalanceDriftsRepository{ctrl: ctrl}
+	mock.recorder = &MockBalanceDriftsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBalanceDriftsRepository) EXPECT() *MockBalanceDriftsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindBalanceDrifts mocks base method.
+func (m *MockBalanceDriftsRepository) FindBalanceDrifts() ([]*domain.BalanceDrift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindBalanceDrifts")
+	ret0, _ := ret[0].([]*domain.BalanceDrift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindBalanceDrifts indicates an expected call of FindBalanceDrifts.
+func (mr *MockBalanceDriftsRepositoryMockRecorder) FindBalanceDrifts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBalanceDrifts", reflect.TypeOf((*MockBalanceDriftsRepository)(nil).FindBalanceDrifts))
+}
@@ -12,6 +12,7 @@ package mocks
 import (
 	reflect "reflect"
 
+	domain "github.com/frolmr/gophermart/internal/domain"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -39,32 +40,17 @@ func (m *MockBalanceRepository) EXPECT() *MockBalanceRepositoryMockRecorder {
 	return m.recorder
 }
 
-// GetUserCurrentBalance mocks base method.
-func (m *MockBalanceRepository) GetUserCurrentBalance(userID int64) (float64, error) {
+// GetUserBalance mocks base method.
+func (m *MockBalanceRepository) GetUserBalance(userID int64) (*domain.Balance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserCurrentBalance", userID)
-	ret0, _ := ret[0].(float64)
+	ret := m.ctrl.Call(m, "GetUserBalance", userID)
+	ret0, _ := ret[0].(*domain.Balance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserCurrentBalance indicates an expected call of GetUserCurrentBalance.
-func (mr *MockBalanceRepositoryMockRecorder) GetUserCurrentBalance(userID any) *gomock.Call {
+// GetUserBalance indicates an expected call of GetUserBalance.
+func (mr *MockBalanceRepositoryMockRecorder) GetUserBalance(userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserCurrentBalance", reflect.TypeOf((*MockBalanceRepository)(nil).GetUserCurrentBalance), userID)
-}
-
-// GetUserWithdrawalsSum mocks base method.
-func (m *MockBalanceRepository) GetUserWithdrawalsSum(userID int64) (float64, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserWithdrawalsSum", userID)
-	ret0, _ := ret[0].(float64)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// GetUserWithdrawalsSum indicates an expected call of GetUserWithdrawalsSum.
-func (mr *MockBalanceRepositoryMockRecorder) GetUserWithdrawalsSum(userID any) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawalsSum", reflect.TypeOf((*MockBalanceRepository)(nil).GetUserWithdrawalsSum), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockBalanceRepository)(nil).GetUserBalance), userID)
 }
@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/api/handlers/events_handler.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/api/handlers/events_handler.go -destination=internal/mocks/mock_events_subscriber.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockEventsSubscriber is a mock of EventsSubscriber interface.
+type MockEventsSubscriber struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventsSubscriberMockRecorder
+	isgomock struct{}
+}
+
+// MockEventsSubscriberMockRecorder is the mock recorder for MockEventsSubscriber.
+type MockEventsSubscriberMockRecorder struct {
+	mock *MockEventsSubscriber
+}
+
+// NewMockEventsSubscriber creates a new mock instance.
+func NewMockEventsSubscriber(ctrl *gomock.Controller) *MockEventsSubscriber {
+	mock := &MockEventsSubscriber{ctrl: ctrl}
+	mock.recorder = &MockEventsSubscriberMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventsSubscriber) EXPECT() *MockEventsSubscriberMockRecorder {
+	return m.recorder
+}
+
+// Subscribe mocks base method.
+func (m *MockEventsSubscriber) Subscribe(userID int64) (<-chan *domain.Event, func()) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", userID)
+	ret0, _ := ret[0].(<-chan *domain.Event)
+	ret1, _ := ret[1].(func())
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockEventsSubscriberMockRecorder) Subscribe(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockEventsSubscriber)(nil).Subscribe), userID)
+}
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/api/handlers/admin_handler.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/api/handlers/admin_handler.go -destination=internal/mocks/mock_login_attempts_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginAttemptsRepository is a mock of LoginAttemptsRepository interface.
+type MockLoginAttemptsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAttemptsRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockLoginAttemptsRepositoryMockRecorder is the mock recorder for MockLoginAttemptsRepository.
+type MockLoginAttemptsRepositoryMockRecorder struct {
+	mock *MockLoginAttemptsRepository
+}
+
+// NewMockLoginAttemptsRepository creates a new mock instance.
+func NewMockLoginAttemptsRepository(ctrl *gomock.Controller) *MockLoginAttemptsRepository {
+	mock := &MockLoginAttemptsRepository{ctrl: ctrl}
+	mock.recorder = &MockLoginAttemptsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAttemptsRepository) EXPECT() *MockLoginAttemptsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ClearLoginAttempts mocks base method.
+func (m *MockLoginAttemptsRepository) ClearLoginAttempts(key domain.LoginAttemptKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearLoginAttempts", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearLoginAttempts indicates an expected call of ClearLoginAttempts.
+func (mr *MockLoginAttemptsRepositoryMockRecorder) ClearLoginAttempts(key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearLoginAttempts", reflect.TypeOf((*MockLoginAttemptsRepository)(nil).ClearLoginAttempts), key)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/events/notifier.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/events/notifier.go -destination=internal/mocks/mock_notifier_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockNotifierRepository is a mock of NotifierRepository interface.
+type MockNotifierRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockNotifierRepositoryMockRecorder is the mock recorder for MockNotifierRepository.
+type MockNotifierRepositoryMockRecorder struct {
+	mock *MockNotifierRepository
+}
+
+// NewMockNotifierRepository creates a new mock instance.
+func NewMockNotifierRepository(ctrl *gomock.Controller) *MockNotifierRepository {
+	mock := &MockNotifierRepository{ctrl: ctrl}
+	mock.recorder = &MockNotifierRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifierRepository) EXPECT() *MockNotifierRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetUserBalance mocks base method.
+func (m *MockNotifierRepository) GetUserBalance(userID int64) (*domain.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserBalance", userID)
+	ret0, _ := ret[0].(*domain.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserBalance indicates an expected call of GetUserBalance.
+func (mr *MockNotifierRepositoryMockRecorder) GetUserBalance(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockNotifierRepository)(nil).GetUserBalance), userID)
+}
+
+// NotifyEvent mocks base method.
+func (m *MockNotifierRepository) NotifyEvent(event *domain.Event) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyEvent", event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyEvent indicates an expected call of NotifyEvent.
+func (mr *MockNotifierRepositoryMockRecorder) NotifyEvent(event any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyEvent", reflect.TypeOf((*MockNotifierRepository)(nil).NotifyEvent), event)
+}
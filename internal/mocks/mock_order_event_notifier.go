@@ -0,0 +1,52 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/service/order_events.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/service/order_events.go -destination=internal/mocks/mock_order_event_notifier.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOrderEventNotifier is a mock of OrderEventNotifier interface.
+type MockOrderEventNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockOrderEventNotifierMockRecorder
+	isgomock struct{}
+}
+
+// MockOrderEventNotifierMockRecorder is the mock recorder for MockOrderEventNotifier.
+type MockOrderEventNotifierMockRecorder struct {
+	mock *MockOrderEventNotifier
+}
+
+// NewMockOrderEventNotifier creates a new mock instance.
+func NewMockOrderEventNotifier(ctrl *gomock.Controller) *MockOrderEventNotifier {
+	mock := &MockOrderEventNotifier{ctrl: ctrl}
+	mock.recorder = &MockOrderEventNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOrderEventNotifier) EXPECT() *MockOrderEventNotifierMockRecorder {
+	return m.recorder
+}
+
+// OrderStatusChanged mocks base method.
+func (m *MockOrderEventNotifier) OrderStatusChanged(userID int64, number, status string, accrual *float64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OrderStatusChanged", userID, number, status, accrual)
+}
+
+// OrderStatusChanged indicates an expected call of OrderStatusChanged.
+func (mr *MockOrderEventNotifierMockRecorder) OrderStatusChanged(userID, number, status, accrual any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderStatusChanged", reflect.TypeOf((*MockOrderEventNotifier)(nil).OrderStatusChanged), userID, number, status, accrual)
+}
@@ -41,11 +41,13 @@ func (m *MockOrdersRepository) EXPECT() *MockOrdersRepositoryMockRecorder {
 }
 
 // CreateOrder mocks base method.
-func (m *MockOrdersRepository) CreateOrder(number string, userID int64) error {
+func (m *MockOrdersRepository) CreateOrder(number string, userID int64) (int64, bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateOrder", number, userID)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // CreateOrder indicates an expected call of CreateOrder.
@@ -54,34 +56,49 @@ func (mr *MockOrdersRepositoryMockRecorder) CreateOrder(number, userID any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockOrdersRepository)(nil).CreateOrder), number, userID)
 }
 
-// FindOrderByNumber mocks base method.
-func (m *MockOrdersRepository) FindOrderByNumber(number string) (*domain.DBOrder, error) {
+// CreateOrders mocks base method.
+func (m *MockOrdersRepository) CreateOrders(numbers []string, userID int64) ([]*domain.OrderUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrders", numbers, userID)
+	ret0, _ := ret[0].([]*domain.OrderUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrders indicates an expected call of CreateOrders.
+func (mr *MockOrdersRepositoryMockRecorder) CreateOrders(numbers, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrders", reflect.TypeOf((*MockOrdersRepository)(nil).CreateOrders), numbers, userID)
+}
+
+// GetUserOrderDetails mocks base method.
+func (m *MockOrdersRepository) GetUserOrderDetails(userID int64, number string) (*domain.OrderDetails, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOrderByNumber", number)
-	ret0, _ := ret[0].(*domain.DBOrder)
+	ret := m.ctrl.Call(m, "GetUserOrderDetails", userID, number)
+	ret0, _ := ret[0].(*domain.OrderDetails)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindOrderByNumber indicates an expected call of FindOrderByNumber.
-func (mr *MockOrdersRepositoryMockRecorder) FindOrderByNumber(number any) *gomock.Call {
+// GetUserOrderDetails indicates an expected call of GetUserOrderDetails.
+func (mr *MockOrdersRepositoryMockRecorder) GetUserOrderDetails(userID, number any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrderByNumber", reflect.TypeOf((*MockOrdersRepository)(nil).FindOrderByNumber), number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrderDetails", reflect.TypeOf((*MockOrdersRepository)(nil).GetUserOrderDetails), userID, number)
 }
 
-// GetAllUserOrders mocks base method.
-func (m *MockOrdersRepository) GetAllUserOrders(userID int64) ([]*domain.Order, error) {
+// GetUserOrders mocks base method.
+func (m *MockOrdersRepository) GetUserOrders(filter domain.OrderFilter) (*domain.OrderPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUserOrders", userID)
-	ret0, _ := ret[0].([]*domain.Order)
+	ret := m.ctrl.Call(m, "GetUserOrders", filter)
+	ret0, _ := ret[0].(*domain.OrderPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllUserOrders indicates an expected call of GetAllUserOrders.
-func (mr *MockOrdersRepositoryMockRecorder) GetAllUserOrders(userID any) *gomock.Call {
+// GetUserOrders indicates an expected call of GetUserOrders.
+func (mr *MockOrdersRepositoryMockRecorder) GetUserOrders(filter any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUserOrders", reflect.TypeOf((*MockOrdersRepository)(nil).GetAllUserOrders), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrders", reflect.TypeOf((*MockOrdersRepository)(nil).GetUserOrders), filter)
 }
 
 // GetAllUnprocessedOrders mocks base method.
@@ -99,6 +116,20 @@ func (mr *MockOrdersRepositoryMockRecorder) GetAllUnprocessedOrders() *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUnprocessedOrders", reflect.TypeOf((*MockOrdersRepository)(nil).GetAllUnprocessedOrders))
 }
 
+// RecordOrderPoll mocks base method.
+func (m *MockOrdersRepository) RecordOrderPoll(id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordOrderPoll", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordOrderPoll indicates an expected call of RecordOrderPoll.
+func (mr *MockOrdersRepositoryMockRecorder) RecordOrderPoll(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordOrderPoll", reflect.TypeOf((*MockOrdersRepository)(nil).RecordOrderPoll), id)
+}
+
 // UpdateOrderAccrualStatus mocks base method.
 func (m *MockOrdersRepository) UpdateOrderAccrualStatus(id int64, status string, accrual *float64) error {
 	m.ctrl.T.Helper()
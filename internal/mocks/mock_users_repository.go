@@ -41,19 +41,75 @@ func (m *MockUsersRepository) EXPECT() *MockUsersRepositoryMockRecorder {
 	return m.recorder
 }
 
-// CreateAndReturnUser mocks base method.
-func (m *MockUsersRepository) CreateAndReturnUser(login, password string) (*domain.DBUser, error) {
+// AnonymizeUser mocks base method.
+func (m *MockUsersRepository) AnonymizeUser(userID int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateAndReturnUser", login, password)
+	ret := m.ctrl.Call(m, "AnonymizeUser", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeUser indicates an expected call of AnonymizeUser.
+func (mr *MockUsersRepositoryMockRecorder) AnonymizeUser(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUser", reflect.TypeOf((*MockUsersRepository)(nil).AnonymizeUser), userID)
+}
+
+// ClearLoginAttempts mocks base method.
+func (m *MockUsersRepository) ClearLoginAttempts(key domain.LoginAttemptKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearLoginAttempts", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearLoginAttempts indicates an expected call of ClearLoginAttempts.
+func (mr *MockUsersRepositoryMockRecorder) ClearLoginAttempts(key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearLoginAttempts", reflect.TypeOf((*MockUsersRepository)(nil).ClearLoginAttempts), key)
+}
+
+// CreateUser mocks base method.
+func (m *MockUsersRepository) CreateUser(login, passwordHash string) (*domain.DBUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", login, passwordHash)
 	ret0, _ := ret[0].(*domain.DBUser)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateAndReturnUser indicates an expected call of CreateAndReturnUser.
-func (mr *MockUsersRepositoryMockRecorder) CreateAndReturnUser(login, password any) *gomock.Call {
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockUsersRepositoryMockRecorder) CreateUser(login, passwordHash any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAndReturnUser", reflect.TypeOf((*MockUsersRepository)(nil).CreateAndReturnUser), login, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUsersRepository)(nil).CreateUser), login, passwordHash)
+}
+
+// DeleteRefreshToken mocks base method.
+func (m *MockUsersRepository) DeleteRefreshToken(token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRefreshToken", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRefreshToken indicates an expected call of DeleteRefreshToken.
+func (mr *MockUsersRepositoryMockRecorder) DeleteRefreshToken(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRefreshToken", reflect.TypeOf((*MockUsersRepository)(nil).DeleteRefreshToken), token)
+}
+
+// DeleteUserRefreshTokens mocks base method.
+func (m *MockUsersRepository) DeleteUserRefreshTokens(userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserRefreshTokens", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserRefreshTokens indicates an expected call of DeleteUserRefreshTokens.
+func (mr *MockUsersRepositoryMockRecorder) DeleteUserRefreshTokens(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserRefreshTokens", reflect.TypeOf((*MockUsersRepository)(nil).DeleteUserRefreshTokens), userID)
 }
 
 // GetRefreshToken mocks base method.
@@ -71,6 +127,21 @@ func (mr *MockUsersRepositoryMockRecorder) GetRefreshToken(token any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockUsersRepository)(nil).GetRefreshToken), token)
 }
 
+// GetUserByID mocks base method.
+func (m *MockUsersRepository) GetUserByID(userID int64) (*domain.DBUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", userID)
+	ret0, _ := ret[0].(*domain.DBUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockUsersRepositoryMockRecorder) GetUserByID(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUsersRepository)(nil).GetUserByID), userID)
+}
+
 // GetUserByLogin mocks base method.
 func (m *MockUsersRepository) GetUserByLogin(login string) (*domain.DBUser, error) {
 	m.ctrl.T.Helper()
@@ -86,6 +157,54 @@ func (mr *MockUsersRepositoryMockRecorder) GetUserByLogin(login any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockUsersRepository)(nil).GetUserByLogin), login)
 }
 
+// RegisterLoginAttempt mocks base method.
+func (m *MockUsersRepository) RegisterLoginAttempt(keys ...domain.LoginAttemptKey) (time.Time, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RegisterLoginAttempt", varargs...)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterLoginAttempt indicates an expected call of RegisterLoginAttempt.
+func (mr *MockUsersRepositoryMockRecorder) RegisterLoginAttempt(keys ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterLoginAttempt", reflect.TypeOf((*MockUsersRepository)(nil).RegisterLoginAttempt), keys...)
+}
+
+// RevertLoginAttempt mocks base method.
+func (m *MockUsersRepository) RevertLoginAttempt(key domain.LoginAttemptKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevertLoginAttempt", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevertLoginAttempt indicates an expected call of RevertLoginAttempt.
+func (mr *MockUsersRepositoryMockRecorder) RevertLoginAttempt(key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevertLoginAttempt", reflect.TypeOf((*MockUsersRepository)(nil).RevertLoginAttempt), key)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockUsersRepository) RotateRefreshToken(oldToken, newToken string, expiresAt time.Time) (*domain.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", oldToken, newToken, expiresAt)
+	ret0, _ := ret[0].(*domain.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockUsersRepositoryMockRecorder) RotateRefreshToken(oldToken, newToken, expiresAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockUsersRepository)(nil).RotateRefreshToken), oldToken, newToken, expiresAt)
+}
+
 // StoreRefreshToken mocks base method.
 func (m *MockUsersRepository) StoreRefreshToken(userID int64, token string, expiresAt time.Time) error {
 	m.ctrl.T.Helper()
@@ -99,3 +218,31 @@ func (mr *MockUsersRepositoryMockRecorder) StoreRefreshToken(userID, token, expi
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreRefreshToken", reflect.TypeOf((*MockUsersRepository)(nil).StoreRefreshToken), userID, token, expiresAt)
 }
+
+// UpdatePasswordHash mocks base method.
+func (m *MockUsersRepository) UpdatePasswordHash(userID int64, oldHash, newHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePasswordHash", userID, oldHash, newHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePasswordHash indicates an expected call of UpdatePasswordHash.
+func (mr *MockUsersRepositoryMockRecorder) UpdatePasswordHash(userID, oldHash, newHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePasswordHash", reflect.TypeOf((*MockUsersRepository)(nil).UpdatePasswordHash), userID, oldHash, newHash)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockUsersRepository) UpdateUserPassword(userID int64, passwordHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", userID, passwordHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockUsersRepositoryMockRecorder) UpdateUserPassword(userID, passwordHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockUsersRepository)(nil).UpdateUserPassword), userID, passwordHash)
+}
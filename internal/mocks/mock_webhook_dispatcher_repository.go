@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/service/webhook_dispatcher.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/service/webhook_dispatcher.go -destination=internal/mocks/mock_webhook_dispatcher_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	time "time"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWebhookRepository is a mock of WebhookRepository interface.
+type MockWebhookRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockWebhookRepositoryMockRecorder is the mock recorder for MockWebhookRepository.
+type MockWebhookRepositoryMockRecorder struct {
+	mock *MockWebhookRepository
+}
+
+// NewMockWebhookRepository creates a new mock instance.
+func NewMockWebhookRepository(ctrl *gomock.Controller) *MockWebhookRepository {
+	mock := &MockWebhookRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepository) EXPECT() *MockWebhookRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockWebhookRepository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*domain.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", limit, lease)
+	ret0, _ := ret[0].([]*domain.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) ClaimWebhookDeliveries(limit, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).ClaimWebhookDeliveries), limit, lease)
+}
+
+// PruneWebhookOutbox mocks base method.
+func (m *MockWebhookRepository) PruneWebhookOutbox(olderThan time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneWebhookOutbox", olderThan)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PruneWebhookOutbox indicates an expected call of PruneWebhookOutbox.
+func (mr *MockWebhookRepositoryMockRecorder) PruneWebhookOutbox(olderThan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneWebhookOutbox", reflect.TypeOf((*MockWebhookRepository)(nil).PruneWebhookOutbox), olderThan)
+}
+
+// RecordWebhookAttempt mocks base method.
+func (m *MockWebhookRepository) RecordWebhookAttempt(attempt *domain.WebhookAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordWebhookAttempt", attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordWebhookAttempt indicates an expected call of RecordWebhookAttempt.
+func (mr *MockWebhookRepositoryMockRecorder) RecordWebhookAttempt(attempt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookAttempt", reflect.TypeOf((*MockWebhookRepository)(nil).RecordWebhookAttempt), attempt)
+}
@@ -0,0 +1,100 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/api/handlers/webhooks_handler.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/api/handlers/webhooks_handler.go -destination=internal/mocks/mock_webhooks_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	domain "github.com/frolmr/gophermart/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWebhooksRepository is a mock of WebhooksRepository interface.
+type MockWebhooksRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhooksRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockWebhooksRepositoryMockRecorder is the mock recorder for MockWebhooksRepository.
+type MockWebhooksRepositoryMockRecorder struct {
+	mock *MockWebhooksRepository
+}
+
+// NewMockWebhooksRepository creates a new mock instance.
+func NewMockWebhooksRepository(ctrl *gomock.Controller) *MockWebhooksRepository {
+	mock := &MockWebhooksRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhooksRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhooksRepository) EXPECT() *MockWebhooksRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateWebhookSubscription mocks base method.
+func (m *MockWebhooksRepository) CreateWebhookSubscription(subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", subscription)
+	ret0, _ := ret[0].(*domain.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockWebhooksRepositoryMockRecorder) CreateWebhookSubscription(subscription any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockWebhooksRepository)(nil).CreateWebhookSubscription), subscription)
+}
+
+// DeleteWebhookSubscription mocks base method.
+func (m *MockWebhooksRepository) DeleteWebhookSubscription(id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscription", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookSubscription indicates an expected call of DeleteWebhookSubscription.
+func (mr *MockWebhooksRepositoryMockRecorder) DeleteWebhookSubscription(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscription", reflect.TypeOf((*MockWebhooksRepository)(nil).DeleteWebhookSubscription), id)
+}
+
+// GetWebhookDeliveryLog mocks base method.
+func (m *MockWebhooksRepository) GetWebhookDeliveryLog(subscriptionID int64, limit int) ([]*domain.WebhookDeliveryLogEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveryLog", subscriptionID, limit)
+	ret0, _ := ret[0].([]*domain.WebhookDeliveryLogEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveryLog indicates an expected call of GetWebhookDeliveryLog.
+func (mr *MockWebhooksRepositoryMockRecorder) GetWebhookDeliveryLog(subscriptionID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveryLog", reflect.TypeOf((*MockWebhooksRepository)(nil).GetWebhookDeliveryLog), subscriptionID, limit)
+}
+
+// GetWebhookSubscriptions mocks base method.
+func (m *MockWebhooksRepository) GetWebhookSubscriptions() ([]*domain.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookSubscriptions")
+	ret0, _ := ret[0].([]*domain.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookSubscriptions indicates an expected call of GetWebhookSubscriptions.
+func (mr *MockWebhooksRepositoryMockRecorder) GetWebhookSubscriptions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookSubscriptions", reflect.TypeOf((*MockWebhooksRepository)(nil).GetWebhookSubscriptions))
+}
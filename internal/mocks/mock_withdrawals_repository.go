@@ -54,32 +54,53 @@ func (mr *MockWithdrawalRepositoryMockRecorder) CreateWithdrawal(orderNumber, su
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithdrawal", reflect.TypeOf((*MockWithdrawalRepository)(nil).CreateWithdrawal), orderNumber, sum, userID)
 }
 
-// GetAllUserWithdrawals mocks base method.
-func (m *MockWithdrawalRepository) GetAllUserWithdrawals(userID int64) ([]*domain.Withdrawal, error) {
+// GetUserWithdrawals mocks base method.
+func (m *MockWithdrawalRepository) GetUserWithdrawals(filter domain.WithdrawalFilter) (*domain.WithdrawalPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUserWithdrawals", userID)
-	ret0, _ := ret[0].([]*domain.Withdrawal)
+	ret := m.ctrl.Call(m, "GetUserWithdrawals", filter)
+	ret0, _ := ret[0].(*domain.WithdrawalPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllUserWithdrawals indicates an expected call of GetAllUserWithdrawals.
-func (mr *MockWithdrawalRepositoryMockRecorder) GetAllUserWithdrawals(userID any) *gomock.Call {
+// GetUserWithdrawals indicates an expected call of GetUserWithdrawals.
+func (mr *MockWithdrawalRepositoryMockRecorder) GetUserWithdrawals(filter any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUserWithdrawals", reflect.TypeOf((*MockWithdrawalRepository)(nil).GetAllUserWithdrawals), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawals", reflect.TypeOf((*MockWithdrawalRepository)(nil).GetUserWithdrawals), filter)
 }
 
-// GetUserCurrentBalance mocks base method.
-func (m *MockWithdrawalRepository) GetUserCurrentBalance(userID int64) (float64, error) {
+// MockBalanceEventNotifier is a mock of BalanceEventNotifier interface.
+type MockBalanceEventNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockBalanceEventNotifierMockRecorder
+	isgomock struct{}
+}
+
+// MockBalanceEventNotifierMockRecorder is the mock recorder for MockBalanceEventNotifier.
+type MockBalanceEventNotifierMockRecorder struct {
+	mock *MockBalanceEventNotifier
+}
+
+// NewMockBalanceEventNotifier creates a new mock instance.
+func NewMockBalanceEventNotifier(ctrl *gomock.Controller) *MockBalanceEventNotifier {
+	mock := &MockBalanceEventNotifier{ctrl: ctrl}
+	mock.recorder = &MockBalanceEventNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBalanceEventNotifier) EXPECT() *MockBalanceEventNotifierMockRecorder {
+	return m.recorder
+}
+
+// BalanceChanged mocks base method.
+func (m *MockBalanceEventNotifier) BalanceChanged(userID int64) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserCurrentBalance", userID)
-	ret0, _ := ret[0].(float64)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	m.ctrl.Call(m, "BalanceChanged", userID)
 }
 
-// GetUserCurrentBalance indicates an expected call of GetUserCurrentBalance.
-func (mr *MockWithdrawalRepositoryMockRecorder) GetUserCurrentBalance(userID any) *gomock.Call {
+// BalanceChanged indicates an expected call of BalanceChanged.
+func (mr *MockBalanceEventNotifierMockRecorder) BalanceChanged(userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserCurrentBalance", reflect.TypeOf((*MockWithdrawalRepository)(nil).GetUserCurrentBalance), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceChanged", reflect.TypeOf((*MockBalanceEventNotifier)(nil).BalanceChanged), userID)
 }
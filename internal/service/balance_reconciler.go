@@ -0,0 +1,74 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	reconciliationInterval = 10 * time.Minute
+)
+
+type BalanceDriftsRepository interface {
+	FindBalanceDrifts() ([]*domain.BalanceDrift, error)
+}
+
+type BalanceReconciler struct {
+	logger *zap.SugaredLogger
+	repo   BalanceDriftsRepository
+}
+
+func NewBalanceReconciler(lgr *zap.SugaredLogger, repo BalanceDriftsRepository) *BalanceReconciler {
+	return &BalanceReconciler{
+		logger: lgr,
+		repo:   repo,
+	}
+}
+
+func (br *BalanceReconciler) Run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(reconciliationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := br.reconcile(); err != nil {
+				br.logger.Errorf("Balance Reconciler: failed to check balances: %s", err.Error())
+			}
+		case <-stopCh:
+			br.logger.Info("Shutting down Balance Reconciler")
+			return
+		}
+	}
+}
+
+func (br *BalanceReconciler) reconcile() ([]*domain.BalanceDrift, error) {
+	drifts, err := br.repo.FindBalanceDrifts()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(drifts) == 0 {
+		br.logger.Info("Balance Reconciler: No balance drift found")
+		return nil, nil
+	}
+
+	for _, drift := range drifts {
+		br.logger.Warnw("Balance Reconciler: balance drift detected",
+			"user_id", drift.UserID,
+			"current", drift.Current,
+			"expected_current", drift.ExpectedCurrent,
+			"withdrawn", drift.Withdrawn,
+			"expected_withdrawn", drift.ExpectedWithdrawn,
+			"ledger_current", drift.LedgerCurrent,
+			"ledger_withdrawn", drift.LedgerWithdrawn,
+		)
+	}
+
+	return drifts, nil
+}
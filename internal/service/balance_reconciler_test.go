@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestBalanceReconciler_Reconcile_NoDrift(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockBalanceDriftsRepository(ctrl)
+
+	logger := zap.NewNop().Sugar()
+	reconciler := NewBalanceReconciler(logger, mockRepo)
+
+	mockRepo.EXPECT().
+		FindBalanceDrifts().
+		Return(nil, nil)
+
+	drifts, err := reconciler.reconcile()
+
+	assert.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestBalanceReconciler_Reconcile_DriftFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockBalanceDriftsRepository(ctrl)
+
+	logger := zap.NewNop().Sugar()
+	reconciler := NewBalanceReconciler(logger, mockRepo)
+
+	expected := []*domain.BalanceDrift{
+		{UserID: 1, Current: 100, Withdrawn: 0, ExpectedCurrent: 90, ExpectedWithdrawn: 10},
+	}
+
+	mockRepo.EXPECT().
+		FindBalanceDrifts().
+		Return(expected, nil)
+
+	drifts, err := reconciler.reconcile()
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, drifts)
+}
+
+func TestBalanceReconciler_Reconcile_DatabaseError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockBalanceDriftsRepository(ctrl)
+
+	logger := zap.NewNop().Sugar()
+	reconciler := NewBalanceReconciler(logger, mockRepo)
+
+	mockRepo.EXPECT().
+		FindBalanceDrifts().
+		Return(nil, errors.New("database error"))
+
+	drifts, err := reconciler.reconcile()
+
+	assert.Error(t, err)
+	assert.Nil(t, drifts)
+}
@@ -0,0 +1,5 @@
+package service
+
+type OrderEventNotifier interface {
+	OrderStatusChanged(userID int64, number, status string, accrual *float64)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"sync"
 	"time"
 
@@ -16,19 +17,33 @@ const (
 type OrdersRepository interface {
 	GetAllUnprocessedOrders() ([]*domain.DBOrder, error)
 	UpdateOrderAccrualStatus(id int64, status string, accrual *float64) error
+	RecordOrderPoll(id int64) error
 }
 
 type OrderProcessor struct {
-	logger *zap.SugaredLogger
-	repo   OrdersRepository
-	client client.AccrualClientInterface
+	logger       *zap.SugaredLogger
+	repo         OrdersRepository
+	client       client.AccrualClientInterface
+	notifier     OrderEventNotifier
+	workersCount int
+
+	pauseMu     sync.Mutex
+	pausedUntil time.Time
 }
 
-func NewOrderProcessor(lgr *zap.SugaredLogger, repo OrdersRepository, client client.AccrualClientInterface) *OrderProcessor {
+func NewOrderProcessor(
+	lgr *zap.SugaredLogger,
+	repo OrdersRepository,
+	client client.AccrualClientInterface,
+	notifier OrderEventNotifier,
+	workersCount int,
+) *OrderProcessor {
 	return &OrderProcessor{
-		logger: lgr,
-		repo:   repo,
-		client: client,
+		logger:       lgr,
+		repo:         repo,
+		client:       client,
+		notifier:     notifier,
+		workersCount: max(workersCount, 1),
 	}
 }
 
@@ -41,9 +56,8 @@ func (op *OrderProcessor) Run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
 	for {
 		select {
 		case <-ticker.C:
-			err := op.processUnprocessedOrders()
-			if err != nil {
-				continue
+			if err := op.processUnprocessedOrders(stopCh); err != nil {
+				op.logger.Errorf("Order Processor: batch finished with errors: %s", err.Error())
 			}
 		case <-stopCh:
 			op.logger.Info("Shutting down Orders Processor")
@@ -53,29 +67,118 @@ func (op *OrderProcessor) Run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
 	}
 }
 
-func (op *OrderProcessor) processUnprocessedOrders() error {
+func (op *OrderProcessor) processUnprocessedOrders(stopCh <-chan struct{}) error {
+	if pausedUntil, paused := op.pausedTill(); paused {
+		op.logger.Debugf("Order Processor: accrual polling paused until %s", pausedUntil.Format(time.RFC3339))
+		return nil
+	}
+
 	ordersToProcess, err := op.repo.GetAllUnprocessedOrders()
 	if err != nil {
 		return err
 	}
 
-	if len(ordersToProcess) > 0 {
-		op.logger.Infof("Order Processor: Found %d orders to process", len(ordersToProcess))
+	if len(ordersToProcess) == 0 {
+		op.logger.Info("Order Processor: No orders to process")
+		return nil
+	}
 
-		for _, order := range ordersToProcess {
-			op.logger.Info("Processing order ", order.Number)
-			if err := op.processOrder(order); err != nil {
-				return err
+	op.logger.Infof("Order Processor: Found %d orders to process", len(ordersToProcess))
+
+	ordersCh := make(chan *domain.DBOrder)
+
+	var (
+		errsMu sync.Mutex
+		errs   []error
+	)
+
+	var workersWg sync.WaitGroup
+	for range min(op.workersCount, len(ordersToProcess)) {
+		workersWg.Add(1)
+		go func() {
+			defer workersWg.Done()
+			for order := range ordersCh {
+				if err := op.processOrderInWorker(order); err != nil {
+					errsMu.Lock()
+					errs = append(errs, err)
+					errsMu.Unlock()
+				}
 			}
+		}()
+	}
+
+	for _, order := range ordersToProcess {
+		if op.feedInterrupted(stopCh) {
+			break
+		}
+
+		select {
+		case ordersCh <- order:
+		case <-stopCh:
 		}
+	}
+	close(ordersCh)
+	workersWg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (op *OrderProcessor) processOrderInWorker(order *domain.DBOrder) error {
+	if _, paused := op.pausedTill(); paused {
+		return nil
+	}
+
+	op.logger.Info("Processing order ", order.Number)
+	err := op.processOrder(order)
+	if err == nil {
+		return nil
+	}
+
+	var rateLimitErr *client.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		pausedUntil := op.pause(rateLimitErr.RetryAfter)
+		op.logger.Warnf("Order Processor: accrual polling paused until %s", pausedUntil.Format(time.RFC3339))
 	} else {
-		op.logger.Info("Order Processor: No orders to process")
+		op.logger.Errorf("Order Processor: failed to process order %s: %s", order.Number, err.Error())
 	}
-	return nil
+
+	return err
+}
+
+func (op *OrderProcessor) feedInterrupted(stopCh <-chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+	}
+
+	_, paused := op.pausedTill()
+	return paused
+}
+
+func (op *OrderProcessor) pause(d time.Duration) time.Time {
+	op.pauseMu.Lock()
+	defer op.pauseMu.Unlock()
+
+	if until := time.Now().Add(d); until.After(op.pausedUntil) {
+		op.pausedUntil = until
+	}
+
+	return op.pausedUntil
+}
+
+func (op *OrderProcessor) pausedTill() (time.Time, bool) {
+	op.pauseMu.Lock()
+	defer op.pauseMu.Unlock()
+
+	return op.pausedUntil, time.Now().Before(op.pausedUntil)
 }
 
 func (op *OrderProcessor) processOrder(order *domain.DBOrder) error {
 	accrualOrder, err := op.client.RequestOrderState(order.Number)
+	if pollErr := op.repo.RecordOrderPoll(order.ID); pollErr != nil {
+		op.logger.Warnf("Order Processor: failed to record poll for order %s: %s", order.Number, pollErr.Error())
+	}
 	if err != nil {
 		return err
 	}
@@ -85,9 +188,15 @@ func (op *OrderProcessor) processOrder(order *domain.DBOrder) error {
 	}
 
 	if order.Status != accrualOrder.Status {
-		if err := op.repo.UpdateOrderAccrualStatus(order.ID, accrualOrder.Status, &accrualOrder.Accrual); err != nil {
+		var accrual *float64
+		if accrualOrder.Status == domain.OrderStatusProcessed {
+			accrual = &accrualOrder.Accrual
+		}
+
+		if err := op.repo.UpdateOrderAccrualStatus(order.ID, accrualOrder.Status, accrual); err != nil {
 			return err
 		}
+		op.notifier.OrderStatusChanged(order.UserID, order.Number, accrualOrder.Status, accrual)
 	}
 
 	return nil
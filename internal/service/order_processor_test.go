@@ -2,8 +2,12 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/frolmr/gophermart/internal/client"
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/internal/mocks"
 	"github.com/stretchr/testify/assert"
@@ -17,9 +21,10 @@ func TestOrderProcessor_ProcessUnprocessedOrders_Success(t *testing.T) {
 
 	mockRepo := mocks.NewMockOrdersRepository(ctrl)
 	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
 
 	logger := zap.NewNop().Sugar()
-	processor := NewOrderProcessor(logger, mockRepo, mockClient)
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 2)
 
 	ordersToProcess := []*domain.DBOrder{
 		{ID: 1, Number: "12345678903", Status: "NEW"},
@@ -34,19 +39,33 @@ func TestOrderProcessor_ProcessUnprocessedOrders_Success(t *testing.T) {
 		RequestOrderState("12345678903").
 		Return(&domain.AccrualOrder{Order: "12345678903", Status: "PROCESSED", Accrual: 10.5}, nil)
 
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(1)).
+		Return(nil)
+
 	mockRepo.EXPECT().
 		UpdateOrderAccrualStatus(int64(1), "PROCESSED", gomock.Any()).
 		Return(nil)
 
+	mockNotifier.EXPECT().
+		OrderStatusChanged(int64(0), "12345678903", "PROCESSED", gomock.Any())
+
 	mockClient.EXPECT().
 		RequestOrderState("98765432109").
 		Return(&domain.AccrualOrder{Order: "98765432109", Status: "PROCESSED", Accrual: 10.5}, nil)
 
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(2)).
+		Return(nil)
+
 	mockRepo.EXPECT().
 		UpdateOrderAccrualStatus(int64(2), "PROCESSED", gomock.Any()).
 		Return(nil)
 
-	err := processor.processUnprocessedOrders()
+	mockNotifier.EXPECT().
+		OrderStatusChanged(int64(0), "98765432109", "PROCESSED", gomock.Any())
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
 
 	assert.NoError(t, err)
 }
@@ -57,16 +76,17 @@ func TestOrderProcessor_ProcessUnprocessedOrders_NoOrders(t *testing.T) {
 
 	mockRepo := mocks.NewMockOrdersRepository(ctrl)
 	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
 
 	logger := zap.NewNop().Sugar()
 
-	processor := NewOrderProcessor(logger, mockRepo, mockClient)
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 2)
 
 	mockRepo.EXPECT().
 		GetAllUnprocessedOrders().
 		Return([]*domain.DBOrder{}, nil)
 
-	err := processor.processUnprocessedOrders()
+	err := processor.processUnprocessedOrders(make(chan struct{}))
 
 	assert.NoError(t, err)
 }
@@ -77,15 +97,16 @@ func TestOrderProcessor_ProcessUnprocessedOrders_DatabaseError(t *testing.T) {
 
 	mockRepo := mocks.NewMockOrdersRepository(ctrl)
 	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
 
 	logger := zap.NewNop().Sugar()
-	processor := NewOrderProcessor(logger, mockRepo, mockClient)
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 2)
 
 	mockRepo.EXPECT().
 		GetAllUnprocessedOrders().
 		Return(nil, errors.New("database error"))
 
-	err := processor.processUnprocessedOrders()
+	err := processor.processUnprocessedOrders(make(chan struct{}))
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "database error")
@@ -97,10 +118,11 @@ func TestOrderProcessor_ProcessUnprocessedOrders_ClientError(t *testing.T) {
 
 	mockRepo := mocks.NewMockOrdersRepository(ctrl)
 	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
 
 	logger := zap.NewNop().Sugar()
 
-	processor := NewOrderProcessor(logger, mockRepo, mockClient)
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 2)
 
 	ordersToProcess := []*domain.DBOrder{
 		{ID: 1, Number: "12345678903", Status: "NEW"},
@@ -114,8 +136,332 @@ func TestOrderProcessor_ProcessUnprocessedOrders_ClientError(t *testing.T) {
 		RequestOrderState("12345678903").
 		Return(nil, errors.New("client error"))
 
-	err := processor.processUnprocessedOrders()
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(1)).
+		Return(nil)
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "client error")
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_ErrorIsolation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 1)
+
+	ordersToProcess := []*domain.DBOrder{
+		{ID: 1, Number: "12345678903", Status: "NEW"},
+		{ID: 2, Number: "98765432109", Status: "NEW"},
+		{ID: 3, Number: "4561261212345467", Status: "NEW"},
+	}
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return(ordersToProcess, nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("12345678903").
+		Return(nil, errors.New("client error"))
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(1)).
+		Return(nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("98765432109").
+		Return(&domain.AccrualOrder{Order: "98765432109", Status: "PROCESSED", Accrual: 10.5}, nil)
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(2)).
+		Return(nil)
+
+	mockRepo.EXPECT().
+		UpdateOrderAccrualStatus(int64(2), "PROCESSED", gomock.Any()).
+		Return(errors.New("database error"))
+
+	mockClient.EXPECT().
+		RequestOrderState("4561261212345467").
+		Return(&domain.AccrualOrder{Order: "4561261212345467", Status: "INVALID"}, nil)
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(3)).
+		Return(nil)
+
+	mockRepo.EXPECT().
+		UpdateOrderAccrualStatus(int64(3), "INVALID", gomock.Any()).
+		Return(nil)
+
+	mockNotifier.EXPECT().
+		OrderStatusChanged(int64(0), "4561261212345467", "INVALID", gomock.Any())
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "client error")
+	assert.Contains(t, err.Error(), "database error")
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_Parallel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	workersCount := 4
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, workersCount)
+
+	ordersToProcess := make([]*domain.DBOrder, 0, workersCount)
+	for i := range workersCount {
+		ordersToProcess = append(ordersToProcess, &domain.DBOrder{ID: int64(i + 1), Number: fmt.Sprintf("order-%d", i), Status: "NEW"})
+	}
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return(ordersToProcess, nil)
+
+	var inFlight sync.WaitGroup
+	inFlight.Add(workersCount)
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(gomock.Any()).
+		Times(workersCount).
+		Return(nil)
+
+	mockClient.EXPECT().
+		RequestOrderState(gomock.Any()).
+		Times(workersCount).
+		DoAndReturn(func(number string) (*domain.AccrualOrder, error) {
+			inFlight.Done()
+			inFlight.Wait()
+			return nil, nil
+		})
+
+	done := make(chan error)
+	go func() {
+		done <- processor.processUnprocessedOrders(make(chan struct{}))
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("orders were not processed in parallel")
+	}
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_Stopped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 1)
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return([]*domain.DBOrder{{ID: 1, Number: "12345678903", Status: "NEW"}}, nil)
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	err := processor.processUnprocessedOrders(stopCh)
+
+	assert.NoError(t, err)
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_RateLimited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 1)
+
+	ordersToProcess := []*domain.DBOrder{
+		{ID: 1, Number: "12345678903", Status: "NEW"},
+		{ID: 2, Number: "98765432109", Status: "NEW"},
+	}
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return(ordersToProcess, nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("12345678903").
+		Return(nil, &client.RateLimitError{RetryAfter: time.Minute})
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(1)).
+		Return(nil)
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
+
+	var rateLimitErr *client.RateLimitError
+	assert.ErrorAs(t, err, &rateLimitErr)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), processor.pausedUntil, time.Second)
+
+	err = processor.processUnprocessedOrders(make(chan struct{}))
+
+	assert.NoError(t, err)
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_ResumesAfterPause(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 2)
+	processor.pausedUntil = time.Now().Add(-time.Second)
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return([]*domain.DBOrder{}, nil)
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
+
+	assert.NoError(t, err)
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_AccrualOnlyForProcessed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 1)
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return([]*domain.DBOrder{{ID: 1, Number: "12345678903", Status: "NEW"}}, nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("12345678903").
+		Return(&domain.AccrualOrder{Order: "12345678903", Status: "PROCESSING"}, nil)
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(1)).
+		Return(nil)
+
+	mockRepo.EXPECT().
+		UpdateOrderAccrualStatus(int64(1), "PROCESSING", gomock.Nil()).
+		Return(nil)
+
+	mockNotifier.EXPECT().
+		OrderStatusChanged(int64(0), "12345678903", "PROCESSING", gomock.Nil())
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
+
+	assert.NoError(t, err)
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_PollRecordFailureIsNotFatal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 1)
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return([]*domain.DBOrder{{ID: 1, Number: "12345678903", Status: "NEW"}}, nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("12345678903").
+		Return(&domain.AccrualOrder{Order: "12345678903", Status: "PROCESSED", Accrual: 5}, nil)
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(int64(1)).
+		Return(errors.New("database error"))
+
+	mockRepo.EXPECT().
+		UpdateOrderAccrualStatus(int64(1), "PROCESSED", gomock.Any()).
+		Return(nil)
+
+	mockNotifier.EXPECT().
+		OrderStatusChanged(int64(0), "12345678903", "PROCESSED", gomock.Any())
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
+
+	assert.NoError(t, err)
+}
+
+func TestOrderProcessor_ProcessUnprocessedOrders_NotifiesOwner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockClient := mocks.NewMockAccrualClientInterface(ctrl)
+	mockNotifier := mocks.NewMockOrderEventNotifier(ctrl)
+
+	logger := zap.NewNop().Sugar()
+
+	processor := NewOrderProcessor(logger, mockRepo, mockClient, mockNotifier, 1)
+
+	mockRepo.EXPECT().
+		GetAllUnprocessedOrders().
+		Return([]*domain.DBOrder{
+			{ID: 1, Number: "12345678903", Status: "PROCESSING", UserID: 7},
+			{ID: 2, Number: "98765432109", Status: "PROCESSING", UserID: 8},
+		}, nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("12345678903").
+		Return(&domain.AccrualOrder{Order: "12345678903", Status: "PROCESSED", Accrual: 42.5}, nil)
+
+	mockClient.EXPECT().
+		RequestOrderState("98765432109").
+		Return(&domain.AccrualOrder{Order: "98765432109", Status: "PROCESSING"}, nil)
+
+	mockRepo.EXPECT().
+		RecordOrderPoll(gomock.Any()).
+		Times(2).
+		Return(nil)
+
+	mockRepo.EXPECT().
+		UpdateOrderAccrualStatus(int64(1), "PROCESSED", gomock.Any()).
+		Return(nil)
+
+	mockNotifier.EXPECT().
+		OrderStatusChanged(int64(7), "12345678903", "PROCESSED", gomock.Cond(func(accrual *float64) bool {
+			return accrual != nil && *accrual == 42.5
+		}))
+
+	err := processor.processUnprocessedOrders(make(chan struct{}))
+
+	assert.NoError(t, err)
 }
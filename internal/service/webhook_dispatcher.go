@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/go-resty/resty/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookDispatchInterval = 1 * time.Second
+	webhookBatchSize        = 20
+	webhookDeliveryLease    = 1 * time.Minute
+	webhookMaxAttempts      = 8
+	webhookBaseBackoff      = 5 * time.Second
+	webhookMaxBackoff       = 1 * time.Hour
+	webhookPruneInterval    = 1 * time.Hour
+	webhookRetention        = 30 * 24 * time.Hour
+
+	WebhookEventHeader     = "X-Gophermart-Event"
+	WebhookDeliveryHeader  = "X-Gophermart-Delivery"
+	WebhookTimestampHeader = "X-Gophermart-Timestamp"
+	WebhookSignatureHeader = "X-Gophermart-Signature"
+)
+
+type WebhookRepository interface {
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*domain.WebhookDelivery, error)
+	RecordWebhookAttempt(attempt *domain.WebhookAttempt) error
+	PruneWebhookOutbox(olderThan time.Duration) (int64, error)
+}
+
+type webhookEnvelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type WebhookDispatcher struct {
+	logger     *zap.SugaredLogger
+	repo       WebhookRepository
+	httpClient *resty.Client
+}
+
+func NewWebhookDispatcher(lgr *zap.SugaredLogger, repo WebhookRepository, httpClient *resty.Client) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		logger:     lgr,
+		repo:       repo,
+		httpClient: httpClient,
+	}
+}
+
+func (wd *WebhookDispatcher) Run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(webhookDispatchInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(webhookPruneInterval)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := wd.dispatch(); err != nil {
+				wd.logger.Errorf("Webhook Dispatcher: %s", err.Error())
+			}
+		case <-pruneTicker.C:
+			wd.prune()
+		case <-stopCh:
+			wd.logger.Info("Shutting down Webhook Dispatcher")
+			return
+		}
+	}
+}
+
+func (wd *WebhookDispatcher) dispatch() error {
+	deliveries, err := wd.repo.ClaimWebhookDeliveries(webhookBatchSize, webhookDeliveryLease)
+	if err != nil {
+		return err
+	}
+
+	var deliveriesWg sync.WaitGroup
+	for _, delivery := range deliveries {
+		deliveriesWg.Add(1)
+		go func() {
+			defer deliveriesWg.Done()
+			attempt := wd.deliver(delivery)
+			if err := wd.repo.RecordWebhookAttempt(attempt); err != nil {
+				wd.logger.Errorf("Webhook Dispatcher: failed to record attempt for delivery %d: %s", delivery.ID, err.Error())
+			}
+		}()
+	}
+	deliveriesWg.Wait()
+
+	return nil
+}
+
+func (wd *WebhookDispatcher) prune() {
+	pruned, err := wd.repo.PruneWebhookOutbox(webhookRetention)
+	if err != nil {
+		wd.logger.Errorf("Webhook Dispatcher: failed to prune outbox: %s", err.Error())
+		return
+	}
+	if pruned > 0 {
+		wd.logger.Infof("Webhook Dispatcher: pruned %d finished events older than %s", pruned, webhookRetention)
+	}
+}
+
+func (wd *WebhookDispatcher) deliver(delivery *domain.WebhookDelivery) *domain.WebhookAttempt {
+	attempt := &domain.WebhookAttempt{DeliveryID: delivery.ID}
+
+	body, err := json.Marshal(&webhookEnvelope{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		attempt.Error = "error encoding payload: " + err.Error()
+		return attempt
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	started := time.Now()
+	resp, err := wd.httpClient.R().
+		SetHeader("Content-Type", domain.JSONContentType).
+		SetHeader(WebhookEventHeader, delivery.EventType).
+		SetHeader(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10)).
+		SetHeader(WebhookTimestampHeader, timestamp).
+		SetHeader(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, body)).
+		SetBody(body).
+		Post(delivery.URL)
+	attempt.Duration = time.Since(started)
+
+	switch {
+	case err != nil:
+		attempt.Error = err.Error()
+	case resp.IsSuccess():
+		attempt.ResponseStatus = resp.StatusCode()
+		attempt.Delivered = true
+		return attempt
+	default:
+		attempt.ResponseStatus = resp.StatusCode()
+		attempt.Error = "unexpected response status " + resp.Status()
+	}
+
+	if attemptNumber := delivery.Attempts + 1; attemptNumber < webhookMaxAttempts {
+		next := time.Now().Add(webhookBackoff(attemptNumber))
+		attempt.NextAttemptAt = &next
+	} else {
+		wd.logger.Warnf("Webhook Dispatcher: giving up on delivery %d after %d attempts", delivery.ID, attemptNumber)
+	}
+
+	return attempt
+}
+
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s.%s", timestamp, body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attemptNumber int) time.Duration {
+	backoff := webhookBaseBackoff
+	for range attemptNumber - 1 {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+
+	return backoff
+}
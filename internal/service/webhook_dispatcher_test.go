@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/frolmr/gophermart/internal/mocks"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func testWebhookDelivery(url string, attempts int) *domain.WebhookDelivery {
+	return &domain.WebhookDelivery{
+		ID:        11,
+		Attempts:  attempts,
+		URL:       url,
+		Secret:    "0123456789abcdef",
+		EventID:   5,
+		EventType: domain.WebhookOrderProcessed,
+		Payload:   json.RawMessage(`{"user_id":1,"order":"12345678903","status":"PROCESSED","accrual":10.5}`),
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestWebhookDispatcher_Dispatch_DeliversSignedPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	received := make(chan *http.Request, 1)
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	mockRepo := mocks.NewMockWebhookRepository(ctrl)
+	dispatcher := NewWebhookDispatcher(zap.NewNop().Sugar(), mockRepo, resty.New())
+
+	delivery := testWebhookDelivery(receiver.URL, 0)
+	mockRepo.EXPECT().
+		ClaimWebhookDeliveries(webhookBatchSize, webhookDeliveryLease).
+		Return([]*domain.WebhookDelivery{delivery}, nil)
+	mockRepo.EXPECT().
+		RecordWebhookAttempt(gomock.Any()).
+		DoAndReturn(func(attempt *domain.WebhookAttempt) error {
+			assert.Equal(t, delivery.ID, attempt.DeliveryID)
+			assert.True(t, attempt.Delivered)
+			assert.Equal(t, http.StatusNoContent, attempt.ResponseStatus)
+			assert.Empty(t, attempt.Error)
+			assert.Nil(t, attempt.NextAttemptAt)
+			return nil
+		})
+
+	err := dispatcher.dispatch()
+	require.NoError(t, err)
+
+	req := <-received
+	assert.Equal(t, domain.WebhookOrderProcessed, req.Header.Get(WebhookEventHeader))
+	assert.Equal(t, strconv.FormatInt(delivery.ID, 10), req.Header.Get(WebhookDeliveryHeader))
+	assert.Equal(t,
+		SignWebhookPayload(delivery.Secret, req.Header.Get(WebhookTimestampHeader), receivedBody),
+		req.Header.Get(WebhookSignatureHeader),
+	)
+	assert.JSONEq(t,
+		`{"id":5,"type":"order.processed","created_at":"2024-01-02T03:04:05Z",`+
+			`"data":{"user_id":1,"order":"12345678903","status":"PROCESSED","accrual":10.5}}`,
+		string(receivedBody),
+	)
+}
+
+func TestWebhookDispatcher_Deliver_Failures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	dispatcher := NewWebhookDispatcher(zap.NewNop().Sugar(), nil, resty.New())
+
+	t.Run("Retry scheduled", func(t *testing.T) {
+		before := time.Now()
+		attempt := dispatcher.deliver(testWebhookDelivery(receiver.URL, 2))
+
+		assert.False(t, attempt.Delivered)
+		assert.Equal(t, http.StatusInternalServerError, attempt.ResponseStatus)
+		assert.Contains(t, attempt.Error, "unexpected response status")
+		require.NotNil(t, attempt.NextAttemptAt)
+		assert.WithinDuration(t, before.Add(webhookBackoff(3)), *attempt.NextAttemptAt, 5*time.Second)
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		attempt := dispatcher.deliver(testWebhookDelivery(receiver.URL, webhookMaxAttempts-1))
+
+		assert.False(t, attempt.Delivered)
+		assert.Nil(t, attempt.NextAttemptAt)
+	})
+
+	t.Run("Receiver unreachable", func(t *testing.T) {
+		attempt := dispatcher.deliver(testWebhookDelivery("http://127.0.0.1:1", 0))
+
+		assert.False(t, attempt.Delivered)
+		assert.Zero(t, attempt.ResponseStatus)
+		assert.NotEmpty(t, attempt.Error)
+		assert.NotNil(t, attempt.NextAttemptAt)
+	})
+}
+
+func TestWebhookDispatcher_Dispatch_ClaimError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhookRepository(ctrl)
+	dispatcher := NewWebhookDispatcher(zap.NewNop().Sugar(), mockRepo, resty.New())
+
+	mockRepo.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("database error"))
+
+	err := dispatcher.dispatch()
+
+	assert.Error(t, err)
+}
+
+func TestWebhookDispatcher_Prune(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockWebhookRepository(ctrl)
+	dispatcher := NewWebhookDispatcher(zap.NewNop().Sugar(), mockRepo, resty.New())
+
+	mockRepo.EXPECT().PruneWebhookOutbox(webhookRetention).Return(int64(3), nil)
+	mockRepo.EXPECT().PruneWebhookOutbox(webhookRetention).Return(int64(0), errors.New("database error"))
+
+	dispatcher.prune()
+	dispatcher.prune()
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	signature := SignWebhookPayload("secret", "1700000000", []byte(`{"id":1}`))
+
+	assert.Equal(t, "sha256=3dd1b9aef568d75f6790a84bd2e5dfa1f44409eef3cbdbd3f10b837376100c11", signature)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, webhookBackoff(1))
+	assert.Equal(t, 10*time.Second, webhookBackoff(2))
+	assert.Equal(t, 40*time.Second, webhookBackoff(4))
+	assert.Equal(t, webhookMaxBackoff, webhookBackoff(20))
+}
@@ -1,15 +1,38 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
+	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/pkg/formatter"
 )
 
 func (s *Storage) CreateOrderAccrual(orderID int64, value float64) error {
-	accrualInSubunit := formatter.ConvertToSubunit(value)
-	if _, err := s.db.Exec("INSERT INTO accruals (order_id, accrual) VALUES ($1, $2)", orderID, accrualInSubunit); err != nil {
-		s.logger.Errorf("Accrual insert fail for order_id: %s, value %f; err: %s", orderID, value, err.Error())
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Errorf("Transaction for accrual insert error order_id: %d, err: %s", orderID, err.Error())
+		return fmt.Errorf("error creating accrual: %w", err)
+	}
+
+	var (
+		userID      int64
+		orderNumber string
+	)
+	if err := tx.QueryRow("SELECT user_id, number FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&userID, &orderNumber); err != nil {
+		s.logger.Errorf("Failed to lock order, order_id: %d, err: %s", orderID, err.Error())
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating accrual: %w", err)
+	}
+
+	if err := s.upsertAccrual(tx, orderID, userID, orderNumber, formatter.ConvertToSubunit(value)); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating accrual: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Transaction for accrual insert commit error order_id: %d, err: %s", orderID, err.Error())
 		return fmt.Errorf("error creating accrual: %w", err)
 	}
 
@@ -32,3 +55,39 @@ func (s *Storage) GetUserAccrualsSum(userID int64) (float64, error) {
 
 	return formatter.ConvertToCurrency(totalAccruals), nil
 }
+
+func (s *Storage) upsertAccrual(tx *sql.Tx, orderID, userID int64, orderNumber string, accrual int) error {
+	var previous int64
+	err := tx.QueryRow("SELECT accrual FROM accruals WHERE order_id = $1", orderID).Scan(&previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Errorf("Accrual selection fail for order_id: %d, err: %s", orderID, err.Error())
+		return fmt.Errorf("error upserting accrual: %w", err)
+	}
+
+	query := `
+            INSERT INTO accruals (order_id, accrual) VALUES ($1, $2)
+            ON CONFLICT (order_id) DO UPDATE SET accrual = EXCLUDED.accrual`
+	if _, err := tx.Exec(query, orderID, accrual); err != nil {
+		s.logger.Errorf("Failed to upsert accrual, order_id: %d, value %d; err: %s", orderID, accrual, err.Error())
+		return fmt.Errorf("error upserting accrual: %w", err)
+	}
+
+	delta := int64(accrual) - previous
+	if delta == 0 {
+		return nil
+	}
+
+	if _, err := s.lockUserBalance(tx, userID); err != nil {
+		return fmt.Errorf("error upserting accrual: %w", err)
+	}
+
+	if err := s.insertLedgerEntry(tx, userID, domain.LedgerEntryAccrual, delta, orderNumber); err != nil {
+		return fmt.Errorf("error upserting accrual: %w", err)
+	}
+
+	if err := s.applyBalanceChange(tx, userID, delta, 0); err != nil {
+		return fmt.Errorf("error upserting accrual: %w", err)
+	}
+
+	return nil
+}
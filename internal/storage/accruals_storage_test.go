@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"database/sql"
 	"errors"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/pkg/formatter"
 	"github.com/stretchr/testify/assert"
 )
@@ -13,12 +15,27 @@ func TestCreateOrderAccrual_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	orderID := int64(1)
+	userID := int64(2)
+	orderNumber := "12345678903"
 	value := 50.0
 	accrualInSubunit := formatter.ConvertToSubunit(value)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "number"}).AddRow(userID, orderNumber))
+	mock.ExpectQuery(`SELECT accrual FROM accruals WHERE order_id = \$1`).
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
 	mock.ExpectExec("INSERT INTO accruals").
 		WithArgs(orderID, accrualInSubunit).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceLock(mock, userID, 0)
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(userID, domain.LedgerEntryAccrual, int64(accrualInSubunit), orderNumber).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceChange(mock, userID, int64(accrualInSubunit), 0)
+	mock.ExpectCommit()
 
 	err := storage.CreateOrderAccrual(orderID, value)
 
@@ -33,15 +50,24 @@ func TestCreateOrderAccrual_DatabaseError(t *testing.T) {
 	value := 50.0
 	accrualInSubunit := formatter.ConvertToSubunit(value)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "number"}).AddRow(int64(2), "12345678903"))
+	mock.ExpectQuery(`SELECT accrual FROM accruals WHERE order_id = \$1`).
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
 	mock.ExpectExec("INSERT INTO accruals").
 		WithArgs(orderID, accrualInSubunit).
 		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
 
 	err := storage.CreateOrderAccrual(orderID, value)
 
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
 func TestGetUserAccrualsSum_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
@@ -1,35 +1,137 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
+	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/pkg/formatter"
 )
 
-func (s *Storage) GetUserCurrentBalance(userID int64) (float64, error) {
+func (s *Storage) GetUserBalance(userID int64) (*domain.Balance, error) {
+	var current, withdrawn int64
+	err := s.db.QueryRow("SELECT current, withdrawn FROM user_balances WHERE user_id = $1", userID).Scan(&current, &withdrawn)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &domain.Balance{}, nil
+		}
+		s.logger.Errorf("Balance selection fail for user_id: %d, err: %s", userID, err.Error())
+		return nil, fmt.Errorf("error getting balance: %w", err)
+	}
+
+	return &domain.Balance{
+		BalanceSum:    formatter.ConvertToCurrency(current),
+		WithdrawalSum: formatter.ConvertToCurrency(withdrawn),
+	}, nil
+}
+
+func (s *Storage) FindBalanceDrifts() ([]*domain.BalanceDrift, error) {
+	var drifts []*domain.BalanceDrift
+
+	// user_balances is the balance withdrawals are checked against and the API reads,
+	// so it is compared with both the source tables and the ledger.
 	query := `
-            WITH accruals_cte AS (
-                SELECT COALESCE(SUM(a.accrual), 0) AS total_accruals
+            WITH accruals_agg AS (
+                SELECT o.user_id, SUM(a.accrual) AS total
                 FROM accruals a
-                LEFT JOIN orders o ON a.order_id = o.id
-                WHERE o.user_id = $1
+                JOIN orders o ON a.order_id = o.id
+                GROUP BY o.user_id
             ),
-            withdrawals_cte AS (
-                SELECT COALESCE(SUM(w.sum), 0) AS total_withdrawals
-                FROM withdrawals w
-                WHERE w.user_id = $1
+            withdrawals_agg AS (
+                SELECT user_id, SUM(sum) AS total
+                FROM withdrawals
+                GROUP BY user_id
+            ),
+            ledger_agg AS (
+                SELECT
+                    user_id,
+                    SUM(amount) AS current,
+                    COALESCE(-SUM(amount) FILTER (WHERE entry_type = 'WITHDRAWAL'), 0) AS withdrawn
+                FROM ledger_entries
+                GROUP BY user_id
+            ),
+            expected AS (
+                SELECT
+                    u.id AS user_id,
+                    COALESCE(a.total, 0) - COALESCE(w.total, 0) AS current,
+                    COALESCE(w.total, 0) AS withdrawn,
+                    COALESCE(l.current, 0) AS ledger_current,
+                    COALESCE(l.withdrawn, 0) AS ledger_withdrawn
+                FROM users u
+                LEFT JOIN accruals_agg a ON a.user_id = u.id
+                LEFT JOIN withdrawals_agg w ON w.user_id = u.id
+                LEFT JOIN ledger_agg l ON l.user_id = u.id
             )
             SELECT
-                accruals_cte.total_accruals - withdrawals_cte.total_withdrawals AS net_difference
-            FROM
-                accruals_cte, withdrawals_cte;`
+                e.user_id, COALESCE(b.current, 0), COALESCE(b.withdrawn, 0),
+                e.current, e.withdrawn, e.ledger_current, e.ledger_withdrawn
+            FROM expected e
+            LEFT JOIN user_balances b ON b.user_id = e.user_id
+            WHERE COALESCE(b.current, 0) <> e.current OR COALESCE(b.withdrawn, 0) <> e.withdrawn
+            OR COALESCE(b.current, 0) <> e.ledger_current OR COALESCE(b.withdrawn, 0) <> e.ledger_withdrawn
+            ORDER BY e.user_id`
 
-	var total int64
-	err := s.db.QueryRow(query, userID).Scan(&total)
+	rows, err := s.db.Query(query)
 	if err != nil {
-		s.logger.Errorf("Balance calculation fail for user_id: %s, err: %s", userID, err.Error())
-		return 0, fmt.Errorf("error getting balance: %w", err)
+		s.logger.Errorf("Can't query balance drifts, err: %s", err.Error())
+		return nil, fmt.Errorf("error finding balance drifts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			drift                                                  domain.BalanceDrift
+			current, withdrawn, expectedCurrent, expectedWithdrawn int64
+			ledgerCurrent, ledgerWithdrawn                         int64
+		)
+		err := rows.Scan(&drift.UserID, &current, &withdrawn, &expectedCurrent, &expectedWithdrawn, &ledgerCurrent, &ledgerWithdrawn)
+		if err != nil {
+			s.logger.Errorf("Can't scan balance drift, err: %s", err.Error())
+			return nil, fmt.Errorf("error finding balance drifts: %w", err)
+		}
+		drift.Current = formatter.ConvertToCurrency(current)
+		drift.Withdrawn = formatter.ConvertToCurrency(withdrawn)
+		drift.ExpectedCurrent = formatter.ConvertToCurrency(expectedCurrent)
+		drift.ExpectedWithdrawn = formatter.ConvertToCurrency(expectedWithdrawn)
+		drift.LedgerCurrent = formatter.ConvertToCurrency(ledgerCurrent)
+		drift.LedgerWithdrawn = formatter.ConvertToCurrency(ledgerWithdrawn)
+		drifts = append(drifts, &drift)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for balance drifts, err: %s", err.Error())
+		return nil, fmt.Errorf("error finding balance drifts: %w", err)
+	}
+
+	return drifts, nil
+}
+
+func (s *Storage) lockUserBalance(tx *sql.Tx, userID int64) (int64, error) {
+	// The row has to exist to be locked; concurrent balance changes of the user queue up on it.
+	if _, err := tx.Exec("INSERT INTO user_balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING", userID); err != nil {
+		s.logger.Errorf("Balance row insert fail for user_id: %d, err: %s", userID, err.Error())
+		return 0, fmt.Errorf("error locking user balance: %w", err)
+	}
+
+	var current int64
+	if err := tx.QueryRow("SELECT current FROM user_balances WHERE user_id = $1 FOR UPDATE", userID).Scan(&current); err != nil {
+		s.logger.Errorf("Failed to lock balance of user_id: %d, err: %s", userID, err.Error())
+		return 0, fmt.Errorf("error locking user balance: %w", err)
+	}
+
+	return current, nil
+}
+
+func (s *Storage) applyBalanceChange(tx *sql.Tx, userID, currentDelta, withdrawnDelta int64) error {
+	query := `
+            UPDATE user_balances
+            SET current = current + $2, withdrawn = withdrawn + $3
+            WHERE user_id = $1`
+	if _, err := tx.Exec(query, userID, currentDelta, withdrawnDelta); err != nil {
+		s.logger.Errorf("Balance update fail for user_id: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error applying balance change: %w", err)
 	}
 
-	return formatter.ConvertToCurrency(total), nil
+	return nil
 }
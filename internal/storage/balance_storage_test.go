@@ -1,113 +1,155 @@
 package storage
 
 import (
+	"database/sql"
 	"errors"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/frolmr/gophermart/pkg/formatter"
+	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetUserCurrentBalance_Success(t *testing.T) {
+func TestGetUserBalance_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	userID := int64(1)
-	totalAccruals := int64(10000)
-	totalWithdrawals := int64(3000)
-	netDifference := totalAccruals - totalWithdrawals
-
-	rows := sqlmock.NewRows([]string{"net_difference"}).
-		AddRow(netDifference)
-
-	mock.ExpectQuery(`
-            WITH accruals_cte AS \(
-                SELECT COALESCE\(SUM\(a\.accrual\), 0\) AS total_accruals
-                FROM accruals a
-                LEFT JOIN orders o ON a\.order_id = o\.id
-                WHERE o\.user_id = \$1
-            \),
-            withdrawals_cte AS \(
-                SELECT COALESCE\(SUM\(w\.sum\), 0\) AS total_withdrawals
-                FROM withdrawals w
-                WHERE w\.user_id = \$1
-            \)
-            SELECT
-                accruals_cte\.total_accruals - withdrawals_cte\.total_withdrawals AS net_difference
-            FROM
-                accruals_cte, withdrawals_cte;`).
+
+	mock.ExpectQuery(`SELECT current, withdrawn FROM user_balances WHERE user_id = \$1`).
 		WithArgs(userID).
-		WillReturnRows(rows)
+		WillReturnRows(sqlmock.NewRows([]string{"current", "withdrawn"}).AddRow(10050, 5025))
 
-	result, err := storage.GetUserCurrentBalance(userID)
+	result, err := storage.GetUserBalance(userID)
 
 	assert.NoError(t, err)
-	assert.Equal(t, formatter.ConvertToCurrency(netDifference), result)
+	assert.Equal(t, &domain.Balance{BalanceSum: 100.5, WithdrawalSum: 50.25}, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetUserCurrentBalance_NoData(t *testing.T) {
+func TestGetUserBalance_NoData(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	userID := int64(1)
-	netDifference := int64(0)
-
-	rows := sqlmock.NewRows([]string{"net_difference"}).
-		AddRow(netDifference)
-
-	mock.ExpectQuery(`
-            WITH accruals_cte AS \(
-                SELECT COALESCE\(SUM\(a\.accrual\), 0\) AS total_accruals
-                FROM accruals a
-                LEFT JOIN orders o ON a\.order_id = o\.id
-                WHERE o\.user_id = \$1
-            \),
-            withdrawals_cte AS \(
-                SELECT COALESCE\(SUM\(w\.sum\), 0\) AS total_withdrawals
-                FROM withdrawals w
-                WHERE w\.user_id = \$1
-            \)
-            SELECT
-                accruals_cte\.total_accruals - withdrawals_cte\.total_withdrawals AS net_difference
-            FROM
-                accruals_cte, withdrawals_cte;`).
+
+	mock.ExpectQuery(`SELECT current, withdrawn FROM user_balances WHERE user_id = \$1`).
 		WithArgs(userID).
-		WillReturnRows(rows)
+		WillReturnError(sql.ErrNoRows)
 
-	result, err := storage.GetUserCurrentBalance(userID)
+	result, err := storage.GetUserBalance(userID)
 
 	assert.NoError(t, err)
-	assert.Equal(t, formatter.ConvertToCurrency(netDifference), result)
+	assert.Equal(t, &domain.Balance{}, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetUserCurrentBalance_DatabaseError(t *testing.T) {
+func TestGetUserBalance_DatabaseError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	userID := int64(1)
 
-	mock.ExpectQuery(`
-            WITH accruals_cte AS \(
-                SELECT COALESCE\(SUM\(a\.accrual\), 0\) AS total_accruals
-                FROM accruals a
-                LEFT JOIN orders o ON a\.order_id = o\.id
-                WHERE o\.user_id = \$1
-            \),
-            withdrawals_cte AS \(
-                SELECT COALESCE\(SUM\(w\.sum\), 0\) AS total_withdrawals
-                FROM withdrawals w
-                WHERE w\.user_id = \$1
-            \)
-            SELECT
-                accruals_cte\.total_accruals - withdrawals_cte\.total_withdrawals AS net_difference
-            FROM
-                accruals_cte, withdrawals_cte;`).
+	mock.ExpectQuery(`SELECT current, withdrawn FROM user_balances WHERE user_id = \$1`).
 		WithArgs(userID).
 		WillReturnError(errors.New("database error"))
 
-	result, err := storage.GetUserCurrentBalance(userID)
+	result, err := storage.GetUserBalance(userID)
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindBalanceDrifts_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	rows := sqlmock.NewRows([]string{
+		"user_id", "current", "withdrawn", "expected_current", "expected_withdrawn", "ledger_current", "ledger_withdrawn",
+	}).
+		AddRow(int64(1), 10000, 0, 9000, 1000, 10000, 0)
+
+	mock.ExpectQuery("WITH accruals_agg AS").
+		WillReturnRows(rows)
+
+	result, err := storage.FindBalanceDrifts()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.BalanceDrift{
+		{UserID: 1, Current: 100, Withdrawn: 0, ExpectedCurrent: 90, ExpectedWithdrawn: 10, LedgerCurrent: 100, LedgerWithdrawn: 0},
+	}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindBalanceDrifts_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("WITH accruals_agg AS").
+		WillReturnError(errors.New("database error"))
+
+	result, err := storage.FindBalanceDrifts()
 
 	assert.Error(t, err)
-	assert.Equal(t, 0.0, result)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockUserBalance_CreatesMissingRow(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	userID := int64(1)
+
+	mock.ExpectBegin()
+	ExpectBalanceLock(mock, userID, 0)
+
+	tx, err := storage.db.Begin()
+	assert.NoError(t, err)
+
+	current, err := storage.lockUserBalance(tx, userID)
+
+	assert.NoError(t, err)
+	assert.Zero(t, current)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestFindBalanceDrifts_ReportsLedgerDrift(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	userID := SeedUserWithAccrual(t, storage, 100.0)
+	_, err := storage.db.Exec(
+		"INSERT INTO ledger_entries (user_id, entry_type, amount, order_number) VALUES ($1, 'ACCRUAL', 1, 'skew')",
+		userID,
+	)
+	if err != nil {
+		t.Fatalf("Failed to skew ledger: %v", err)
+	}
+
+	drifts, err := storage.FindBalanceDrifts()
+	assert.NoError(t, err)
+
+	var found *domain.BalanceDrift
+	for _, drift := range drifts {
+		if drift.UserID == userID {
+			found = drift
+		}
+	}
+	if assert.NotNil(t, found) {
+		assert.Equal(t, 100.0, found.Current)
+		assert.Equal(t, 100.0, found.ExpectedCurrent)
+		assert.Equal(t, 100.01, found.LedgerCurrent)
+	}
+}
+
+func TestUserBalance_MatchesAggregates(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	userID := SeedUserWithAccrual(t, storage, 100.0)
+	assert.NoError(t, storage.CreateWithdrawal("12345678903", 30.0, userID))
+
+	balance, err := storage.GetUserBalance(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Balance{BalanceSum: 70, WithdrawalSum: 30}, balance)
+
+	drifts, err := storage.FindBalanceDrifts()
+	assert.NoError(t, err)
+	for _, drift := range drifts {
+		assert.NotEqual(t, userID, drift.UserID)
+	}
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+func (s *Storage) NotifyEvent(event *domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+
+	if _, err := s.db.Exec("SELECT pg_notify($1, $2)", domain.EventsChannel, string(payload)); err != nil {
+		s.logger.Errorf("Failed to notify %s event for user_id: %d, err: %s", event.Type, event.UserID, err.Error())
+		return fmt.Errorf("error notifying event: %w", err)
+	}
+
+	return nil
+}
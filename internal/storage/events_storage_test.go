@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyEvent_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	event := &domain.Event{Type: domain.EventBalance, UserID: 1, Data: json.RawMessage(`{"current":10,"withdrawn":0}`)}
+
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+		WithArgs(domain.EventsChannel, `{"type":"balance","user_id":1,"data":{"current":10,"withdrawn":0}}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := storage.NotifyEvent(event)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotifyEvent_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec("SELECT pg_notify").
+		WillReturnError(errors.New("database error"))
+
+	err := storage.NotifyEvent(&domain.Event{Type: domain.EventBalance, UserID: 1, Data: json.RawMessage(`{}`)})
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
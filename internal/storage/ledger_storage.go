@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func (s *Storage) insertLedgerEntry(tx *sql.Tx, userID int64, entryType string, amount int64, orderNumber string) error {
+	query := `
+            INSERT INTO ledger_entries (user_id, entry_type, amount, order_number)
+            VALUES ($1, $2, $3, $4)`
+
+	if _, err := tx.Exec(query, userID, entryType, amount, orderNumber); err != nil {
+		s.logger.Errorf("Ledger entry insert fail for user_id: %d, order# %s, err: %s", userID, orderNumber, err.Error())
+		return fmt.Errorf("error inserting ledger entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedger_RecordsEveryMovement(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	userID := SeedUserWithAccrual(t, storage, 100.0)
+
+	assert.NoError(t, storage.CreateWithdrawal("12345678903", 30.0, userID))
+	assert.NoError(t, storage.CreateWithdrawal("98765432109", 20.5, userID))
+
+	rows, err := storage.db.Query("SELECT entry_type, amount FROM ledger_entries WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+
+	type entry struct {
+		entryType string
+		amount    int64
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.entryType, &e.amount); err != nil {
+			t.Fatalf("Failed to scan ledger entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	assert.NoError(t, rows.Err())
+
+	assert.Equal(t, []entry{
+		{entryType: "ACCRUAL", amount: 10000},
+		{entryType: "WITHDRAWAL", amount: -3000},
+		{entryType: "WITHDRAWAL", amount: -2050},
+	}, entries)
+
+	balance, err := storage.GetUserBalance(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Balance{BalanceSum: 49.5, WithdrawalSum: 50.5}, balance)
+}
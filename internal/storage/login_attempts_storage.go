@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+type loginAttemptRow struct {
+	key          domain.LoginAttemptKey
+	failedCount  int
+	lastFailedAt sql.NullTime
+	lockedUntil  sql.NullTime
+}
+
+// Attempts are counted before the password is checked so parallel guesses can't all slip past the lockout.
+// Callers pass the login key first, which keeps row locks in the same order across requests.
+func (s *Storage) RegisterLoginAttempt(keys ...domain.LoginAttemptKey) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Errorf("Failed to begin transaction for login attempt: %s", err.Error())
+		return time.Time{}, fmt.Errorf("error registering login attempt: %w", err)
+	}
+
+	now := time.Now()
+	rows := make([]loginAttemptRow, 0, len(keys))
+	var lockedUntil time.Time
+	for _, key := range keys {
+		row, err := lockLoginAttemptRow(tx, key)
+		if err != nil {
+			_ = tx.Rollback()
+			s.logger.Errorf("Failed to lock login attempts row for %s %s: %s", key.Scope, key.Value, err.Error())
+			return time.Time{}, fmt.Errorf("error registering login attempt: %w", err)
+		}
+		if row.lockedUntil.Valid && row.lockedUntil.Time.After(now) && row.lockedUntil.Time.After(lockedUntil) {
+			lockedUntil = row.lockedUntil.Time
+		}
+		rows = append(rows, row)
+	}
+
+	if !lockedUntil.IsZero() {
+		_ = tx.Rollback()
+		return lockedUntil, nil
+	}
+
+	for _, row := range rows {
+		policy := domain.LockoutPolicyFor(row.key.Scope)
+		failedCount := row.failedCount
+		if row.lastFailedAt.Valid && now.Sub(row.lastFailedAt.Time) > policy.Window {
+			failedCount = 0
+		}
+		failedCount++
+
+		var rowLockedUntil sql.NullTime
+		if lockout := policy.LockoutFor(failedCount); lockout > 0 {
+			rowLockedUntil = sql.NullTime{Time: now.Add(lockout), Valid: true}
+		}
+
+		if _, err := tx.Exec(
+			"UPDATE login_attempts SET failed_count = $1, last_failed_at = $2, locked_until = $3 WHERE scope = $4 AND key = $5",
+			failedCount, now, rowLockedUntil, row.key.Scope, row.key.Value,
+		); err != nil {
+			_ = tx.Rollback()
+			s.logger.Errorf("Failed to update login attempts for %s %s: %s", row.key.Scope, row.key.Value, err.Error())
+			return time.Time{}, fmt.Errorf("error registering login attempt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Failed to commit login attempt: %s", err.Error())
+		return time.Time{}, fmt.Errorf("error registering login attempt: %w", err)
+	}
+
+	return time.Time{}, nil
+}
+
+func lockLoginAttemptRow(tx *sql.Tx, key domain.LoginAttemptKey) (loginAttemptRow, error) {
+	if _, err := tx.Exec(
+		"INSERT INTO login_attempts (scope, key) VALUES ($1, $2) ON CONFLICT (scope, key) DO NOTHING", key.Scope, key.Value,
+	); err != nil {
+		return loginAttemptRow{}, err
+	}
+
+	row := loginAttemptRow{key: key}
+	err := tx.QueryRow(
+		"SELECT failed_count, last_failed_at, locked_until FROM login_attempts WHERE scope = $1 AND key = $2 FOR UPDATE",
+		key.Scope, key.Value,
+	).Scan(&row.failedCount, &row.lastFailedAt, &row.lockedUntil)
+
+	return row, err
+}
+
+// RevertLoginAttempt takes back an attempt registered for a login that turned out to be successful.
+func (s *Storage) RevertLoginAttempt(key domain.LoginAttemptKey) error {
+	policy := domain.LockoutPolicyFor(key.Scope)
+	if _, err := s.db.Exec(
+		`UPDATE login_attempts
+		SET failed_count = GREATEST(failed_count - 1, 0),
+			locked_until = CASE WHEN failed_count - 1 < $3 THEN NULL ELSE locked_until END
+		WHERE scope = $1 AND key = $2`,
+		key.Scope, key.Value, policy.Threshold,
+	); err != nil {
+		s.logger.Errorf("Failed to revert login attempt for %s %s: %s", key.Scope, key.Value, err.Error())
+		return fmt.Errorf("error reverting login attempt: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) ClearLoginAttempts(key domain.LoginAttemptKey) error {
+	if _, err := s.db.Exec("DELETE FROM login_attempts WHERE scope = $1 AND key = $2", key.Scope, key.Value); err != nil {
+		s.logger.Errorf("Failed to clear login attempts for %s %s: %s", key.Scope, key.Value, err.Error())
+		return fmt.Errorf("error clearing login attempts: %w", err)
+	}
+	return nil
+}
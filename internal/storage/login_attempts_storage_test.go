@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testLoginKey = domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeLogin, Value: "testuser"}
+	testIPKey    = domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeIP, Value: "192.0.2.1"}
+)
+
+func expectLoginAttemptRow(mock sqlmock.Sqlmock, key domain.LoginAttemptKey, failedCount int, lastFailedAt, lockedUntil interface{}) {
+	mock.ExpectExec("INSERT INTO login_attempts").
+		WithArgs(key.Scope, key.Value).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(
+		`SELECT failed_count, last_failed_at, locked_until FROM login_attempts WHERE scope = \$1 AND key = \$2 FOR UPDATE`,
+	).
+		WithArgs(key.Scope, key.Value).
+		WillReturnRows(sqlmock.NewRows([]string{"failed_count", "last_failed_at", "locked_until"}).
+			AddRow(failedCount, lastFailedAt, lockedUntil))
+}
+
+func TestRegisterLoginAttempt(t *testing.T) {
+	tests := []struct {
+		name            string
+		failedCount     int
+		lastFailedAt    interface{}
+		expectedCount   int
+		expectedLockout bool
+	}{
+		{name: "First attempt", failedCount: 0, lastFailedAt: nil, expectedCount: 1},
+		{name: "Below threshold", failedCount: 1, lastFailedAt: time.Now().Add(-time.Minute), expectedCount: 2},
+		{
+			name:            "Reaching threshold locks",
+			failedCount:     domain.LoginLockoutPolicy.Threshold - 1,
+			lastFailedAt:    time.Now().Add(-time.Minute),
+			expectedCount:   domain.LoginLockoutPolicy.Threshold,
+			expectedLockout: true,
+		},
+		{name: "Counter resets after window", failedCount: 10, lastFailedAt: time.Now().Add(-time.Hour), expectedCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := NewMockStorage(t)
+
+			mock.ExpectBegin()
+			expectLoginAttemptRow(mock, testLoginKey, tt.failedCount, tt.lastFailedAt, nil)
+			expectLoginAttemptRow(mock, testIPKey, 0, nil, nil)
+			lockedUntil := sqlmock.Argument(nullTimeArg{valid: false})
+			if tt.expectedLockout {
+				lockedUntil = nullTimeArg{valid: true}
+			}
+			mock.ExpectExec(`UPDATE login_attempts SET failed_count = \$1, last_failed_at = \$2, locked_until = \$3`).
+				WithArgs(tt.expectedCount, sqlmock.AnyArg(), lockedUntil, testLoginKey.Scope, testLoginKey.Value).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`UPDATE login_attempts SET failed_count = \$1, last_failed_at = \$2, locked_until = \$3`).
+				WithArgs(1, sqlmock.AnyArg(), nullTimeArg{valid: false}, testIPKey.Scope, testIPKey.Value).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			locked, err := storage.RegisterLoginAttempt(testLoginKey, testIPKey)
+
+			assert.NoError(t, err)
+			assert.True(t, locked.IsZero())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRegisterLoginAttempt_LockedKeyIsNotCounted(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	ipLockedUntil := time.Now().Add(time.Minute)
+
+	mock.ExpectBegin()
+	expectLoginAttemptRow(mock, testLoginKey, 1, time.Now().Add(-time.Minute), nil)
+	expectLoginAttemptRow(mock, testIPKey, domain.IPLockoutPolicy.Threshold, time.Now().Add(-time.Minute), ipLockedUntil)
+	mock.ExpectRollback()
+
+	lockedUntil, err := storage.RegisterLoginAttempt(testLoginKey, testIPKey)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ipLockedUntil, lockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterLoginAttempt_ExpiredLockIsCounted(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	expectLoginAttemptRow(mock, testLoginKey, 1, time.Now().Add(-time.Minute), time.Now().Add(-time.Second))
+	mock.ExpectExec(`UPDATE login_attempts SET failed_count = \$1, last_failed_at = \$2, locked_until = \$3`).
+		WithArgs(2, sqlmock.AnyArg(), nullTimeArg{valid: false}, testLoginKey.Scope, testLoginKey.Value).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	lockedUntil, err := storage.RegisterLoginAttempt(testLoginKey)
+
+	assert.NoError(t, err)
+	assert.True(t, lockedUntil.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterLoginAttempt_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO login_attempts").
+		WithArgs(testLoginKey.Scope, testLoginKey.Value).
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	_, err := storage.RegisterLoginAttempt(testLoginKey)
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterLoginAttempt_ConcurrentAttemptsStopAtThreshold(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	loginKey := domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeLogin, Value: fmt.Sprintf("user-%d", time.Now().UnixNano())}
+	t.Cleanup(func() { _ = storage.ClearLoginAttempts(loginKey) })
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counted := 0
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ipKey := domain.LoginAttemptKey{Scope: domain.LoginAttemptScopeIP, Value: fmt.Sprintf("198.51.100.%d", i)}
+			defer func() { _ = storage.ClearLoginAttempts(ipKey) }()
+
+			lockedUntil, err := storage.RegisterLoginAttempt(loginKey, ipKey)
+			assert.NoError(t, err)
+			if lockedUntil.IsZero() {
+				mu.Lock()
+				counted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, domain.LoginLockoutPolicy.Threshold, counted)
+}
+
+func TestRevertLoginAttempt(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec(`UPDATE login_attempts SET failed_count = GREATEST\(failed_count - 1, 0\)`).
+		WithArgs(testIPKey.Scope, testIPKey.Value, domain.IPLockoutPolicy.Threshold).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, storage.RevertLoginAttempt(testIPKey))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClearLoginAttempts(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec(`DELETE FROM login_attempts WHERE scope = \$1 AND key = \$2`).
+		WithArgs(testLoginKey.Scope, testLoginKey.Value).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, storage.ClearLoginAttempts(testLoginKey))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type nullTimeArg struct {
+	valid bool
+}
+
+func (a nullTimeArg) Match(v driver.Value) bool {
+	if !a.valid {
+		return v == nil
+	}
+	_, ok := v.(time.Time)
+	return ok
+}
@@ -4,40 +4,165 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/pkg/formatter"
 )
 
-func (s *Storage) FindOrderByNumber(number string) (*domain.DBOrder, error) {
-	stmt, err := s.db.Prepare("SELECT id, number, status, uploaded_at, user_id FROM orders WHERE number = $1")
+var orderWebhookEvents = map[string]string{
+	domain.OrderStatusProcessed: domain.WebhookOrderProcessed,
+	domain.OrderStatusInvalid:   domain.WebhookOrderInvalid,
+}
+
+func (s *Storage) CreateOrder(number string, userID int64) (int64, bool, error) {
+	var ownerID int64
+	err := s.db.QueryRow(
+		"INSERT INTO orders (number, user_id) VALUES ($1, $2) ON CONFLICT (number) DO NOTHING RETURNING user_id", number, userID,
+	).Scan(&ownerID)
+	if err == nil {
+		return ownerID, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Errorf("Order insert fail for order# %s, user_id: %d; err: %s", number, userID, err.Error())
+		return 0, false, fmt.Errorf("error creating order: %w", err)
+	}
+
+	// the conflicting row is committed by now, so a fresh statement always sees it
+	if err := s.db.QueryRow("SELECT user_id FROM orders WHERE number = $1", number).Scan(&ownerID); err != nil {
+		s.logger.Errorf("Order owner query fails for order# %s, err: %s", number, err.Error())
+		return 0, false, fmt.Errorf("error creating order: %w", err)
+	}
+
+	return ownerID, false, nil
+}
+
+func (s *Storage) CreateOrders(numbers []string, userID int64) ([]*domain.OrderUploadResult, error) {
+	if len(numbers) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		s.logger.Errorf("Can't prepare statement for order# %s, err: %s", number, err.Error())
-		return nil, fmt.Errorf("error getting order: %w", err)
+		s.logger.Errorf("Transaction for batch order insert error user_id: %d, err: %s", userID, err.Error())
+		return nil, fmt.Errorf("error creating orders: %w", err)
 	}
-	defer stmt.Close()
 
-	var order domain.DBOrder
-	err = stmt.QueryRow(number).Scan(&order.ID, &order.Number, &order.Status, &order.UploadedAt, &order.UserID)
+	accepted, err := s.insertNewOrders(tx, numbers, userID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		} else {
-			s.logger.Errorf("Order query fails for order# %s, err: %s", number, err.Error())
-			return nil, fmt.Errorf("error getting order: %w", err)
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("error creating orders: %w", err)
+	}
+
+	rejected := make([]string, 0, len(numbers)-len(accepted))
+	for _, number := range numbers {
+		if !accepted[number] {
+			rejected = append(rejected, number)
 		}
 	}
 
-	return &order, nil
+	owners, err := s.orderOwners(tx, rejected)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("error creating orders: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Transaction for batch order insert commit error user_id: %d, err: %s", userID, err.Error())
+		return nil, fmt.Errorf("error creating orders: %w", err)
+	}
+
+	results := make([]*domain.OrderUploadResult, 0, len(numbers))
+	for _, number := range numbers {
+		result := domain.OrderUploadConflict
+		if accepted[number] {
+			result = domain.OrderUploadAccepted
+		} else if owner, ok := owners[number]; ok && owner == userID {
+			result = domain.OrderUploadAlreadyUploaded
+		}
+		results = append(results, &domain.OrderUploadResult{Number: number, Result: result})
+	}
+
+	return results, nil
 }
 
-func (s *Storage) CreateOrder(number string, userID int64) error {
-	if _, err := s.db.Exec("INSERT INTO orders (number, user_id) VALUES ($1, $2)", number, userID); err != nil {
-		s.logger.Errorf("Order insert fail for order# %s, user_id: %d; err: %s", number, userID, err.Error())
-		return fmt.Errorf("error creating order: %w", err)
+func (s *Storage) insertNewOrders(tx *sql.Tx, numbers []string, userID int64) (map[string]bool, error) {
+	// sorted so concurrent batches take unique index locks in the same order
+	sorted := slices.Clone(numbers)
+	slices.Sort(sorted)
+
+	wb := &whereBuilder{}
+	owner := wb.arg(userID)
+	values := make([]string, 0, len(sorted))
+	for _, number := range sorted {
+		values = append(values, "("+wb.arg(number)+", "+owner+")")
 	}
 
-	return nil
+	//nolint:gosec // values are built from placeholders only, numbers are passed as args
+	query := "INSERT INTO orders (number, user_id) VALUES " + strings.Join(values, ", ") +
+		" ON CONFLICT (number) DO NOTHING RETURNING number"
+
+	rows, err := tx.Query(query, wb.args...)
+	if err != nil {
+		s.logger.Errorf("Batch order insert fail for user_id: %d, err: %s", userID, err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	accepted := make(map[string]bool, len(numbers))
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			s.logger.Errorf("Can't scan inserted order for user_id: %d, err: %s", userID, err.Error())
+			return nil, err
+		}
+		accepted[number] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for batch order insert user_id: %d, err: %s", userID, err.Error())
+		return nil, err
+	}
+
+	return accepted, nil
+}
+
+func (s *Storage) orderOwners(tx *sql.Tx, numbers []string) (map[string]int64, error) {
+	owners := make(map[string]int64, len(numbers))
+	if len(numbers) == 0 {
+		return owners, nil
+	}
+
+	wb := &whereBuilder{}
+	//nolint:gosec // conditions are built from placeholders only, values are passed as args
+	query := "SELECT number, user_id FROM orders WHERE number IN (" + wb.argList(numbers) + ")"
+
+	rows, err := tx.Query(query, wb.args...)
+	if err != nil {
+		s.logger.Errorf("Can't query owners of existing orders, err: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			number string
+			userID int64
+		)
+		if err := rows.Scan(&number, &userID); err != nil {
+			s.logger.Errorf("Can't scan owner of existing order, err: %s", err.Error())
+			return nil, err
+		}
+		owners[number] = userID
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for owners of existing orders, err: %s", err.Error())
+		return nil, err
+	}
+
+	return owners, nil
 }
 
 func (s *Storage) GetAllUnprocessedOrders() ([]*domain.DBOrder, error) {
@@ -74,51 +199,146 @@ func (s *Storage) GetAllUnprocessedOrders() ([]*domain.DBOrder, error) {
 	return orders, nil
 }
 
-func (s *Storage) GetAllUserOrders(userID int64) ([]*domain.Order, error) {
-	var orders []*domain.Order
+func (s *Storage) GetUserOrders(filter domain.OrderFilter) (*domain.OrderPage, error) {
+	wb := &whereBuilder{}
+	wb.where("o.user_id = " + wb.arg(filter.UserID))
+	if filter.After != nil {
+		wb.where("(o.uploaded_at, o.id) < (" + wb.arg(filter.After.Time) + ", " + wb.arg(filter.After.ID) + ")")
+	}
+	if len(filter.Statuses) > 0 {
+		wb.where("o.status IN (" + wb.argList(filter.Statuses) + ")")
+	}
+	if filter.Uploaded.From != nil {
+		wb.where("o.uploaded_at >= " + wb.arg(*filter.Uploaded.From))
+	}
+	if filter.Uploaded.To != nil {
+		wb.where("o.uploaded_at < " + wb.arg(*filter.Uploaded.To))
+	}
 
+	//nolint:gosec // conditions are built from placeholders only, values are passed as args
 	query := `
-            SELECT o.number, o.status, a.accrual, o.uploaded_at
+            SELECT o.id, o.number, o.status, a.accrual, o.uploaded_at
             FROM orders o
 	        LEFT JOIN accruals a ON o.id = a.order_id
-	        WHERE o.user_id = $1
-            ORDER BY o.uploaded_at DESC`
-
-	stmt, err := s.db.Prepare(query)
-	if err != nil {
-		s.logger.Errorf("Can't prepare query for user_id: %d orders, err: %s", userID, err.Error())
-		return nil, fmt.Errorf("error getting all users orders: %w", err)
+	        WHERE ` + wb.String() + `
+            ORDER BY o.uploaded_at DESC, o.id DESC`
+	if filter.Limit > domain.NoPageLimit {
+		query += `
+            LIMIT ` + wb.arg(filter.Limit+1)
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query(userID)
+	rows, err := s.db.Query(query, wb.args...)
 	if err != nil {
-		s.logger.Errorf("Can't query orders for user_id: %d, err: %s", userID, err.Error())
-		return nil, fmt.Errorf("error getting all users orders: %w", err)
+		s.logger.Errorf("Can't query orders for user_id: %d, err: %s", filter.UserID, err.Error())
+		return nil, fmt.Errorf("error getting user orders: %w", err)
 	}
 	defer rows.Close()
 
+	page := &domain.OrderPage{}
+	var lastID int64
 	for rows.Next() {
+		if filter.Limit > domain.NoPageLimit && len(page.Orders) == filter.Limit {
+			last := page.Orders[len(page.Orders)-1]
+			page.NextCursor = &domain.Cursor{Time: last.UploadedAt, ID: lastID}
+			break
+		}
+
 		var order domain.Order
 		var accrual sql.NullInt64
-		err := rows.Scan(&order.Number, &order.Status, &accrual, &order.UploadedAt)
-		if err != nil {
-			s.logger.Errorf("Can't scan order to struct for user_id: %d, err: %s", userID, err.Error())
-			return nil, fmt.Errorf("error getting all users orders: %w", err)
+		if err := rows.Scan(&lastID, &order.Number, &order.Status, &accrual, &order.UploadedAt); err != nil {
+			s.logger.Errorf("Can't scan order to struct for user_id: %d, err: %s", filter.UserID, err.Error())
+			return nil, fmt.Errorf("error getting user orders: %w", err)
 		}
 		if accrual.Valid {
 			accrualValue := formatter.ConvertToCurrency(accrual.Int64)
 			order.Accrual = &accrualValue
 		}
-		orders = append(orders, &order)
+		page.Orders = append(page.Orders, &order)
 	}
 
 	if err := rows.Err(); err != nil {
-		s.logger.Errorf("Got rows.Err() for user_id: %d, err: %s", userID, err.Error())
-		return nil, fmt.Errorf("error getting all users orders: %w", err)
+		s.logger.Errorf("Got rows.Err() for user_id: %d, err: %s", filter.UserID, err.Error())
+		return nil, fmt.Errorf("error getting user orders: %w", err)
 	}
 
-	return orders, nil
+	return page, nil
+}
+
+func (s *Storage) GetUserOrderDetails(userID int64, number string) (*domain.OrderDetails, error) {
+	query := `
+            SELECT o.id, o.number, o.status, a.accrual, o.uploaded_at, o.poll_count, o.last_polled_at
+            FROM orders o
+	        LEFT JOIN accruals a ON o.id = a.order_id
+	        WHERE o.number = $1 AND o.user_id = $2`
+
+	var (
+		orderID      int64
+		details      domain.OrderDetails
+		accrual      sql.NullInt64
+		lastPolledAt sql.NullTime
+	)
+	err := s.db.QueryRow(query, number, userID).Scan(
+		&orderID, &details.Number, &details.Status, &accrual, &details.UploadedAt, &details.PollCount, &lastPolledAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		s.logger.Errorf("Order details query fails for order# %s, user_id: %d, err: %s", number, userID, err.Error())
+		return nil, fmt.Errorf("error getting order details: %w", err)
+	}
+	if accrual.Valid {
+		accrualValue := formatter.ConvertToCurrency(accrual.Int64)
+		details.Accrual = &accrualValue
+	}
+	if lastPolledAt.Valid {
+		details.LastPolledAt = &lastPolledAt.Time
+	}
+
+	details.History = []*domain.OrderStatusChange{{Status: domain.OrderStatusNew, ChangedAt: &details.UploadedAt}}
+
+	// Backfilled rows have no changed_at: they predate the history table and come before any tracked change.
+	rows, err := s.db.Query(
+		"SELECT status, changed_at FROM order_status_history WHERE order_id = $1 ORDER BY changed_at NULLS FIRST, id", orderID,
+	)
+	if err != nil {
+		s.logger.Errorf("Can't query status history for order# %s, err: %s", number, err.Error())
+		return nil, fmt.Errorf("error getting order details: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			change    domain.OrderStatusChange
+			changedAt sql.NullTime
+		)
+		if err := rows.Scan(&change.Status, &changedAt); err != nil {
+			s.logger.Errorf("Can't scan status history for order# %s, err: %s", number, err.Error())
+			return nil, fmt.Errorf("error getting order details: %w", err)
+		}
+		if changedAt.Valid {
+			change.ChangedAt = &changedAt.Time
+		} else {
+			change.Backfilled = true
+		}
+		details.History = append(details.History, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for status history of order# %s, err: %s", number, err.Error())
+		return nil, fmt.Errorf("error getting order details: %w", err)
+	}
+
+	return &details, nil
+}
+
+func (s *Storage) RecordOrderPoll(id int64) error {
+	if _, err := s.db.Exec("UPDATE orders SET poll_count = poll_count + 1, last_polled_at = NOW() WHERE id = $1", id); err != nil {
+		s.logger.Errorf("Failed to record accrual poll, order_id: %d, err: %s", id, err.Error())
+		return fmt.Errorf("error recording order poll: %w", err)
+	}
+
+	return nil
 }
 
 func (s *Storage) UpdateOrderAccrualStatus(id int64, status string, accrual *float64) error {
@@ -128,16 +348,48 @@ func (s *Storage) UpdateOrderAccrualStatus(id int64, status string, accrual *flo
 		return fmt.Errorf("error updating orders status: %w", err)
 	}
 
-	if _, err := s.db.Exec("UPDATE orders SET status = $2 WHERE id = $1", id, status); err != nil {
+	var (
+		currentStatus string
+		userID        int64
+		orderNumber   string
+	)
+	row := tx.QueryRow("SELECT status, user_id, number FROM orders WHERE id = $1 FOR UPDATE", id)
+	if err := row.Scan(&currentStatus, &userID, &orderNumber); err != nil {
+		s.logger.Errorf("Failed to lock order, order_id: %d, err: %s", id, err.Error())
+		_ = tx.Rollback()
+		return fmt.Errorf("error updating orders status: %w", err)
+	}
+
+	if !domain.IsOrderStatusTransitionAllowed(currentStatus, status) {
+		s.logger.Warnf("Rejected order status change, order_id: %d, from: %s, to: %s", id, currentStatus, status)
+		_ = tx.Rollback()
+		return fmt.Errorf("error updating orders status from %s to %s: %w", currentStatus, status, domain.ErrOrderStatusRegression)
+	}
+
+	if _, err := tx.Exec("UPDATE orders SET status = $2 WHERE id = $1", id, status); err != nil {
 		s.logger.Errorf("Failed to update order status, order_id: %d, status: %s; err: %s", id, status, err.Error())
 		_ = tx.Rollback()
 		return fmt.Errorf("error updating orders status: %w", err)
 	}
 
+	if currentStatus != status {
+		if _, err := tx.Exec("INSERT INTO order_status_history (order_id, status) VALUES ($1, $2)", id, status); err != nil {
+			s.logger.Errorf("Failed to record order status change, order_id: %d, status: %s; err: %s", id, status, err.Error())
+			_ = tx.Rollback()
+			return fmt.Errorf("error updating orders status: %w", err)
+		}
+
+		if eventType, ok := orderWebhookEvents[status]; ok {
+			event := &domain.WebhookOrderEvent{UserID: userID, Order: orderNumber, Status: status, Accrual: accrual}
+			if err := s.enqueueWebhookEvent(tx, eventType, event); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("error updating orders status: %w", err)
+			}
+		}
+	}
+
 	if accrual != nil {
-		accrualValue := formatter.ConvertToSubunit(*accrual)
-		if _, err := s.db.Exec("INSERT INTO accruals (order_id, accrual) VALUES ($1, $2)", id, accrualValue); err != nil {
-			s.logger.Errorf("Failed to insert new accrual, order_id: %d, err: %s", id, err.Error())
+		if err := s.upsertAccrual(tx, id, userID, orderNumber, formatter.ConvertToSubunit(*accrual)); err != nil {
 			_ = tx.Rollback()
 			return fmt.Errorf("error inserting accrual: %w", err)
 		}
@@ -3,6 +3,8 @@ package storage
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,101 +12,237 @@ import (
 	"github.com/frolmr/gophermart/internal/domain"
 	"github.com/frolmr/gophermart/pkg/formatter"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestFindOrderByNumber_Success(t *testing.T) {
+func TestCreateOrder_Created(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	orderNumber := "12345678903"
-	order := &domain.DBOrder{
-		ID:         1,
-		Number:     orderNumber,
-		Status:     "NEW",
-		UploadedAt: time.Now(),
-		UserID:     1,
-	}
-
-	rows := sqlmock.NewRows([]string{"id", "number", "status", "uploaded_at", "user_id"}).
-		AddRow(order.ID, order.Number, order.Status, order.UploadedAt, order.UserID)
+	userID := int64(1)
 
-	mock.ExpectPrepare("SELECT id, number, status, uploaded_at, user_id FROM orders").
-		ExpectQuery().
-		WithArgs(orderNumber).
-		WillReturnRows(rows)
+	mock.ExpectQuery(`INSERT INTO orders \(number, user_id\) VALUES \(\$1, \$2\) ON CONFLICT \(number\) DO NOTHING RETURNING user_id`).
+		WithArgs(orderNumber, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
 
-	result, err := storage.FindOrderByNumber(orderNumber)
+	ownerID, created, err := storage.CreateOrder(orderNumber, userID)
 
 	assert.NoError(t, err)
-	assert.Equal(t, order, result)
+	assert.True(t, created)
+	assert.Equal(t, userID, ownerID)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestFindOrderByNumber_NotFound(t *testing.T) {
+func TestCreateOrder_AlreadyExists(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	orderNumber := "12345678903"
 
-	mock.ExpectPrepare("SELECT id, number, status, uploaded_at, user_id FROM orders").
-		ExpectQuery().
+	mock.ExpectQuery("INSERT INTO orders").
+		WithArgs(orderNumber, int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery(`SELECT user_id FROM orders WHERE number = \$1`).
 		WithArgs(orderNumber).
-		WillReturnError(sql.ErrNoRows)
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(2)))
 
-	result, err := storage.FindOrderByNumber(orderNumber)
+	ownerID, created, err := storage.CreateOrder(orderNumber, 1)
 
 	assert.NoError(t, err)
-	assert.Nil(t, result)
+	assert.False(t, created)
+	assert.Equal(t, int64(2), ownerID)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestFindOrderByNumber_DatabaseError(t *testing.T) {
+func TestCreateOrder_DatabaseError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	orderNumber := "12345678903"
+	userID := int64(1)
 
-	mock.ExpectPrepare("SELECT id, number, status, uploaded_at, user_id FROM orders").
-		ExpectQuery().
-		WithArgs(orderNumber).
+	mock.ExpectQuery("INSERT INTO orders").
+		WithArgs(orderNumber, userID).
 		WillReturnError(errors.New("database error"))
 
-	result, err := storage.FindOrderByNumber(orderNumber)
+	_, created, err := storage.CreateOrder(orderNumber, userID)
 
 	assert.Error(t, err)
-	assert.Nil(t, result)
+	assert.False(t, created)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestCreateOrder_Success(t *testing.T) {
+func TestCreateOrder_ConcurrentSameNumber(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	suffix := time.Now().UnixNano()
+	const uploaders = 10
+	userIDs := make([]int64, 0, uploaders)
+	for i := range uploaders {
+		user, err := storage.CreateUser(fmt.Sprintf("racer-%d-%d", suffix, i), "hash")
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	orderNumber := fmt.Sprintf("%d", suffix)
+
+	type upload struct {
+		userID  int64
+		ownerID int64
+		created bool
+		err     error
+	}
+	results := make(chan upload, uploaders)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, userID := range userIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			ownerID, created, err := storage.CreateOrder(orderNumber, userID)
+			results <- upload{userID: userID, ownerID: ownerID, created: created, err: err}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	var winners []int64
+	owners := make(map[int64]struct{})
+	for result := range results {
+		require.NoError(t, result.err)
+		if result.created {
+			winners = append(winners, result.userID)
+			assert.Equal(t, result.userID, result.ownerID)
+		}
+		owners[result.ownerID] = struct{}{}
+	}
+
+	require.Len(t, winners, 1)
+	assert.Equal(t, map[int64]struct{}{winners[0]: {}}, owners)
+
+	ownerID, created, err := storage.CreateOrder(orderNumber, winners[0])
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, winners[0], ownerID)
+}
+
+func TestCreateOrders_MixedResults(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	orderNumber := "12345678903"
 	userID := int64(1)
 
-	mock.ExpectExec("INSERT INTO orders").
-		WithArgs(orderNumber, userID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO orders \(number, user_id\) VALUES \(\$2, \$1\), \(\$3, \$1\), \(\$4, \$1\) `+
+		`ON CONFLICT \(number\) DO NOTHING RETURNING number`).
+		WithArgs(userID, "12345678903", "4561261212345467", "79927398713").
+		WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow("79927398713"))
+	mock.ExpectQuery(`SELECT number, user_id FROM orders WHERE number IN \(\$1, \$2\)`).
+		WithArgs("4561261212345467", "12345678903").
+		WillReturnRows(sqlmock.NewRows([]string{"number", "user_id"}).
+			AddRow("4561261212345467", userID).
+			AddRow("12345678903", int64(2)))
+	mock.ExpectCommit()
 
-	err := storage.CreateOrder(orderNumber, userID)
+	results, err := storage.CreateOrders([]string{"79927398713", "4561261212345467", "12345678903"}, userID)
 
 	assert.NoError(t, err)
+	assert.Equal(t, []*domain.OrderUploadResult{
+		{Number: "79927398713", Result: domain.OrderUploadAccepted},
+		{Number: "4561261212345467", Result: domain.OrderUploadAlreadyUploaded},
+		{Number: "12345678903", Result: domain.OrderUploadConflict},
+	}, results)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestCreateOrder_DatabaseError(t *testing.T) {
+func TestCreateOrders_AllAccepted(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	orderNumber := "12345678903"
-	userID := int64(1)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO orders").
+		WithArgs(int64(1), "12345678903", "79927398713").
+		WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow("12345678903").AddRow("79927398713"))
+	mock.ExpectCommit()
 
-	mock.ExpectExec("INSERT INTO orders").
-		WithArgs(orderNumber, userID).
+	results, err := storage.CreateOrders([]string{"79927398713", "12345678903"}, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.OrderUploadResult{
+		{Number: "79927398713", Result: domain.OrderUploadAccepted},
+		{Number: "12345678903", Result: domain.OrderUploadAccepted},
+	}, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateOrders_InsertError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO orders").
+		WithArgs(int64(1), "12345678903").
 		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
 
-	err := storage.CreateOrder(orderNumber, userID)
+	results, err := storage.CreateOrders([]string{"12345678903"}, 1)
 
 	assert.Error(t, err)
+	assert.Nil(t, results)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestCreateOrders_OwnersError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO orders").
+		WithArgs(int64(1), "12345678903").
+		WillReturnRows(sqlmock.NewRows([]string{"number"}))
+	mock.ExpectQuery("SELECT number, user_id FROM orders").
+		WithArgs("12345678903").
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	results, err := storage.CreateOrders([]string{"12345678903"}, 1)
+
+	assert.Error(t, err)
+	assert.Nil(t, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateOrders_BatchAgainstExistingOrders(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	suffix := time.Now().UnixNano()
+	owner, err := storage.CreateUser(fmt.Sprintf("batch-owner-%d", suffix), "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	other, err := storage.CreateUser(fmt.Sprintf("batch-other-%d", suffix), "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	mine, theirs, fresh := fmt.Sprintf("%d1", suffix), fmt.Sprintf("%d2", suffix), fmt.Sprintf("%d3", suffix)
+	if _, _, err := storage.CreateOrder(mine, owner.ID); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	if _, _, err := storage.CreateOrder(theirs, other.ID); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	results, err := storage.CreateOrders([]string{fresh, mine, theirs}, owner.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.OrderUploadResult{
+		{Number: fresh, Result: domain.OrderUploadAccepted},
+		{Number: mine, Result: domain.OrderUploadAlreadyUploaded},
+		{Number: theirs, Result: domain.OrderUploadConflict},
+	}, results)
+
+	assert.Equal(t, owner.ID, FindOrderByNumber(t, storage, fresh).UserID)
+}
+
 func TestGetAllUnprocessedOrders_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
@@ -158,81 +296,184 @@ func TestGetAllUnprocessedOrders_DatabaseError(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetAllUserOrders_Success(t *testing.T) {
+func TestGetUserOrders_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	userID := int64(1)
+	uploadedAt := time.Now()
 	orders := []*domain.Order{
-		{Number: "12345678903", Status: "NEW", UploadedAt: time.Now()},
-		{Number: "98765432109", Status: "PROCESSED", UploadedAt: time.Now(), Accrual: func() *float64 { v := 50.0; return &v }()},
+		{Number: "12345678903", Status: "NEW", UploadedAt: uploadedAt},
+		{Number: "98765432109", Status: "PROCESSED", UploadedAt: uploadedAt, Accrual: func() *float64 { v := 50.0; return &v }()},
 	}
 
-	rows := sqlmock.NewRows([]string{"number", "status", "accrual", "uploaded_at"}).
-		AddRow(orders[0].Number, orders[0].Status, nil, orders[0].UploadedAt).
-		AddRow(orders[1].Number, orders[1].Status, formatter.ConvertToSubunit(50.0), orders[1].UploadedAt)
+	rows := sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at"}).
+		AddRow(2, orders[0].Number, orders[0].Status, nil, orders[0].UploadedAt).
+		AddRow(1, orders[1].Number, orders[1].Status, formatter.ConvertToSubunit(50.0), orders[1].UploadedAt)
 
-	mock.ExpectPrepare("SELECT o.number, o.status, a.accrual, o.uploaded_at FROM orders o").
-		ExpectQuery().
-		WithArgs(userID).
+	mock.ExpectQuery(`SELECT o.id, o.number, o.status, a.accrual, o.uploaded_at\s+FROM orders o.*WHERE o.user_id = \$1\s+`+
+		`ORDER BY o.uploaded_at DESC, o.id DESC\s+LIMIT \$2`).
+		WithArgs(userID, 11).
 		WillReturnRows(rows)
 
-	result, err := storage.GetAllUserOrders(userID)
+	result, err := storage.GetUserOrders(domain.OrderFilter{UserID: userID, Limit: 10})
 
 	assert.NoError(t, err)
-	assert.Equal(t, orders, result)
+	assert.Equal(t, &domain.OrderPage{Orders: orders}, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetAllUserOrders_NoOrders(t *testing.T) {
+func TestGetUserOrders_NextCursor(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	userID := int64(1)
-	rows := sqlmock.NewRows([]string{"number", "status", "accrual", "uploaded_at"})
+	newest := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	older := newest.Add(-time.Hour)
 
-	mock.ExpectPrepare("SELECT o.number, o.status, a.accrual, o.uploaded_at FROM orders o").
-		ExpectQuery().
-		WithArgs(userID).
+	rows := sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at"}).
+		AddRow(7, "12345678903", "NEW", nil, newest).
+		AddRow(5, "98765432109", "NEW", nil, older).
+		AddRow(3, "4561261212345467", "NEW", nil, older)
+
+	mock.ExpectQuery("SELECT o.id, o.number").
+		WithArgs(int64(1), 3).
 		WillReturnRows(rows)
 
-	result, err := storage.GetAllUserOrders(userID)
+	result, err := storage.GetUserOrders(domain.OrderFilter{UserID: 1, Limit: 2})
 
 	assert.NoError(t, err)
-	assert.Empty(t, result)
+	assert.Len(t, result.Orders, 2)
+	assert.Equal(t, &domain.Cursor{Time: older, ID: 5}, result.NextCursor)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetAllUserOrders_DatabaseError(t *testing.T) {
+func TestGetUserOrders_Filters(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	userID := int64(1)
+	cursor := &domain.Cursor{Time: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC), ID: 42}
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
 
-	mock.ExpectPrepare("SELECT o.number, o.status, a.accrual, o.uploaded_at FROM orders o").
-		ExpectQuery().
-		WithArgs(userID).
+	mock.ExpectQuery(`WHERE o.user_id = \$1 AND \(o.uploaded_at, o.id\) < \(\$2, \$3\) `+
+		`AND o.status IN \(\$4, \$5\) AND o.uploaded_at >= \$6 AND o.uploaded_at < \$7\s+`+
+		`ORDER BY o.uploaded_at DESC, o.id DESC\s+LIMIT \$8`).
+		WithArgs(int64(1), cursor.Time, cursor.ID, "NEW", "PROCESSING", from, to, 51).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at"}))
+
+	result, err := storage.GetUserOrders(domain.OrderFilter{
+		UserID:   1,
+		Statuses: []string{"NEW", "PROCESSING"},
+		Uploaded: domain.TimeRange{From: &from, To: &to},
+		Limit:    50,
+		After:    cursor,
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, result.Orders)
+	assert.Nil(t, result.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOrders_WithoutLimit(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	uploadedAt := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at"})
+	for i := range 60 {
+		rows.AddRow(int64(60-i), fmt.Sprintf("%d", 1000+i), "NEW", nil, uploadedAt)
+	}
+	mock.ExpectQuery(`WHERE o.user_id = \$1\s+ORDER BY o.uploaded_at DESC, o.id DESC$`).
+		WithArgs(int64(1)).
+		WillReturnRows(rows)
+
+	result, err := storage.GetUserOrders(domain.OrderFilter{UserID: 1})
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Orders, 60)
+	assert.Nil(t, result.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOrders_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("SELECT o.id, o.number").
+		WithArgs(int64(1), 11).
 		WillReturnError(errors.New("database error"))
 
-	result, err := storage.GetAllUserOrders(userID)
+	result, err := storage.GetUserOrders(domain.OrderFilter{UserID: 1, Limit: 10})
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetUserOrders_PagesThroughHistory(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	suffix := time.Now().UnixNano()
+	user, err := storage.CreateUser(fmt.Sprintf("pager-%d", suffix), "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	const total = 5
+	for i := range total {
+		if _, _, err := storage.CreateOrder(fmt.Sprintf("%d%d", suffix, i), user.ID); err != nil {
+			t.Fatalf("Failed to create order: %v", err)
+		}
+	}
+
+	var seen []string
+	filter := domain.OrderFilter{UserID: user.ID, Limit: 2}
+	for {
+		page, err := storage.GetUserOrders(filter)
+		assert.NoError(t, err)
+		for _, order := range page.Orders {
+			seen = append(seen, order.Number)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		filter.After = page.NextCursor
+	}
+
+	assert.Len(t, seen, total)
+	for i := range total {
+		assert.Equal(t, fmt.Sprintf("%d%d", suffix, total-1-i), seen[i])
+	}
+}
+
 func TestUpdateOrderAccrualStatus_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	orderID := int64(1)
+	userID := int64(2)
+	orderNumber := "12345678903"
 	status := "PROCESSED"
 	accrual := 50.0
 	accrualInSubunit := formatter.ConvertToSubunit(accrual)
 
 	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("PROCESSING", userID, orderNumber))
 	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
 		WithArgs(orderID, status).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`INSERT INTO accruals \(order_id, accrual\) VALUES \(\$1, \$2\)`).
+	mock.ExpectExec(`INSERT INTO order_status_history \(order_id, status\) VALUES \(\$1, \$2\)`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectWebhookEvent(mock, domain.WebhookOrderProcessed, `{"user_id":2,"order":"12345678903","status":"PROCESSED","accrual":50}`)
+	mock.ExpectQuery(`SELECT accrual FROM accruals WHERE order_id = \$1`).
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO accruals \(order_id, accrual\) VALUES \(\$1, \$2\)\s+ON CONFLICT \(order_id\) DO UPDATE`).
 		WithArgs(orderID, accrualInSubunit).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceLock(mock, userID, 1000)
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(userID, domain.LedgerEntryAccrual, int64(accrualInSubunit), orderNumber).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceChange(mock, userID, int64(accrualInSubunit), 0)
 	mock.ExpectCommit()
 
 	err := storage.UpdateOrderAccrualStatus(orderID, status, &accrual)
@@ -248,9 +489,65 @@ func TestUpdateOrderAccrualStatus_NoAccrual(t *testing.T) {
 	status := "PROCESSED"
 
 	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("NEW", int64(1), "12345678903"))
+	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history \(order_id, status\) VALUES \(\$1, \$2\)`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectWebhookEvent(mock, domain.WebhookOrderProcessed, `{"user_id":1,"order":"12345678903","status":"PROCESSED"}`)
+	mock.ExpectCommit()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, status, nil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderAccrualStatus_InvalidEmitsWebhook(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+	status := "INVALID"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("PROCESSING", int64(1), "12345678903"))
+	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history \(order_id, status\) VALUES \(\$1, \$2\)`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectWebhookEvent(mock, domain.WebhookOrderInvalid, `{"user_id":1,"order":"12345678903","status":"INVALID"}`)
+	mock.ExpectCommit()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, status, nil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderAccrualStatus_ProcessingEmitsNoWebhook(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+	status := "PROCESSING"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("NEW", int64(1), "12345678903"))
 	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
 		WithArgs(orderID, status).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history \(order_id, status\) VALUES \(\$1, \$2\)`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	err := storage.UpdateOrderAccrualStatus(orderID, status, nil)
@@ -259,6 +556,127 @@ func TestUpdateOrderAccrualStatus_NoAccrual(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUpdateOrderAccrualStatus_RepeatedProcessed(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+	status := "PROCESSED"
+	accrual := 50.0
+	accrualInSubunit := formatter.ConvertToSubunit(accrual)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("PROCESSED", int64(1), "12345678903"))
+	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT accrual FROM accruals WHERE order_id = \$1`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"accrual"}).AddRow(accrualInSubunit))
+	mock.ExpectExec(`INSERT INTO accruals .* ON CONFLICT \(order_id\) DO UPDATE SET accrual = EXCLUDED.accrual`).
+		WithArgs(orderID, accrualInSubunit).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, status, &accrual)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderAccrualStatus_AccrualCorrection(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+	status := "PROCESSED"
+	accrual := 50.0
+	accrualInSubunit := formatter.ConvertToSubunit(accrual)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("PROCESSED", int64(1), "12345678903"))
+	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT accrual FROM accruals WHERE order_id = \$1`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"accrual"}).AddRow(3000))
+	mock.ExpectExec(`INSERT INTO accruals .* ON CONFLICT \(order_id\) DO UPDATE SET accrual = EXCLUDED.accrual`).
+		WithArgs(orderID, accrualInSubunit).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceLock(mock, int64(1), 3000)
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(int64(1), domain.LedgerEntryAccrual, int64(2000), "12345678903").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceChange(mock, int64(1), 2000, 0)
+	mock.ExpectCommit()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, status, &accrual)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderAccrualStatus_HistoryInsertError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+	status := "PROCESSING"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("NEW", int64(1), "12345678903"))
+	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
+		WithArgs(orderID, status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO order_status_history").
+		WithArgs(orderID, status).
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, status, nil)
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderAccrualStatus_StatusRegression(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("PROCESSED", int64(1), "12345678903"))
+	mock.ExpectRollback()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, "PROCESSING", nil)
+
+	assert.ErrorIs(t, err, domain.ErrOrderStatusRegression)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderAccrualStatus_OrderNotFound(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderID := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := storage.UpdateOrderAccrualStatus(orderID, "PROCESSED", nil)
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateOrderAccrualStatus_DatabaseError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
@@ -267,6 +685,9 @@ func TestUpdateOrderAccrualStatus_DatabaseError(t *testing.T) {
 	accrual := 50.0
 
 	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, user_id, number FROM orders WHERE id = \$1 FOR UPDATE`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "user_id", "number"}).AddRow("NEW", int64(1), "12345678903"))
 	mock.ExpectExec(`UPDATE orders SET status = \$2 WHERE id = \$1`).
 		WithArgs(orderID, status).
 		WillReturnError(errors.New("database error"))
@@ -277,3 +698,161 @@ func TestUpdateOrderAccrualStatus_DatabaseError(t *testing.T) {
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetUserOrderDetails_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	uploadedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	processingAt := uploadedAt.Add(time.Minute)
+	processedAt := uploadedAt.Add(2 * time.Minute)
+	lastPolledAt := processedAt.Add(time.Second)
+
+	mock.ExpectQuery(`SELECT o.id, o.number, o.status, a.accrual, o.uploaded_at, o.poll_count, o.last_polled_at\s+FROM orders o.*`+
+		`WHERE o.number = \$1 AND o.user_id = \$2`).
+		WithArgs("12345678903", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at", "poll_count", "last_polled_at"}).
+			AddRow(7, "12345678903", "PROCESSED", 5025, uploadedAt, 3, lastPolledAt))
+	mock.ExpectQuery(`SELECT status, changed_at FROM order_status_history WHERE order_id = \$1 ORDER BY changed_at NULLS FIRST, id`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "changed_at"}).
+			AddRow("PROCESSING", processingAt).
+			AddRow("PROCESSED", processedAt))
+
+	details, err := storage.GetUserOrderDetails(2, "12345678903")
+
+	accrual := 50.25
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.OrderDetails{
+		Number:       "12345678903",
+		Status:       "PROCESSED",
+		Accrual:      &accrual,
+		UploadedAt:   uploadedAt,
+		PollCount:    3,
+		LastPolledAt: &lastPolledAt,
+		History: []*domain.OrderStatusChange{
+			{Status: "NEW", ChangedAt: &uploadedAt},
+			{Status: "PROCESSING", ChangedAt: &processingAt},
+			{Status: "PROCESSED", ChangedAt: &processedAt},
+		},
+	}, details)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOrderDetails_LabelsBackfilledHistory(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	uploadedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	processedAt := uploadedAt.Add(time.Hour)
+
+	mock.ExpectQuery("SELECT o.id, o.number").
+		WithArgs("12345678903", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at", "poll_count", "last_polled_at"}).
+			AddRow(7, "12345678903", "PROCESSED", 5025, uploadedAt, 0, nil))
+	mock.ExpectQuery("SELECT status, changed_at FROM order_status_history").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "changed_at"}).
+			AddRow("PROCESSING", nil).
+			AddRow("PROCESSED", processedAt))
+
+	details, err := storage.GetUserOrderDetails(2, "12345678903")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.OrderStatusChange{
+		{Status: "NEW", ChangedAt: &uploadedAt},
+		{Status: "PROCESSING", Backfilled: true},
+		{Status: "PROCESSED", ChangedAt: &processedAt},
+	}, details.History)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOrderDetails_NotFound(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("SELECT o.id, o.number").
+		WithArgs("12345678903", int64(2)).
+		WillReturnError(sql.ErrNoRows)
+
+	details, err := storage.GetUserOrderDetails(2, "12345678903")
+
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Nil(t, details)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOrderDetails_HistoryError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("SELECT o.id, o.number").
+		WithArgs("12345678903", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at", "poll_count", "last_polled_at"}).
+			AddRow(7, "12345678903", "NEW", nil, time.Now(), 0, nil))
+	mock.ExpectQuery("SELECT status, changed_at FROM order_status_history").
+		WithArgs(int64(7)).
+		WillReturnError(errors.New("database error"))
+
+	details, err := storage.GetUserOrderDetails(2, "12345678903")
+
+	assert.Error(t, err)
+	assert.Nil(t, details)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordOrderPoll_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec(`UPDATE orders SET poll_count = poll_count \+ 1, last_polled_at = NOW\(\) WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, storage.RecordOrderPoll(7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordOrderPoll_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec("UPDATE orders SET poll_count").
+		WithArgs(int64(7)).
+		WillReturnError(errors.New("database error"))
+
+	assert.Error(t, storage.RecordOrderPoll(7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOrderDetails_RecordsTimeline(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	suffix := time.Now().UnixNano()
+	user, err := storage.CreateUser(fmt.Sprintf("timeline-%d", suffix), "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := user.ID
+
+	orderNumber := fmt.Sprintf("%d", suffix)
+	if _, _, err := storage.CreateOrder(orderNumber, userID); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	order := FindOrderByNumber(t, storage, orderNumber)
+
+	accrual := 12.5
+	assert.NoError(t, storage.RecordOrderPoll(order.ID))
+	assert.NoError(t, storage.UpdateOrderAccrualStatus(order.ID, domain.OrderStatusProcessing, nil))
+	assert.NoError(t, storage.RecordOrderPoll(order.ID))
+	assert.NoError(t, storage.UpdateOrderAccrualStatus(order.ID, domain.OrderStatusProcessed, &accrual))
+
+	details, err := storage.GetUserOrderDetails(userID, orderNumber)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, details.PollCount)
+	assert.NotNil(t, details.LastPolledAt)
+	assert.Equal(t, &accrual, details.Accrual)
+	statuses := make([]string, 0, len(details.History))
+	for _, change := range details.History {
+		statuses = append(statuses, change.Status)
+	}
+	assert.Equal(t, []string{"NEW", "PROCESSING", "PROCESSED"}, statuses)
+
+	_, err = storage.GetUserOrderDetails(userID+1, orderNumber)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
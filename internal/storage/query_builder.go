@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (wb *whereBuilder) arg(value any) string {
+	wb.args = append(wb.args, value)
+	return "$" + strconv.Itoa(len(wb.args))
+}
+
+func (wb *whereBuilder) argList(values []string) string {
+	placeholders := make([]string, 0, len(values))
+	for _, value := range values {
+		placeholders = append(placeholders, wb.arg(value))
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func (wb *whereBuilder) where(condition string) {
+	wb.conditions = append(wb.conditions, condition)
+}
+
+func (wb *whereBuilder) String() string {
+	return strings.Join(wb.conditions, " AND ")
+}
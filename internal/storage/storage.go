@@ -2,10 +2,14 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 )
 
+const uniqueViolationCode = "23505"
+
 type Storage struct {
 	db     *sql.DB
 	logger *zap.SugaredLogger
@@ -17,3 +21,8 @@ func NewStorage(db *sql.DB, lgr *zap.SugaredLogger) *Storage {
 		logger: lgr,
 	}
 }
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
@@ -1,12 +1,20 @@
 package storage
 
 import (
+	"database/sql"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frolmr/gophermart/internal/db/migrator"
+	"github.com/frolmr/gophermart/internal/domain"
 	"go.uber.org/zap"
 )
 
+const testDatabaseURIEnvName = "TEST_DATABASE_URI"
+
 func NewMockStorage(t *testing.T) (*Storage, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -18,3 +26,76 @@ func NewMockStorage(t *testing.T) (*Storage, sqlmock.Sqlmock) {
 
 	return storage, mock
 }
+
+func ExpectBalanceLock(mock sqlmock.Sqlmock, userID, current int64) {
+	mock.ExpectExec(`INSERT INTO user_balances \(user_id\) VALUES \(\$1\) ON CONFLICT \(user_id\) DO NOTHING`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT current FROM user_balances WHERE user_id = \$1 FOR UPDATE`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow(current))
+}
+
+func ExpectBalanceChange(mock sqlmock.Sqlmock, userID, currentDelta, withdrawnDelta int64) {
+	mock.ExpectExec(`UPDATE user_balances\s+SET current = current \+ \$2, withdrawn = withdrawn \+ \$3\s+WHERE user_id = \$1`).
+		WithArgs(userID, currentDelta, withdrawnDelta).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ExpectWebhookEvent(mock sqlmock.Sqlmock, eventType, payload string) {
+	mock.ExpectExec(`WITH subscribers AS \(.*INSERT INTO webhook_outbox \(event_type, payload\)\s+`+
+		`SELECT \$1::text, \$2::jsonb WHERE EXISTS \(SELECT 1 FROM subscribers\)`).
+		WithArgs(eventType, payload).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func NewIntegrationStorage(t *testing.T) *Storage {
+	dbURI := os.Getenv(testDatabaseURIEnvName)
+	if dbURI == "" {
+		t.Skipf("%s is not set, skipping integration test", testDatabaseURIEnvName)
+	}
+
+	if err := migrator.NewMigrator(dbURI).RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	db, err := sql.Open("pgx", dbURI)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewStorage(db, zap.NewNop().Sugar())
+}
+
+func SeedUserWithAccrual(t *testing.T, storage *Storage, accrual float64) int64 {
+	suffix := time.Now().UnixNano()
+
+	user, err := storage.CreateUser(fmt.Sprintf("user-%d", suffix), "password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	orderNumber := fmt.Sprintf("%d", suffix)
+	if _, _, err := storage.CreateOrder(orderNumber, user.ID); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	order := FindOrderByNumber(t, storage, orderNumber)
+	if err := storage.UpdateOrderAccrualStatus(order.ID, "PROCESSED", &accrual); err != nil {
+		t.Fatalf("Failed to settle accrual: %v", err)
+	}
+
+	return user.ID
+}
+
+func FindOrderByNumber(t *testing.T, storage *Storage, number string) *domain.DBOrder {
+	var order domain.DBOrder
+	err := storage.db.QueryRow("SELECT id, number, status, uploaded_at, user_id FROM orders WHERE number = $1", number).
+		Scan(&order.ID, &order.Number, &order.Status, &order.UploadedAt, &order.UserID)
+	if err != nil {
+		t.Fatalf("Failed to find order# %s: %v", number, err)
+	}
+
+	return &order
+}
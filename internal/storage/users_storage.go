@@ -7,72 +7,150 @@ import (
 	"time"
 
 	"github.com/frolmr/gophermart/internal/domain"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func (s *Storage) CreateUser(login, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (s *Storage) CreateUser(login, passwordHash string) (*domain.DBUser, error) {
+	var user domain.DBUser
+	err := s.db.QueryRow(
+		"INSERT INTO users (login, password_hash) VALUES ($1, $2) RETURNING id, login, password_hash", login, passwordHash,
+	).Scan(&user.ID, &user.Login, &user.PasswordHash)
 	if err != nil {
-		s.logger.Errorf("Password encryption failed for user: %s, err: %s", login, err.Error())
-		return fmt.Errorf("error creating user: %w", err)
-	}
-
-	if _, err := s.db.Exec("INSERT INTO users (login, password_hash) VALUES ($1, $2)", login, string(hashedPassword)); err != nil {
+		if isUniqueViolation(err) {
+			return nil, domain.ErrUserExists
+		}
 		s.logger.Errorf("New user insertion failed, user: %s, err: %s", login, err.Error())
-		return fmt.Errorf("error creating user: %w", err)
+		return nil, fmt.Errorf("error creating user: %w", err)
 	}
 
-	return nil
+	return &user, nil
 }
 
-func (s *Storage) CreateAndReturnUser(login, password string) (*domain.DBUser, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		s.logger.Errorf("Password encryption failed for user: %s, err: %s", login, err.Error())
-		return nil, fmt.Errorf("error creating user: %w", err)
-	}
-
-	stmt, err := s.db.Prepare("INSERT INTO users (login, password_hash) VALUES ($1, $2) RETURNING id, login, password_hash")
+func (s *Storage) GetUserByLogin(login string) (*domain.DBUser, error) {
+	stmt, err := s.db.Prepare("SELECT id, login, password_hash FROM users WHERE login = $1")
 	if err != nil {
 		s.logger.Errorf("Can't prepare statement for user: %s, err: %s", login, err.Error())
-		return nil, fmt.Errorf("error creating user: %w", err)
+		return nil, fmt.Errorf("error getting user: %w", err)
 	}
 	defer stmt.Close()
 
 	var user domain.DBUser
-	err = stmt.QueryRow(login, hashedPassword).Scan(&user.ID, &user.Login, &user.PasswordHash)
+	err = stmt.QueryRow(login).Scan(&user.ID, &user.Login, &user.PasswordHash)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
+			return nil, domain.ErrNotFound
 		} else {
 			s.logger.Errorf("User query fails for user: %s, err: %s", login, err.Error())
-			return nil, fmt.Errorf("error creating user: %w", err)
+			return nil, fmt.Errorf("error getting user: %w", err)
 		}
 	}
 
 	return &user, nil
 }
 
-func (s *Storage) GetUserByLogin(login string) (*domain.DBUser, error) {
-	stmt, err := s.db.Prepare("SELECT id, login, password_hash FROM users WHERE login = $1")
+func (s *Storage) GetUserByID(userID int64) (*domain.DBUser, error) {
+	var user domain.DBUser
+	err := s.db.QueryRow(
+		"SELECT id, login, password_hash FROM users WHERE id = $1 AND deleted_at IS NULL", userID,
+	).Scan(&user.ID, &user.Login, &user.PasswordHash)
 	if err != nil {
-		s.logger.Errorf("Can't prepare statement for user: %s, err: %s", login, err.Error())
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		s.logger.Errorf("User query fails for user: %d, err: %s", userID, err.Error())
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
-	defer stmt.Close()
 
-	var user domain.DBUser
-	err = stmt.QueryRow(login).Scan(&user.ID, &user.Login, &user.PasswordHash)
+	return &user, nil
+}
+
+func (s *Storage) UpdateUserPassword(userID int64, passwordHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Errorf("Failed to begin transaction for password change of user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID); err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to update password for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM refresh_tokens WHERE user_id = $1", userID); err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to revoke refresh tokens for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Failed to commit password change for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdatePasswordHash(userID int64, oldHash, newHash string) error {
+	_, err := s.db.Exec(
+		"UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3 AND deleted_at IS NULL",
+		newHash, userID, oldHash,
+	)
+	if err != nil {
+		s.logger.Errorf("Failed to rehash password for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error updating password hash: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AnonymizeUser(userID int64) error {
+	tx, err := s.db.Begin()
 	if err != nil {
+		s.logger.Errorf("Failed to begin transaction for deletion of user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+
+	var login string
+	err = tx.QueryRow(
+		"SELECT login FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", userID,
+	).Scan(&login)
+	if err != nil {
+		_ = tx.Rollback()
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		} else {
-			s.logger.Errorf("User query fails for user: %s, err: %s", login, err.Error())
-			return nil, fmt.Errorf("error getting user: %w", err)
+			return nil
 		}
+		s.logger.Errorf("Failed to lock user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user: %w", err)
 	}
 
-	return &user, nil
+	if _, err := tx.Exec(
+		"UPDATE users SET login = NULL, password_hash = '', deleted_at = NOW() WHERE id = $1", userID,
+	); err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to anonymize user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM refresh_tokens WHERE user_id = $1", userID); err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to revoke refresh tokens for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		"DELETE FROM login_attempts WHERE scope = $1 AND key = $2", domain.LoginAttemptScopeLogin, login,
+	); err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to clear login attempts for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Failed to commit deletion of user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+
+	return nil
 }
 
 func (s *Storage) StoreRefreshToken(userID int64, token string, expiresAt time.Time) error {
@@ -85,7 +163,7 @@ func (s *Storage) StoreRefreshToken(userID int64, token string, expiresAt time.T
 }
 
 func (s *Storage) GetRefreshToken(token string) (*domain.RefreshToken, error) {
-	stmt, err := s.db.Prepare("SELECT id, user_id, token, expires_at FROM refresh_tokens WHERE token = $1")
+	stmt, err := s.db.Prepare("SELECT id, user_id, token, expires_at, family_id, used_at FROM refresh_tokens WHERE token = $1")
 	if err != nil {
 		s.logger.Errorf("Can't prepare statement for refresh token: %s, err: %s", token, err.Error())
 		return nil, fmt.Errorf("error getting refresh token: %w", err)
@@ -93,18 +171,97 @@ func (s *Storage) GetRefreshToken(token string) (*domain.RefreshToken, error) {
 	defer stmt.Close()
 
 	var refreshToken domain.RefreshToken
-	err = stmt.QueryRow(token).Scan(&refreshToken.ID, &refreshToken.UserID, &refreshToken.Token, &refreshToken.ExpiresAt)
+	var usedAt sql.NullTime
+	err = stmt.QueryRow(token).Scan(
+		&refreshToken.ID, &refreshToken.UserID, &refreshToken.Token, &refreshToken.ExpiresAt, &refreshToken.FamilyID, &usedAt,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
+			return nil, domain.ErrNotFound
 		}
 		s.logger.Errorf("Failed to get refresh token: %s", err.Error())
 		return nil, fmt.Errorf("error getting refresh token: %w", err)
 	}
 
+	if usedAt.Valid {
+		refreshToken.UsedAt = &usedAt.Time
+	}
+
 	return &refreshToken, nil
 }
 
+func (s *Storage) RotateRefreshToken(oldToken, newToken string, expiresAt time.Time) (*domain.RefreshToken, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Errorf("Failed to begin transaction for refresh token rotation: %s", err.Error())
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+
+	var current domain.RefreshToken
+	var usedAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT id, user_id, expires_at, family_id, used_at FROM refresh_tokens WHERE token = $1 FOR UPDATE", oldToken,
+	).Scan(&current.ID, &current.UserID, &current.ExpiresAt, &current.FamilyID, &usedAt)
+	if err != nil {
+		_ = tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRefreshTokenInvalid
+		}
+		s.logger.Errorf("Failed to lock refresh token: %s", err.Error())
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+
+	if usedAt.Valid {
+		if _, err := tx.Exec("DELETE FROM refresh_tokens WHERE family_id = $1", current.FamilyID); err != nil {
+			_ = tx.Rollback()
+			s.logger.Errorf("Failed to revoke refresh token family: %s, err: %s", current.FamilyID, err.Error())
+			return nil, fmt.Errorf("error revoking refresh token family: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			s.logger.Errorf("Failed to commit refresh token family revocation: %s", err.Error())
+			return nil, fmt.Errorf("error revoking refresh token family: %w", err)
+		}
+		s.logger.Warnf("Refresh token reuse detected for user: %d, family %s revoked", current.UserID, current.FamilyID)
+		return nil, domain.ErrRefreshTokenReused
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		_ = tx.Rollback()
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+
+	rotated := domain.RefreshToken{
+		UserID:    current.UserID,
+		Token:     newToken,
+		ExpiresAt: expiresAt,
+		FamilyID:  current.FamilyID,
+	}
+	err = tx.QueryRow(
+		"INSERT INTO refresh_tokens (user_id, token, expires_at, family_id) VALUES ($1, $2, $3, $4) RETURNING id",
+		rotated.UserID, rotated.Token, rotated.ExpiresAt, rotated.FamilyID,
+	).Scan(&rotated.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to store rotated refresh token for user: %d, err: %s", current.UserID, err.Error())
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE refresh_tokens SET used_at = NOW(), replaced_by = $1 WHERE id = $2", rotated.ID, current.ID,
+	); err != nil {
+		_ = tx.Rollback()
+		s.logger.Errorf("Failed to mark refresh token as used: %s", err.Error())
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Failed to commit refresh token rotation: %s", err.Error())
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+
+	return &rotated, nil
+}
+
 func (s *Storage) DeleteRefreshToken(token string) error {
 	_, err := s.db.Exec("DELETE FROM refresh_tokens WHERE token = $1", token)
 	if err != nil {
@@ -113,3 +270,12 @@ func (s *Storage) DeleteRefreshToken(token string) error {
 	}
 	return nil
 }
+
+func (s *Storage) DeleteUserRefreshTokens(userID int64) error {
+	_, err := s.db.Exec("DELETE FROM refresh_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		s.logger.Errorf("Failed to delete refresh tokens for user: %d, err: %s", userID, err.Error())
+		return fmt.Errorf("error deleting user refresh tokens: %w", err)
+	}
+	return nil
+}
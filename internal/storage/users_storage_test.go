@@ -3,11 +3,14 @@ package storage
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,76 +18,88 @@ func TestCreateUser_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	login := "testuser"
-	password := "testpassword"
+	hashedPassword := "hashedpassword"
+	userID := int64(1)
 
-	mock.ExpectExec("INSERT INTO users").
-		WithArgs(login, sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO users \(login, password_hash\) VALUES \(\$1, \$2\) RETURNING id, login, password_hash`).
+		WithArgs(login, hashedPassword).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password_hash"}).
+			AddRow(userID, login, hashedPassword))
 
-	err := storage.CreateUser(login, password)
+	user, err := storage.CreateUser(login, hashedPassword)
 
 	assert.NoError(t, err)
+	assert.Equal(t, &domain.DBUser{ID: userID, Login: login, PasswordHash: hashedPassword}, user)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestCreateUser_DatabaseError(t *testing.T) {
+func TestCreateUser_LoginTaken(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	login := "testuser"
-	password := "testpassword"
-
-	mock.ExpectExec("INSERT INTO users").
-		WithArgs(login, sqlmock.AnyArg()).
-		WillReturnError(errors.New("database error"))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", "hashedpassword").
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "users_login_key"})
 
-	err := storage.CreateUser(login, password)
-
-	assert.Error(t, err)
-	assert.NoError(t, mock.ExpectationsWereMet())
-}
-
-func TestCreateAndReturnUser_Success(t *testing.T) {
-	storage, mock := NewMockStorage(t)
-
-	login := "testuser"
-	password := "testpassword"
-	hashedPassword := "hashedpassword"
-	userID := int64(1)
-
-	mock.ExpectPrepare("INSERT INTO users").
-		ExpectQuery().
-		WithArgs(login, sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password_hash"}).
-			AddRow(userID, login, hashedPassword))
+	user, err := storage.CreateUser("testuser", "hashedpassword")
 
-	user, err := storage.CreateAndReturnUser(login, password)
-
-	assert.NoError(t, err)
-	assert.NotNil(t, user)
-	assert.Equal(t, userID, user.ID)
-	assert.Equal(t, login, user.Login)
-	assert.Equal(t, hashedPassword, user.PasswordHash)
+	assert.ErrorIs(t, err, domain.ErrUserExists)
+	assert.Nil(t, user)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestCreateAndReturnUser_DatabaseError(t *testing.T) {
+func TestCreateUser_DatabaseError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	login := "testuser"
-	password := "testpassword"
-
-	mock.ExpectPrepare("INSERT INTO users").
-		ExpectQuery().
-		WithArgs(login, sqlmock.AnyArg()).
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", "hashedpassword").
 		WillReturnError(errors.New("database error"))
 
-	user, err := storage.CreateAndReturnUser(login, password)
+	user, err := storage.CreateUser("testuser", "hashedpassword")
 
 	assert.Error(t, err)
+	assert.NotErrorIs(t, err, domain.ErrUserExists)
 	assert.Nil(t, user)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestCreateUser_ConcurrentSameLogin(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	const registrations = 10
+	login := fmt.Sprintf("racer-%d", time.Now().UnixNano())
+
+	start := make(chan struct{})
+	errs := make(chan error, registrations)
+	var wg sync.WaitGroup
+	for range registrations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := storage.CreateUser(login, "hash")
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	var created, conflicts int
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, domain.ErrUserExists):
+			conflicts++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	assert.Equal(t, 1, created)
+	assert.Equal(t, registrations-1, conflicts)
+}
+
 func TestGetUserByLogin_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
@@ -120,7 +135,7 @@ func TestGetUserByLogin_NotFound(t *testing.T) {
 
 	user, err := storage.GetUserByLogin(login)
 
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
 	assert.Nil(t, user)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -185,13 +200,14 @@ func TestGetRefreshToken_Success(t *testing.T) {
 		UserID:    1,
 		Token:     token,
 		ExpiresAt: time.Now().Add(time.Hour),
+		FamilyID:  "family-1",
 	}
 
-	mock.ExpectPrepare("SELECT id, user_id, token, expires_at FROM refresh_tokens WHERE token = \\$1").
+	mock.ExpectPrepare("SELECT id, user_id, token, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \\$1").
 		ExpectQuery().
 		WithArgs(token).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at"}).
-			AddRow(refreshToken.ID, refreshToken.UserID, refreshToken.Token, refreshToken.ExpiresAt))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "family_id", "used_at"}).
+			AddRow(refreshToken.ID, refreshToken.UserID, refreshToken.Token, refreshToken.ExpiresAt, refreshToken.FamilyID, nil))
 
 	result, err := storage.GetRefreshToken(token)
 
@@ -205,14 +221,14 @@ func TestGetRefreshToken_NotFound(t *testing.T) {
 
 	token := "invalid-refresh-token"
 
-	mock.ExpectPrepare("SELECT id, user_id, token, expires_at FROM refresh_tokens WHERE token = \\$1").
+	mock.ExpectPrepare("SELECT id, user_id, token, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \\$1").
 		ExpectQuery().
 		WithArgs(token).
 		WillReturnError(sql.ErrNoRows)
 
 	result, err := storage.GetRefreshToken(token)
 
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
 	assert.Nil(t, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -222,7 +238,7 @@ func TestGetRefreshToken_DatabaseError(t *testing.T) {
 
 	token := "invalid-refresh-token"
 
-	mock.ExpectPrepare("SELECT id, user_id, token, expires_at FROM refresh_tokens WHERE token = \\$1").
+	mock.ExpectPrepare("SELECT id, user_id, token, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \\$1").
 		ExpectQuery().
 		WithArgs(token).
 		WillReturnError(errors.New("database error"))
@@ -263,3 +279,280 @@ func TestDeleteRefreshToken_DatabaseError(t *testing.T) {
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestDeleteUserRefreshTokens_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	userID := int64(1)
+
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	err := storage.DeleteUserRefreshTokens(userID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteUserRefreshTokens_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	userID := int64(1)
+
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnError(errors.New("database error"))
+
+	err := storage.DeleteUserRefreshTokens(userID)
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \$1 FOR UPDATE`).
+		WithArgs("old-token").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at", "family_id", "used_at"}).
+			AddRow(1, 1, expiresAt, "family-1", nil))
+	mock.ExpectQuery("INSERT INTO refresh_tokens").
+		WithArgs(int64(1), "new-token", expiresAt, "family-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(`UPDATE refresh_tokens SET used_at = NOW\(\), replaced_by = \$1 WHERE id = \$2`).
+		WithArgs(int64(2), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := storage.RotateRefreshToken("old-token", "new-token", expiresAt)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.RefreshToken{
+		ID:        2,
+		UserID:    1,
+		Token:     "new-token",
+		ExpiresAt: expiresAt,
+		FamilyID:  "family-1",
+	}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \$1 FOR UPDATE`).
+		WithArgs("used-token").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at", "family_id", "used_at"}).
+			AddRow(1, 1, time.Now().Add(time.Hour), "family-1", time.Now().Add(-time.Minute)))
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE family_id = \$1`).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	result, err := storage.RotateRefreshToken("used-token", "new-token", time.Now().Add(time.Hour))
+
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_Expired(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \$1 FOR UPDATE`).
+		WithArgs("expired-token").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at", "family_id", "used_at"}).
+			AddRow(1, 1, time.Now().Add(-time.Hour), "family-1", nil))
+	mock.ExpectRollback()
+
+	result, err := storage.RotateRefreshToken("expired-token", "new-token", time.Now().Add(time.Hour))
+
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_NotFound(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, expires_at, family_id, used_at FROM refresh_tokens WHERE token = \$1 FOR UPDATE`).
+		WithArgs("unknown-token").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	result, err := storage.RotateRefreshToken("unknown-token", "new-token", time.Now().Add(time.Hour))
+
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_ReuseAfterRotation(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	suffix := time.Now().UnixNano()
+	user, err := storage.CreateUser(fmt.Sprintf("user-%d", suffix), "password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	firstToken := fmt.Sprintf("token-%d-1", suffix)
+	secondToken := fmt.Sprintf("token-%d-2", suffix)
+	expiresAt := time.Now().Add(time.Hour)
+
+	assert.NoError(t, storage.StoreRefreshToken(user.ID, firstToken, expiresAt))
+
+	rotated, err := storage.RotateRefreshToken(firstToken, secondToken, expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, secondToken, rotated.Token)
+
+	_, err = storage.RotateRefreshToken(firstToken, fmt.Sprintf("token-%d-3", suffix), expiresAt)
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+
+	stored, err := storage.GetRefreshToken(secondToken)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestGetUserByID(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery(`SELECT id, login, password_hash FROM users WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password_hash"}).AddRow(1, "testuser", "hash"))
+
+	user, err := storage.GetUserByID(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.DBUser{ID: 1, Login: "testuser", PasswordHash: "hash"}, user)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserPassword_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password_hash = \$1 WHERE id = \$2`).
+		WithArgs("newhash", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE user_id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err := storage.UpdateUserPassword(1, "newhash")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserPassword_RevocationError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password_hash = \$1 WHERE id = \$2`).
+		WithArgs("newhash", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE user_id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	err := storage.UpdateUserPassword(1, "newhash")
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePasswordHash_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec(`UPDATE users SET password_hash = \$1 WHERE id = \$2 AND password_hash = \$3 AND deleted_at IS NULL`).
+		WithArgs("newhash", int64(1), "oldhash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := storage.UpdatePasswordHash(1, "oldhash", "newhash")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePasswordHash_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec(`UPDATE users SET password_hash = \$1 WHERE id = \$2 AND password_hash = \$3`).
+		WithArgs("newhash", int64(1), "oldhash").
+		WillReturnError(errors.New("database error"))
+
+	err := storage.UpdatePasswordHash(1, "oldhash", "newhash")
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnonymizeUser_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT login FROM users WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"login"}).AddRow("testuser"))
+	mock.ExpectExec(`UPDATE users SET login = NULL, password_hash = '', deleted_at = NOW\(\) WHERE id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE user_id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM login_attempts WHERE scope = \$1 AND key = \$2`).
+		WithArgs(domain.LoginAttemptScopeLogin, "testuser").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := storage.AnonymizeUser(1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnonymizeUser_AlreadyDeleted(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT login FROM users WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := storage.AnonymizeUser(1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnonymizeUser_KeepsFinancialRecords(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	userID := SeedUserWithAccrual(t, storage, 100)
+	assert.NoError(t, storage.CreateWithdrawal(fmt.Sprintf("%d", time.Now().UnixNano()), 10, userID))
+
+	assert.NoError(t, storage.AnonymizeUser(userID))
+
+	user, err := storage.GetUserByID(userID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Nil(t, user)
+
+	withdrawals, err := storage.GetUserWithdrawals(domain.WithdrawalFilter{UserID: userID})
+	assert.NoError(t, err)
+	assert.Len(t, withdrawals.Withdrawals, 1)
+
+	balance, err := storage.GetUserBalance(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 90.0, balance.BalanceSum)
+}
@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/frolmr/gophermart/internal/domain"
+)
+
+func (s *Storage) CreateWebhookSubscription(subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	created := *subscription
+	err := s.db.QueryRow(
+		"INSERT INTO webhook_subscriptions (url, secret, event_types) VALUES ($1, $2, string_to_array($3, ',')) RETURNING id, created_at",
+		subscription.URL, subscription.Secret, strings.Join(subscription.EventTypes, ","),
+	).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		s.logger.Errorf("Webhook subscription insert fail for url: %s, err: %s", subscription.URL, err.Error())
+		return nil, fmt.Errorf("error creating webhook subscription: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (s *Storage) GetWebhookSubscriptions() ([]*domain.WebhookSubscription, error) {
+	rows, err := s.db.Query(
+		"SELECT id, url, array_to_string(event_types, ','), created_at FROM webhook_subscriptions ORDER BY id",
+	)
+	if err != nil {
+		s.logger.Errorf("Can't query webhook subscriptions, err: %s", err.Error())
+		return nil, fmt.Errorf("error getting webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*domain.WebhookSubscription
+	for rows.Next() {
+		var (
+			subscription domain.WebhookSubscription
+			eventTypes   string
+		)
+		if err := rows.Scan(&subscription.ID, &subscription.URL, &eventTypes, &subscription.CreatedAt); err != nil {
+			s.logger.Errorf("Can't scan webhook subscription, err: %s", err.Error())
+			return nil, fmt.Errorf("error getting webhook subscriptions: %w", err)
+		}
+		subscription.EventTypes = strings.Split(eventTypes, ",")
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for webhook subscriptions, err: %s", err.Error())
+		return nil, fmt.Errorf("error getting webhook subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func (s *Storage) DeleteWebhookSubscription(id int64) error {
+	res, err := s.db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		s.logger.Errorf("Webhook subscription delete fail for id: %d, err: %s", id, err.Error())
+		return fmt.Errorf("error deleting webhook subscription: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting webhook subscription: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) enqueueWebhookEvent(tx *sql.Tx, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook event: %w", err)
+	}
+
+	// Events nobody is subscribed to are dropped instead of piling up in the outbox.
+	query := `
+            WITH subscribers AS (
+                SELECT id FROM webhook_subscriptions WHERE $1::text = ANY(event_types)
+            ), event AS (
+                INSERT INTO webhook_outbox (event_type, payload)
+                SELECT $1::text, $2::jsonb WHERE EXISTS (SELECT 1 FROM subscribers)
+                RETURNING id
+            )
+            INSERT INTO webhook_deliveries (outbox_id, subscription_id)
+            SELECT event.id, subscribers.id
+            FROM event, subscribers`
+
+	if _, err := tx.Exec(query, eventType, string(payload)); err != nil {
+		s.logger.Errorf("Failed to enqueue %s webhook event, err: %s", eventType, err.Error())
+		return fmt.Errorf("error enqueueing webhook event: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*domain.WebhookDelivery, error) {
+	query := `
+            WITH due AS (
+                SELECT id FROM webhook_deliveries
+                WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+                ORDER BY next_attempt_at
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            )
+            UPDATE webhook_deliveries wd
+            SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+            FROM due, webhook_subscriptions ws, webhook_outbox wo
+            WHERE wd.id = due.id AND ws.id = wd.subscription_id AND wo.id = wd.outbox_id
+            RETURNING wd.id, wd.attempts, ws.url, ws.secret, wo.id, wo.event_type, wo.payload, wo.created_at`
+
+	rows, err := s.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		s.logger.Errorf("Can't claim webhook deliveries, err: %s", err.Error())
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		var (
+			delivery domain.WebhookDelivery
+			payload  []byte
+		)
+		err := rows.Scan(
+			&delivery.ID, &delivery.Attempts, &delivery.URL, &delivery.Secret,
+			&delivery.EventID, &delivery.EventType, &payload, &delivery.CreatedAt,
+		)
+		if err != nil {
+			s.logger.Errorf("Can't scan webhook delivery, err: %s", err.Error())
+			return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+		}
+		delivery.Payload = payload
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for webhook deliveries, err: %s", err.Error())
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) RecordWebhookAttempt(attempt *domain.WebhookAttempt) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Errorf("Transaction for webhook attempt error delivery_id: %d, err: %s", attempt.DeliveryID, err.Error())
+		return fmt.Errorf("error recording webhook attempt: %w", err)
+	}
+
+	var responseStatus, attemptError any
+	if attempt.ResponseStatus != 0 {
+		responseStatus = attempt.ResponseStatus
+	}
+	if attempt.Error != "" {
+		attemptError = attempt.Error
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO webhook_delivery_attempts (delivery_id, response_status, error, duration_ms) VALUES ($1, $2, $3, $4)",
+		attempt.DeliveryID, responseStatus, attemptError, attempt.Duration.Milliseconds(),
+	)
+	if err != nil {
+		s.logger.Errorf("Failed to log webhook attempt, delivery_id: %d, err: %s", attempt.DeliveryID, err.Error())
+		_ = tx.Rollback()
+		return fmt.Errorf("error recording webhook attempt: %w", err)
+	}
+
+	switch {
+	case attempt.Delivered:
+		_, err = tx.Exec(
+			"UPDATE webhook_deliveries SET status = 'DELIVERED', attempts = attempts + 1, delivered_at = NOW() WHERE id = $1",
+			attempt.DeliveryID,
+		)
+	case attempt.NextAttemptAt != nil:
+		_, err = tx.Exec(
+			"UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = $2 WHERE id = $1",
+			attempt.DeliveryID, *attempt.NextAttemptAt,
+		)
+	default:
+		_, err = tx.Exec(
+			"UPDATE webhook_deliveries SET status = 'FAILED', attempts = attempts + 1 WHERE id = $1",
+			attempt.DeliveryID,
+		)
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to update webhook delivery, delivery_id: %d, err: %s", attempt.DeliveryID, err.Error())
+		_ = tx.Rollback()
+		return fmt.Errorf("error recording webhook attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Transaction for webhook attempt commit error delivery_id: %d, err: %s", attempt.DeliveryID, err.Error())
+		return fmt.Errorf("error recording webhook attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetWebhookDeliveryLog(subscriptionID int64, limit int) ([]*domain.WebhookDeliveryLogEntry, error) {
+	query := `
+            SELECT wd.id, wd.outbox_id, wo.event_type, wd.status, wa.attempted_at, wa.response_status, wa.error, wa.duration_ms
+            FROM webhook_delivery_attempts wa
+            JOIN webhook_deliveries wd ON wd.id = wa.delivery_id
+            JOIN webhook_outbox wo ON wo.id = wd.outbox_id
+            WHERE wd.subscription_id = $1
+            ORDER BY wa.attempted_at DESC, wa.id DESC
+            LIMIT $2`
+
+	rows, err := s.db.Query(query, subscriptionID, limit)
+	if err != nil {
+		s.logger.Errorf("Can't query webhook delivery log for subscription_id: %d, err: %s", subscriptionID, err.Error())
+		return nil, fmt.Errorf("error getting webhook delivery log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*domain.WebhookDeliveryLogEntry
+	for rows.Next() {
+		var (
+			entry          domain.WebhookDeliveryLogEntry
+			responseStatus sql.NullInt64
+			attemptError   sql.NullString
+		)
+		err := rows.Scan(
+			&entry.DeliveryID, &entry.EventID, &entry.EventType, &entry.Status,
+			&entry.AttemptedAt, &responseStatus, &attemptError, &entry.DurationMS,
+		)
+		if err != nil {
+			s.logger.Errorf("Can't scan webhook delivery log for subscription_id: %d, err: %s", subscriptionID, err.Error())
+			return nil, fmt.Errorf("error getting webhook delivery log: %w", err)
+		}
+		if responseStatus.Valid {
+			status := int(responseStatus.Int64)
+			entry.ResponseStatus = &status
+		}
+		entry.Error = attemptError.String
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Errorf("Got rows.Err() for webhook delivery log of subscription_id: %d, err: %s", subscriptionID, err.Error())
+		return nil, fmt.Errorf("error getting webhook delivery log: %w", err)
+	}
+
+	if len(entries) == 0 {
+		var exists bool
+		err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1)", subscriptionID).Scan(&exists)
+		if err != nil {
+			s.logger.Errorf("Can't check webhook subscription_id: %d, err: %s", subscriptionID, err.Error())
+			return nil, fmt.Errorf("error getting webhook delivery log: %w", err)
+		}
+		if !exists {
+			return nil, domain.ErrNotFound
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *Storage) PruneWebhookOutbox(olderThan time.Duration) (int64, error) {
+	// Deliveries and their attempts go along with the event through ON DELETE CASCADE.
+	query := `
+            DELETE FROM webhook_outbox wo
+            WHERE wo.created_at < NOW() - $1 * INTERVAL '1 second'
+            AND NOT EXISTS (
+                SELECT 1 FROM webhook_deliveries wd WHERE wd.outbox_id = wo.id AND wd.status = 'PENDING'
+            )`
+
+	res, err := s.db.Exec(query, olderThan.Seconds())
+	if err != nil {
+		s.logger.Errorf("Failed to prune webhook outbox, err: %s", err.Error())
+		return 0, fmt.Errorf("error pruning webhook outbox: %w", err)
+	}
+
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error pruning webhook outbox: %w", err)
+	}
+
+	return pruned, nil
+}
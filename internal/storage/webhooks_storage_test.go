@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frolmr/gophermart/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhookSubscription_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	createdAt := time.Now()
+	subscription := &domain.WebhookSubscription{
+		URL:        "https://example.com/hooks",
+		Secret:     "0123456789abcdef",
+		EventTypes: []string{domain.WebhookOrderProcessed, domain.WebhookWithdrawalCreated},
+	}
+
+	mock.ExpectQuery(`INSERT INTO webhook_subscriptions \(url, secret, event_types\) VALUES \(\$1, \$2, string_to_array\(\$3, ','\)\)`).
+		WithArgs(subscription.URL, subscription.Secret, "order.processed,withdrawal.created").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(7), createdAt))
+
+	created, err := storage.CreateWebhookSubscription(subscription)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), created.ID)
+	assert.Equal(t, createdAt, created.CreatedAt)
+	assert.Equal(t, subscription.Secret, created.Secret)
+	assert.Equal(t, subscription.EventTypes, created.EventTypes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWebhookSubscription_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("INSERT INTO webhook_subscriptions").
+		WillReturnError(errors.New("database error"))
+
+	created, err := storage.CreateWebhookSubscription(&domain.WebhookSubscription{URL: "https://example.com/hooks"})
+
+	assert.Error(t, err)
+	assert.Nil(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhookSubscriptions_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	createdAt := time.Now()
+	mock.ExpectQuery(`SELECT id, url, array_to_string\(event_types, ','\), created_at FROM webhook_subscriptions ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "event_types", "created_at"}).
+			AddRow(int64(1), "https://example.com/a", "order.processed,order.invalid", createdAt).
+			AddRow(int64(2), "https://example.com/b", "withdrawal.created", createdAt))
+
+	subscriptions, err := storage.GetWebhookSubscriptions()
+
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 2)
+	assert.Equal(t, []string{domain.WebhookOrderProcessed, domain.WebhookOrderInvalid}, subscriptions[0].EventTypes)
+	assert.Equal(t, []string{domain.WebhookWithdrawalCreated}, subscriptions[1].EventTypes)
+	assert.Empty(t, subscriptions[0].Secret)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWebhookSubscription(t *testing.T) {
+	tests := []struct {
+		name        string
+		affected    int64
+		expectedErr error
+	}{
+		{name: "Deleted", affected: 1},
+		{name: "Not found", affected: 0, expectedErr: domain.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := NewMockStorage(t)
+
+			mock.ExpectExec(`DELETE FROM webhook_subscriptions WHERE id = \$1`).
+				WithArgs(int64(3)).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			err := storage.DeleteWebhookSubscription(3)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestClaimWebhookDeliveries_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	createdAt := time.Now()
+	mock.ExpectQuery(`(?s)WITH due AS .*FOR UPDATE SKIP LOCKED.*UPDATE webhook_deliveries wd`).
+		WithArgs(20, float64(60)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "attempts", "url", "secret", "event_id", "event_type", "payload", "created_at"}).
+			AddRow(int64(5), 2, "https://example.com/hooks", "secret", int64(9), domain.WebhookOrderInvalid,
+				[]byte(`{"user_id":1,"order":"12345678903","status":"INVALID"}`), createdAt))
+
+	deliveries, err := storage.ClaimWebhookDeliveries(20, time.Minute)
+
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, int64(5), deliveries[0].ID)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, int64(9), deliveries[0].EventID)
+	assert.Equal(t, domain.WebhookOrderInvalid, deliveries[0].EventType)
+	assert.JSONEq(t, `{"user_id":1,"order":"12345678903","status":"INVALID"}`, string(deliveries[0].Payload))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordWebhookAttempt(t *testing.T) {
+	nextAttemptAt := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		attempt     *domain.WebhookAttempt
+		attemptArgs []driver.Value
+		updateQuery string
+		updateArgs  []driver.Value
+	}{
+		{
+			name:        "Delivered",
+			attempt:     &domain.WebhookAttempt{DeliveryID: 1, ResponseStatus: 204, Duration: 15 * time.Millisecond, Delivered: true},
+			attemptArgs: []driver.Value{int64(1), 204, nil, int64(15)},
+			updateQuery: `UPDATE webhook_deliveries SET status = 'DELIVERED', attempts = attempts \+ 1, delivered_at = NOW\(\) WHERE id = \$1`,
+			updateArgs:  []driver.Value{int64(1)},
+		},
+		{
+			name: "Retry scheduled",
+			attempt: &domain.WebhookAttempt{
+				DeliveryID: 2, ResponseStatus: 500, Error: "unexpected response status 500", NextAttemptAt: &nextAttemptAt,
+			},
+			attemptArgs: []driver.Value{int64(2), 500, "unexpected response status 500", int64(0)},
+			updateQuery: `UPDATE webhook_deliveries SET attempts = attempts \+ 1, next_attempt_at = \$2 WHERE id = \$1`,
+			updateArgs:  []driver.Value{int64(2), nextAttemptAt},
+		},
+		{
+			name:        "Gave up",
+			attempt:     &domain.WebhookAttempt{DeliveryID: 3, Error: "connection refused"},
+			attemptArgs: []driver.Value{int64(3), nil, "connection refused", int64(0)},
+			updateQuery: `UPDATE webhook_deliveries SET status = 'FAILED', attempts = attempts \+ 1 WHERE id = \$1`,
+			updateArgs:  []driver.Value{int64(3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := NewMockStorage(t)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO webhook_delivery_attempts \(delivery_id, response_status, error, duration_ms\)`).
+				WithArgs(tt.attemptArgs...).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(tt.updateQuery).
+				WithArgs(tt.updateArgs...).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			err := storage.RecordWebhookAttempt(tt.attempt)
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRecordWebhookAttempt_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_delivery_attempts").
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	err := storage.RecordWebhookAttempt(&domain.WebhookAttempt{DeliveryID: 1, Delivered: true})
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhookDeliveryLog_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	attemptedAt := time.Now()
+	mock.ExpectQuery(`(?s)FROM webhook_delivery_attempts wa.*WHERE wd.subscription_id = \$1.*LIMIT \$2`).
+		WithArgs(int64(4), 100).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "outbox_id", "event_type", "status", "attempted_at", "response_status", "error", "duration_ms",
+		}).
+			AddRow(int64(8), int64(3), domain.WebhookOrderProcessed, domain.WebhookDeliveryDelivered, attemptedAt, int64(200), nil, int64(12)).
+			AddRow(int64(8), int64(3), domain.WebhookOrderProcessed, domain.WebhookDeliveryDelivered, attemptedAt, nil, "timeout", int64(10000)))
+
+	entries, err := storage.GetWebhookDeliveryLog(4, 100)
+
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NotNil(t, entries[0].ResponseStatus)
+	assert.Equal(t, 200, *entries[0].ResponseStatus)
+	assert.Empty(t, entries[0].Error)
+	assert.Nil(t, entries[1].ResponseStatus)
+	assert.Equal(t, "timeout", entries[1].Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhookDeliveryLog_EmptyLog(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("FROM webhook_delivery_attempts").
+		WithArgs(int64(4), 100).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "outbox_id", "event_type", "status", "attempted_at", "response_status", "error", "duration_ms",
+		}))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM webhook_subscriptions WHERE id = \$1\)`).
+		WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	entries, err := storage.GetWebhookDeliveryLog(4, 100)
+
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhookDeliveryLog_UnknownSubscription(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectQuery("FROM webhook_delivery_attempts").
+		WithArgs(int64(404), 100).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "outbox_id", "event_type", "status", "attempted_at", "response_status", "error", "duration_ms",
+		}))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM webhook_subscriptions WHERE id = \$1\)`).
+		WithArgs(int64(404)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := storage.GetWebhookDeliveryLog(404, 100)
+
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPruneWebhookOutbox_Success(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec(`DELETE FROM webhook_outbox wo\s+WHERE wo.created_at < NOW\(\) - \$1 \* INTERVAL '1 second'\s+` +
+		`AND NOT EXISTS \(\s+SELECT 1 FROM webhook_deliveries wd WHERE wd.outbox_id = wo.id AND wd.status = 'PENDING'\s+\)`).
+		WithArgs((24 * time.Hour).Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	pruned, err := storage.PruneWebhookOutbox(24 * time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), pruned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPruneWebhookOutbox_DatabaseError(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectExec("DELETE FROM webhook_outbox").
+		WillReturnError(errors.New("database error"))
+
+	pruned, err := storage.PruneWebhookOutbox(24 * time.Hour)
+
+	assert.Error(t, err)
+	assert.Zero(t, pruned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnqueueWebhookEvent_OnlyForSubscribedEvents(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	eventType := fmt.Sprintf("test.event-%d", time.Now().UnixNano())
+	enqueue := func() {
+		tx, err := storage.db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, storage.enqueueWebhookEvent(tx, eventType, map[string]int{"user_id": 1}))
+		require.NoError(t, tx.Commit())
+	}
+	countDeliveries := func() int {
+		var count int
+		err := storage.db.QueryRow(
+			"SELECT COUNT(*) FROM webhook_outbox wo JOIN webhook_deliveries wd ON wd.outbox_id = wo.id WHERE wo.event_type = $1",
+			eventType,
+		).Scan(&count)
+		require.NoError(t, err)
+		return count
+	}
+	countOutbox := func() int {
+		var count int
+		require.NoError(t, storage.db.QueryRow("SELECT COUNT(*) FROM webhook_outbox WHERE event_type = $1", eventType).Scan(&count))
+		return count
+	}
+
+	enqueue()
+	assert.Zero(t, countOutbox())
+
+	subscription, err := storage.CreateWebhookSubscription(&domain.WebhookSubscription{
+		URL:        "http://localhost/hook",
+		Secret:     "0123456789abcdef",
+		EventTypes: []string{eventType},
+	})
+	require.NoError(t, err)
+	defer func() { _ = storage.DeleteWebhookSubscription(subscription.ID) }()
+
+	enqueue()
+	assert.Equal(t, 1, countOutbox())
+	assert.Equal(t, 1, countDeliveries())
+}
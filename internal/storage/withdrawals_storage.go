@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/frolmr/gophermart/internal/domain"
@@ -9,70 +11,162 @@ import (
 
 func (s *Storage) CreateWithdrawal(orderNumber string, sum float64, userID int64) error {
 	sumInSubunit := formatter.ConvertToSubunit(sum)
-	query := `INSERT INTO withdrawals (order_number, sum, user_id) VALUES ($1, $2, $3)`
 
-	if _, err := s.db.Exec(query, orderNumber, sumInSubunit, userID); err != nil {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Errorf("Transaction for withdrawal order# %s begin error, err: %s", orderNumber, err.Error())
+		return fmt.Errorf("error creating withdrawal: %w", err)
+	}
+
+	// Concurrent withdrawals of the same user are serialized on the balance row,
+	// so the balance check below can't be passed twice with the same funds.
+	balance, err := s.lockUserBalance(tx, userID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating withdrawal: %w", err)
+	}
+
+	if balance < int64(sumInSubunit) {
+		_ = tx.Rollback()
+		return domain.ErrInsufficientFunds
+	}
+
+	query := `INSERT INTO withdrawals (order_number, sum, user_id) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(query, orderNumber, sumInSubunit, userID); err != nil {
 		s.logger.Errorf("Inserting order# %s, failed; err: %s ", orderNumber, err.Error())
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating withdrawal: %w", err)
+	}
+
+	amount := -int64(sumInSubunit)
+	if err := s.insertLedgerEntry(tx, userID, domain.LedgerEntryWithdrawal, amount, orderNumber); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating withdrawal: %w", err)
+	}
+
+	if err := s.applyBalanceChange(tx, userID, amount, -amount); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating withdrawal: %w", err)
+	}
+
+	event := &domain.WebhookWithdrawalEvent{UserID: userID, Order: orderNumber, Sum: sum}
+	if err := s.enqueueWebhookEvent(tx, domain.WebhookWithdrawalCreated, event); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("error creating withdrawal: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Transaction for withdrawal order# %s commit error, err: %s", orderNumber, err.Error())
 		return fmt.Errorf("error creating withdrawal: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Storage) GetAllUserWithdrawals(userID int64) ([]*domain.Withdrawal, error) {
-	var withdrawals []*domain.Withdrawal
+func (s *Storage) GetUserWithdrawals(filter domain.WithdrawalFilter) (*domain.WithdrawalPage, error) {
+	// Summary and page are read from one snapshot, so a withdrawal committed in between can't make them disagree.
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		s.logger.Errorf("Transaction for withdrawals of user_id: %d begin error, err: %s", filter.UserID, err.Error())
+		return nil, fmt.Errorf("error getting withdrawals: %w", err)
+	}
 
-	query := `
-            SELECT order_number, sum, processed_at
-            FROM withdrawals
-	        WHERE user_id = $1
-            ORDER BY processed_at DESC`
+	page := &domain.WithdrawalPage{}
+	if err := s.readWithdrawalsSummary(tx, filter, page); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err := s.readWithdrawalsPage(tx, filter, page); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
 
-	stmt, err := s.db.Prepare(query)
-	if err != nil {
-		s.logger.Errorf("Can't prepare statement for withdrawals selection for user_id: %s, err: %s ", userID, err.Error())
+	if err := tx.Commit(); err != nil {
+		s.logger.Errorf("Transaction for withdrawals of user_id: %d commit error, err: %s", filter.UserID, err.Error())
 		return nil, fmt.Errorf("error getting withdrawals: %w", err)
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query(userID)
+	return page, nil
+}
+
+func (s *Storage) readWithdrawalsSummary(tx *sql.Tx, filter domain.WithdrawalFilter, page *domain.WithdrawalPage) error {
+	summaryWindow := withdrawalWindow(filter)
+	//nolint:gosec // conditions are built from placeholders only, values are passed as args
+	summaryQuery := `
+            SELECT COUNT(*), COALESCE(SUM(sum), 0)
+            FROM withdrawals
+            WHERE ` + summaryWindow.String()
+
+	var total int64
+	if err := tx.QueryRow(summaryQuery, summaryWindow.args...).Scan(&page.Summary.Count, &total); err != nil {
+		s.logger.Errorf("Withdrawals summary for user_id: %d failed, err: %s", filter.UserID, err.Error())
+		return fmt.Errorf("error getting withdrawals: %w", err)
+	}
+	page.Summary.Total = formatter.ConvertToCurrency(total)
+
+	return nil
+}
+
+func (s *Storage) readWithdrawalsPage(tx *sql.Tx, filter domain.WithdrawalFilter, page *domain.WithdrawalPage) error {
+	pageWindow := withdrawalWindow(filter)
+	if filter.After != nil {
+		pageWindow.where("(processed_at, id) < (" + pageWindow.arg(filter.After.Time) + ", " + pageWindow.arg(filter.After.ID) + ")")
+	}
+	//nolint:gosec // conditions are built from placeholders only, values are passed as args
+	query := `
+            SELECT id, order_number, sum, processed_at
+            FROM withdrawals
+	        WHERE ` + pageWindow.String() + `
+            ORDER BY processed_at DESC, id DESC`
+	if filter.Limit > domain.NoPageLimit {
+		query += `
+            LIMIT ` + pageWindow.arg(filter.Limit+1)
+	}
+
+	rows, err := tx.Query(query, pageWindow.args...)
 	if err != nil {
-		s.logger.Errorf("Query for withdrawals for user_id: %s selection fialed, err: %s", userID, err.Error())
-		return nil, fmt.Errorf("error getting withdrawals: %w", err)
+		s.logger.Errorf("Query for withdrawals for user_id: %d selection failed, err: %s", filter.UserID, err.Error())
+		return fmt.Errorf("error getting withdrawals: %w", err)
 	}
 	defer rows.Close()
 
+	var lastID int64
 	for rows.Next() {
+		if filter.Limit > domain.NoPageLimit && len(page.Withdrawals) == filter.Limit {
+			last := page.Withdrawals[len(page.Withdrawals)-1]
+			page.NextCursor = &domain.Cursor{Time: last.ProcessedAt, ID: lastID}
+			break
+		}
+
 		var withdrawal domain.Withdrawal
-		err := rows.Scan(&withdrawal.Order, &withdrawal.Sum, &withdrawal.ProcessedAt)
-		if err != nil {
+		if err := rows.Scan(&lastID, &withdrawal.Order, &withdrawal.Sum, &withdrawal.ProcessedAt); err != nil {
 			s.logger.Error("Error scanning withdrawals ", err.Error())
-			return nil, fmt.Errorf("error getting withdrawals: %w", err)
+			return fmt.Errorf("error getting withdrawals: %w", err)
 		}
 		withdrawal.Sum /= domain.ToSubunitDelimeter
-		withdrawals = append(withdrawals, &withdrawal)
+		page.Withdrawals = append(page.Withdrawals, &withdrawal)
 	}
 
 	if err := rows.Err(); err != nil {
-		s.logger.Errorf("Got rows.Err() for user_id: %d, err: %s", userID, err.Error())
-		return nil, fmt.Errorf("error getting withdrawals: %w", err)
+		s.logger.Errorf("Got rows.Err() for user_id: %d, err: %s", filter.UserID, err.Error())
+		return fmt.Errorf("error getting withdrawals: %w", err)
 	}
 
-	return withdrawals, nil
+	return nil
 }
 
-func (s *Storage) GetUserWithdrawalsSum(userID int64) (float64, error) {
-	query := `
-			SELECT COALESCE(SUM(sum), 0) AS total_withdrawals
-			FROM withdrawals
-			WHERE user_id = $1`
-
-	var totalWithdrawals int64
-	err := s.db.QueryRow(query, userID).Scan(&totalWithdrawals)
-	if err != nil {
-		s.logger.Errorf("Withdrawal sum selection fail for user_id: %s, err: %s", userID, err.Error())
-		return 0, fmt.Errorf("error getting withdrawals sum: %w", err)
+func withdrawalWindow(filter domain.WithdrawalFilter) *whereBuilder {
+	wb := &whereBuilder{}
+	wb.where("user_id = " + wb.arg(filter.UserID))
+	if filter.Processed.From != nil {
+		wb.where("processed_at >= " + wb.arg(*filter.Processed.From))
+	}
+	if filter.Processed.To != nil {
+		wb.where("processed_at < " + wb.arg(*filter.Processed.To))
+	}
+	if filter.MinSum > 0 {
+		wb.where("sum >= " + wb.arg(formatter.ConvertToSubunit(filter.MinSum)))
 	}
 
-	return formatter.ConvertToCurrency(totalWithdrawals), nil
+	return wb
 }
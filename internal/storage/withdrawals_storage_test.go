@@ -2,6 +2,9 @@ package storage
 
 import (
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,9 +21,17 @@ func TestCreateWithdrawal_Success(t *testing.T) {
 	userID := int64(1)
 	sumInSubunit := int(sum * domain.ToSubunitDelimeter)
 
+	mock.ExpectBegin()
+	ExpectBalanceLock(mock, userID, 10000)
 	mock.ExpectExec("INSERT INTO withdrawals").
 		WithArgs(orderNumber, sumInSubunit, userID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(userID, domain.LedgerEntryWithdrawal, int64(-sumInSubunit), orderNumber).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ExpectBalanceChange(mock, userID, int64(-sumInSubunit), int64(sumInSubunit))
+	ExpectWebhookEvent(mock, domain.WebhookWithdrawalCreated, `{"user_id":1,"order":"12345678903","sum":50}`)
+	mock.ExpectCommit()
 
 	err := storage.CreateWithdrawal(orderNumber, sum, userID)
 
@@ -28,6 +39,23 @@ func TestCreateWithdrawal_Success(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestCreateWithdrawal_InsufficientFunds(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	orderNumber := "12345678903"
+	sum := 50.0
+	userID := int64(1)
+
+	mock.ExpectBegin()
+	ExpectBalanceLock(mock, userID, 4999)
+	mock.ExpectRollback()
+
+	err := storage.CreateWithdrawal(orderNumber, sum, userID)
+
+	assert.ErrorIs(t, err, domain.ErrInsufficientFunds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestCreateWithdrawal_DatabaseError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
@@ -36,109 +64,225 @@ func TestCreateWithdrawal_DatabaseError(t *testing.T) {
 	userID := int64(1)
 	sumInSubunit := int(sum * domain.ToSubunitDelimeter)
 
+	mock.ExpectBegin()
+	ExpectBalanceLock(mock, userID, 10000)
 	mock.ExpectExec("INSERT INTO withdrawals").
 		WithArgs(orderNumber, sumInSubunit, userID).
 		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
 
 	err := storage.CreateWithdrawal(orderNumber, sum, userID)
 
 	assert.Error(t, err)
+	assert.NotErrorIs(t, err, domain.ErrInsufficientFunds)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetAllUserWithdrawals_Success(t *testing.T) {
+func TestCreateWithdrawal_LedgerError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
+	orderNumber := "12345678903"
+	sum := 50.0
 	userID := int64(1)
-	processedAt := time.Now()
-	withdrawals := []*domain.Withdrawal{
-		{Order: "12345678903", Sum: 50.0, ProcessedAt: processedAt},
-		{Order: "98765432109", Sum: 30.0, ProcessedAt: processedAt},
-	}
-
-	rows := sqlmock.NewRows([]string{"order_number", "sum", "processed_at"}).
-		AddRow(withdrawals[0].Order, int(withdrawals[0].Sum*domain.ToSubunitDelimeter), withdrawals[0].ProcessedAt).
-		AddRow(withdrawals[1].Order, int(withdrawals[1].Sum*domain.ToSubunitDelimeter), withdrawals[1].ProcessedAt)
+	sumInSubunit := int(sum * domain.ToSubunitDelimeter)
 
-	mock.ExpectPrepare("SELECT order_number, sum, processed_at FROM withdrawals").
-		ExpectQuery().
-		WithArgs(userID).
-		WillReturnRows(rows)
+	mock.ExpectBegin()
+	ExpectBalanceLock(mock, userID, 10000)
+	mock.ExpectExec("INSERT INTO withdrawals").
+		WithArgs(orderNumber, sumInSubunit, userID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
 
-	result, err := storage.GetAllUserWithdrawals(userID)
+	err := storage.CreateWithdrawal(orderNumber, sum, userID)
 
-	assert.NoError(t, err)
-	assert.Equal(t, withdrawals, result)
+	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetAllUserWithdrawals_NoWithdrawals(t *testing.T) {
+func TestCreateWithdrawal_LockError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	userID := int64(1)
 
-	rows := sqlmock.NewRows([]string{"order_number", "sum", "processed_at"})
-
-	mock.ExpectPrepare("SELECT order_number, sum, processed_at FROM withdrawals").
-		ExpectQuery().
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO user_balances \(user_id\)`).
 		WithArgs(userID).
-		WillReturnRows(rows)
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT current FROM user_balances WHERE user_id = \$1 FOR UPDATE`).
+		WithArgs(userID).
+		WillReturnError(errors.New("lock timeout"))
+	mock.ExpectRollback()
 
-	result, err := storage.GetAllUserWithdrawals(userID)
+	err := storage.CreateWithdrawal("12345678903", 50.0, userID)
 
-	assert.NoError(t, err)
-	assert.Empty(t, result)
+	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetAllUserWithdrawals_DatabaseError(t *testing.T) {
+func TestCreateWithdrawal_ConcurrentWithdrawals(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	userID := SeedUserWithAccrual(t, storage, 100.0)
+
+	const attempts = 20
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int32
+		rejected  atomic.Int32
+	)
+
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := storage.CreateWithdrawal(fmt.Sprintf("%d%d", userID, i), 10.0, userID)
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, domain.ErrInsufficientFunds):
+				rejected.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	balance, err := storage.GetUserBalance(userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(10), succeeded.Load())
+	assert.Equal(t, int32(attempts-10), rejected.Load())
+	assert.Equal(t, &domain.Balance{BalanceSum: 0, WithdrawalSum: 100}, balance)
+}
+
+func TestGetUserWithdrawals_Success(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
 	userID := int64(1)
+	processedAt := time.Now()
+	withdrawals := []*domain.Withdrawal{
+		{Order: "12345678903", Sum: 50.0, ProcessedAt: processedAt},
+		{Order: "98765432109", Sum: 30.0, ProcessedAt: processedAt},
+	}
 
-	mock.ExpectPrepare("SELECT order_number, sum, processed_at FROM withdrawals").
-		ExpectQuery().
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\), COALESCE\(SUM\(sum\), 0\)\s+FROM withdrawals\s+WHERE user_id = \$1$`).
 		WithArgs(userID).
-		WillReturnError(errors.New("database error"))
+		WillReturnRows(sqlmock.NewRows([]string{"count", "total"}).AddRow(2, 8000))
 
-	result, err := storage.GetAllUserWithdrawals(userID)
+	rows := sqlmock.NewRows([]string{"id", "order_number", "sum", "processed_at"}).
+		AddRow(2, withdrawals[0].Order, int(withdrawals[0].Sum*domain.ToSubunitDelimeter), withdrawals[0].ProcessedAt).
+		AddRow(1, withdrawals[1].Order, int(withdrawals[1].Sum*domain.ToSubunitDelimeter), withdrawals[1].ProcessedAt)
 
-	assert.Error(t, err)
-	assert.Nil(t, result)
+	mock.ExpectQuery(`SELECT id, order_number, sum, processed_at\s+FROM withdrawals\s+` +
+		`WHERE user_id = \$1\s+ORDER BY processed_at DESC, id DESC$`).
+		WithArgs(userID).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	result, err := storage.GetUserWithdrawals(domain.WithdrawalFilter{UserID: userID})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.WithdrawalPage{
+		Withdrawals: withdrawals,
+		Summary:     domain.WithdrawalSummary{Count: 2, Total: 80},
+	}, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetUserWithdrawalsSum_Success(t *testing.T) {
+func TestGetUserWithdrawals_FiltersAndCursor(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	userID := int64(1)
-	totalWithdrawals := 80.0
-	totalWithdrawalsInSubunit := int(totalWithdrawals * domain.ToSubunitDelimeter)
+	cursor := &domain.Cursor{Time: time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC), ID: 9}
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	older := cursor.Time.Add(-time.Hour)
+
+	window := `WHERE user_id = \$1 AND processed_at >= \$2 AND processed_at < \$3 AND sum >= \$4`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\).*`+window+`$`).
+		WithArgs(int64(1), from, to, 1050).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "total"}).AddRow(3, 6000))
+
+	mock.ExpectQuery(`SELECT id, order_number.*`+window+` AND \(processed_at, id\) < \(\$5, \$6\)\s+`+
+		`ORDER BY processed_at DESC, id DESC\s+LIMIT \$7`).
+		WithArgs(int64(1), from, to, 1050, cursor.Time, cursor.ID, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_number", "sum", "processed_at"}).
+			AddRow(8, "12345678903", 2000, older).
+			AddRow(7, "98765432109", 2000, older))
+	mock.ExpectCommit()
+
+	result, err := storage.GetUserWithdrawals(domain.WithdrawalFilter{
+		UserID:    1,
+		Processed: domain.TimeRange{From: &from, To: &to},
+		MinSum:    10.5,
+		Limit:     1,
+		After:     cursor,
+	})
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\), 0\\) AS total_withdrawals FROM withdrawals").
-		WithArgs(userID).
-		WillReturnRows(sqlmock.NewRows([]string{"total_withdrawals"}).
-			AddRow(totalWithdrawalsInSubunit))
+	assert.NoError(t, err)
+	assert.Len(t, result.Withdrawals, 1)
+	assert.Equal(t, &domain.Cursor{Time: older, ID: 8}, result.NextCursor)
+	assert.Equal(t, domain.WithdrawalSummary{Count: 3, Total: 60}, result.Summary)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	result, err := storage.GetUserWithdrawalsSum(userID)
+func TestGetUserWithdrawals_NoWithdrawals(t *testing.T) {
+	storage, mock := NewMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "total"}).AddRow(0, 0))
+	mock.ExpectQuery("SELECT id, order_number, sum, processed_at FROM withdrawals").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_number", "sum", "processed_at"}))
+	mock.ExpectCommit()
+
+	result, err := storage.GetUserWithdrawals(domain.WithdrawalFilter{UserID: 1})
 
 	assert.NoError(t, err)
-	assert.Equal(t, totalWithdrawals, result)
+	assert.Empty(t, result.Withdrawals)
+	assert.Zero(t, result.Summary)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetUserWithdrawalsSum_DatabaseError(t *testing.T) {
+func TestGetUserWithdrawals_DatabaseError(t *testing.T) {
 	storage, mock := NewMockStorage(t)
 
-	userID := int64(1)
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\), 0\\) AS total_withdrawals FROM withdrawals").
-		WithArgs(userID).
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT").
+		WithArgs(int64(1)).
 		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
 
-	result, err := storage.GetUserWithdrawalsSum(userID)
+	result, err := storage.GetUserWithdrawals(domain.WithdrawalFilter{UserID: 1})
 
 	assert.Error(t, err)
-	assert.Equal(t, 0.0, result)
+	assert.Nil(t, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetUserWithdrawals_SummaryCoversWholeWindow(t *testing.T) {
+	storage := NewIntegrationStorage(t)
+
+	userID := SeedUserWithAccrual(t, storage, 100)
+	for i, sum := range []float64{5, 10, 20} {
+		orderNumber := fmt.Sprintf("%d%d", time.Now().UnixNano(), i)
+		if err := storage.CreateWithdrawal(orderNumber, sum, userID); err != nil {
+			t.Fatalf("Failed to create withdrawal: %v", err)
+		}
+	}
+
+	page, err := storage.GetUserWithdrawals(domain.WithdrawalFilter{UserID: userID, MinSum: 10, Limit: 1})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Withdrawals, 1)
+	assert.Equal(t, 20.0, page.Withdrawals[0].Sum)
+	assert.NotNil(t, page.NextCursor)
+	assert.Equal(t, domain.WithdrawalSummary{Count: 2, Total: 30}, page.Summary)
+}